@@ -20,6 +20,9 @@ TOOLS=(
     "heatmap-generator"
     "performance-analyzer"
     "scheduler-analyzer"
+    "scheduler-recovery"
+    "scheduler-selector"
+    "dynamic-quota-controller"
 )
 
 # 函数定义
@@ -25,6 +25,9 @@ TOOLS=(
     "scheduler-visualizer"
     "heatmap-generator"
     "performance-analyzer"
+    "scheduler-recovery"
+    "scheduler-selector"
+    "dynamic-quota-controller"
 )
 
 # 函数定义
@@ -0,0 +1,110 @@
+// dynamic-quota-controller 动态配额控制器
+// 按DynamicQuotaPolicy协调各命名空间的dynamic-quota ResourceQuota，
+// 通过领导者选举保证同一时间只有一个副本调整配额和回写策略状态
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubernetes-fundamentals/internal/utils"
+	"github.com/kubernetes-fundamentals/pkg/scheduler"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+func main() {
+	klog.InitFlags(nil)
+
+	// 解析命令行参数
+	var (
+		kubeconfig     = flag.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config, then ~/.kube/config)")
+		port           = flag.String("port", utils.GetEnvOrDefault("HTTP_PORT", "8080"), "HTTP port serving /healthz")
+		resyncInterval = flag.Duration("resync-interval", time.Minute, "Interval of the periodic reconcile and capacity arbitration of all managed namespaces")
+		persistState   = flag.Bool("persist-state", true, "Keep usage history and scaling cooldowns in a dynamic-quota-state ConfigMap of each managed namespace")
+		leaderElect    = flag.Bool("leader-elect", true, "Reconcile quotas only while holding the leader lease")
+		lockName       = flag.String("leader-elect-lock-name", "dynamic-quota-controller", "Name of the leader election lease")
+		lockNamespace  = flag.String("leader-elect-namespace", "", "Namespace of the leader election lease (defaults to $POD_NAMESPACE, then kube-system)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Scale namespace resource quotas according to DynamicQuotaPolicy resources.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *resyncInterval <= 0 {
+		klog.Fatalf("--resync-interval must be positive, got %v", *resyncInterval)
+	}
+
+	klog.Info("Starting dynamic quota controller...")
+
+	config, err := utils.GetKubernetesConfig(*kubeconfig)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes config: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		klog.Fatalf("Failed to create dynamic client: %v", err)
+	}
+
+	manager := scheduler.NewDynamicResourceQuotaManager(client)
+	if *persistState {
+		manager.SetStateStore(scheduler.NewConfigMapQuotaStateStore(client))
+	}
+
+	controllerConfig := scheduler.DynamicQuotaControllerConfig{
+		ResyncInterval: *resyncInterval,
+	}
+	if *leaderElect {
+		controllerConfig.LeaderElection = utils.LeaderElectionConfig{
+			LockName:      *lockName,
+			LockNamespace: *lockNamespace,
+		}
+	}
+	controller := scheduler.NewDynamicQuotaController(client, dynamicClient, manager, controllerConfig)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "ok")
+	})
+	server := &http.Server{
+		Addr:         ":" + *port,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	go func() {
+		klog.Infof("Starting health server on port %s", *port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			klog.Fatalf("Health server failed: %v", err)
+		}
+	}()
+
+	if err := controller.Run(ctx); err != nil {
+		klog.Errorf("Leader election failed: %v", err)
+	}
+
+	klog.Info("Shutting down health server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		klog.Errorf("Health server shutdown error: %v", err)
+	}
+}
@@ -14,6 +14,15 @@ import (
 )
 
 func main() {
+	// tune子命令：比较当前调度器配置与推荐配置
+	if len(os.Args) > 1 && os.Args[1] == "tune" {
+		if err := runTune(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 解析命令行参数
 	var (
 		port       = flag.String("port", "8081", "HTTP server port")
@@ -92,6 +101,72 @@ func main() {
 	}
 }
 
+// runTune 根据集群规模生成推荐的调度器配置，打印与ConfigMap中当前配置的差异，可选择直接应用
+func runTune(args []string) error {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	var (
+		kubeconfig = fs.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config, then ~/.kube/config)")
+		namespace  = fs.String("namespace", "kube-system", "Namespace of the scheduler ConfigMap and Deployment")
+		configMap  = fs.String("configmap", "scheduler-config", "ConfigMap holding the scheduler configuration")
+		configKey  = fs.String("key", "scheduler-config.yaml", "Key of the scheduler configuration in the ConfigMap")
+		nodes      = fs.Int("nodes", 0, "Cluster size to optimize for (0 = detect from the cluster)")
+		apply      = fs.Bool("apply", false, "Write the recommended configuration to the ConfigMap")
+		deployment = fs.String("restart-deployment", "", "Scheduler Deployment to restart after applying (rolled back if unhealthy)")
+		timeout    = fs.Duration("rollout-timeout", 5*time.Minute, "Time to wait for the scheduler rollout to become healthy")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s tune [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print a diff between the current scheduler configuration and the recommended one.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	tuner, err := scheduler.NewSchedulerPerformanceTunerFromKubeconfig(*kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	nodeCount := *nodes
+	if nodeCount > 0 {
+		tuner.OptimizeForClusterSize(nodeCount)
+	} else if nodeCount, err = tuner.OptimizeForCluster(ctx); err != nil {
+		return err
+	}
+
+	target := scheduler.SchedulerConfigTarget{
+		Namespace:      *namespace,
+		ConfigMapName:  *configMap,
+		ConfigKey:      *configKey,
+		DeploymentName: *deployment,
+	}
+	current, recommended, diff, err := tuner.DiffWithCurrentConfig(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Recommended scheduler configuration for %d nodes (%s/%s, key %s)\n\n", nodeCount, *namespace, *configMap, *configKey)
+	if current == recommended {
+		fmt.Println("Scheduler configuration is already up to date")
+		return nil
+	}
+	fmt.Print(diff)
+
+	if !*apply {
+		return nil
+	}
+	result, err := tuner.ApplyOptimizedConfig(ctx, target, scheduler.SchedulerConfigApplyOptions{
+		Restart:        *deployment != "",
+		RolloutTimeout: *timeout,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nApplied recommended configuration (changed=%v, restarted=%v)\n", result.Changed, result.Restarted)
+	return nil
+}
+
 func formatReportAsText(report *scheduler.AnalysisReport) string {
 	var result string
 
@@ -0,0 +1,388 @@
+// scheduler-recovery 调度器故障恢复服务
+// 运行健康检查器、基于策略的恢复管理器和基于健康发现的恢复循环，
+// 通过领导者选举保证同一时间只有一个副本执行恢复动作、发送告警和提供事件API
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kubernetes-fundamentals/internal/metrics"
+	"github.com/kubernetes-fundamentals/internal/utils"
+	"github.com/kubernetes-fundamentals/pkg/scheduler"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// leaderLabel 领导者副本Pod上的标签，scheduler-recovery-leader Service只选择领导者，事件API经由它访问
+const leaderLabel = "scheduler-recovery.kubernetes.io/leader"
+
+// recoveryService 健康检查在所有副本上运行，告警、恢复组件和事件API只在领导者任期内运行
+type recoveryService struct {
+	client          kubernetes.Interface
+	healthChecker   *scheduler.HealthChecker
+	policies        *scheduler.RecoveryPolicyConfig
+	recoveryConfig  *scheduler.RecoveryConfig
+	recorder        record.EventRecorder
+	namespace       string // 本服务所在命名空间
+	podName         string // 本副本的Pod名称，为空时不设置领导者标签
+	policyConfigMap string // 领导者任期内监听的恢复策略ConfigMap，为空时不热更新
+	incidentToken   string // 确认和解决事件所需的Bearer令牌，为空时禁止确认和解决
+
+	mu        sync.RWMutex
+	leading   bool
+	recovery  *scheduler.RecoveryManager
+	incidents *scheduler.IncidentManager
+
+	metrics       *metrics.MetricsCollector
+	leaderGauge   prometheus.Gauge
+	dryRunGauge   prometheus.Gauge
+	incidentGauge *prometheus.GaugeVec
+	lastExecution *prometheus.GaugeVec
+}
+
+func main() {
+	klog.InitFlags(nil)
+
+	// 解析命令行参数
+	var (
+		kubeconfig       = flag.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config, then ~/.kube/config)")
+		port             = flag.String("port", utils.GetEnvOrDefault("HTTP_PORT", "8080"), "HTTP server port for /status, /metrics, /healthz and /incidents")
+		healthConfig     = flag.String("health-config", "/etc/scheduler-health/health-checks.json", "Path to health-checks.json from scheduler-health-config")
+		recoveryConfig   = flag.String("recovery-config", "/etc/scheduler-recovery/recovery-policies.json", "Path to recovery-policies.json from scheduler-recovery-config")
+		recoveryInterval = flag.Duration("recovery-interval", 30*time.Second, "Interval of the health finding driven recovery loop")
+		dryRun           = flag.Bool("dry-run", false, "Log planned recovery actions without mutating the cluster")
+		leaderElect      = flag.Bool("leader-elect", true, "Run recovery only while holding the leader lease")
+		lockName         = flag.String("leader-elect-lock-name", "scheduler-recovery", "Name of the leader election lease")
+		lockNamespace    = flag.String("leader-elect-namespace", "", "Namespace of the leader election lease (defaults to $POD_NAMESPACE, then kube-system)")
+		policyConfigMap  = flag.String("recovery-configmap", "scheduler-recovery-config", "ConfigMap in $POD_NAMESPACE watched for recovery policy changes while leading (empty loads --recovery-config once)")
+		tokenFile        = flag.String("incident-token-file", "/etc/scheduler-recovery-incidents/token", "File with the bearer token required to acknowledge or resolve incidents (disabled when missing)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Run scheduler health checks and automatic recovery.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *recoveryInterval <= 0 {
+		klog.Fatalf("--recovery-interval must be positive, got %v", *recoveryInterval)
+	}
+
+	klog.Info("Starting scheduler recovery service...")
+
+	healthChecks, err := scheduler.LoadHealthCheckConfigFile(*healthConfig)
+	if err != nil {
+		klog.Fatalf("Failed to load health check config: %v", err)
+	}
+	policies, err := scheduler.LoadRecoveryPoliciesFile(*recoveryConfig)
+	if err != nil {
+		klog.Fatalf("Failed to load recovery policies: %v", err)
+	}
+	alertManager, err := scheduler.NewAlertManagerFromConfig(healthChecks.Alerting)
+	if err != nil {
+		klog.Fatalf("Failed to create alert manager: %v", err)
+	}
+
+	client, err := utils.GetKubernetesClient(*kubeconfig)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	config := scheduler.DefaultRecoveryConfig()
+	config.HealthCheckInterval = *recoveryInterval
+	config.DryRun = *dryRun
+	if *dryRun {
+		klog.Info("Dry run enabled: recovery actions are logged but not executed")
+	}
+
+	incidentToken, err := readIncidentToken(*tokenFile)
+	if err != nil {
+		klog.Fatalf("Failed to read incident token: %v", err)
+	}
+	if incidentToken == "" {
+		klog.Warningf("No incident token at %s, acknowledging and resolving incidents over HTTP is disabled", *tokenFile)
+	}
+
+	svc := newRecoveryService(client, policies, config)
+	svc.healthChecker = scheduler.NewHealthChecker(healthChecks.Checks, alertManager).WithKubernetesClient(client)
+	svc.recorder = scheduler.NewSchedulerEventRecorder(client, "scheduler-recovery")
+	svc.namespace = utils.GetEnvOrDefault("POD_NAMESPACE", "kube-system")
+	svc.podName = os.Getenv("POD_NAME")
+	svc.policyConfigMap = *policyConfigMap
+	svc.incidentToken = incidentToken
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 健康检查在所有副本上运行，/status在非领导者副本上同样可用；告警只由领导者发送
+	svc.healthChecker.SetAlerting(false)
+	svc.healthChecker.Start()
+	defer svc.healthChecker.Stop()
+	defer alertManager.Stop()
+
+	server := &http.Server{
+		Addr:    ":" + *port,
+		Handler: svc.routes(),
+	}
+	go func() {
+		klog.Infof("Starting HTTP server on port %s", *port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			klog.Fatalf("HTTP server failed: %v", err)
+		}
+	}()
+
+	if *leaderElect {
+		err = utils.RunWithLeaderElection(ctx, client, utils.LeaderElectionConfig{
+			LockName:      *lockName,
+			LockNamespace: *lockNamespace,
+		}, svc.run)
+		if err != nil {
+			klog.Errorf("Leader election failed: %v", err)
+		}
+	} else {
+		svc.run(ctx)
+	}
+
+	klog.Info("Shutting down HTTP server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		klog.Errorf("HTTP server shutdown error: %v", err)
+	}
+}
+
+// newRecoveryService 创建恢复服务并注册指标
+func newRecoveryService(client kubernetes.Interface, policies *scheduler.RecoveryPolicyConfig, config *scheduler.RecoveryConfig) *recoveryService {
+	svc := &recoveryService{
+		client:         client,
+		policies:       policies,
+		recoveryConfig: config,
+		metrics:        metrics.NewMetricsCollector("scheduler", "recovery"),
+		leaderGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "scheduler",
+			Subsystem: "recovery",
+			Name:      "leader",
+			Help:      "Whether this replica holds the leader lease and runs recovery (1=leader, 0=standby)",
+		}),
+		dryRunGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "scheduler",
+			Subsystem: "recovery",
+			Name:      "dry_run",
+			Help:      "Whether recovery actions are only logged (1=dry run, 0=live)",
+		}),
+		incidentGauge: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "scheduler",
+			Subsystem: "recovery",
+			Name:      "incidents",
+			Help:      "Number of incidents by state",
+		}, []string{"state"}),
+		lastExecution: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "scheduler",
+			Subsystem: "recovery",
+			Name:      "policy_last_execution_timestamp_seconds",
+			Help:      "Unix time of the last execution of each recovery policy",
+		}, []string{"policy"}),
+	}
+	svc.metrics.MustRegister(svc.leaderGauge, svc.dryRunGauge, svc.incidentGauge, svc.lastExecution)
+	if config.DryRun {
+		svc.dryRunGauge.Set(1)
+	}
+	return svc
+}
+
+// run 在领导者任期内运行恢复组件，任期结束（ctx取消）时停止
+// 恢复管理器和事件管理器停止后不能重新启动，每个任期重新创建，事件从ConfigMap恢复
+func (s *recoveryService) run(ctx context.Context) {
+	incidents, err := scheduler.NewIncidentManager(s.policies.Escalation)
+	if err != nil {
+		klog.Errorf("Failed to create incident manager: %v", err)
+		return
+	}
+	// 事件保存在本服务所在命名空间的ConfigMap中，新的领导者接管未解决的事件
+	incidents.WithStore(scheduler.NewConfigMapIncidentStore(s.client, s.namespace))
+	executor := scheduler.NewSchedulerRecoveryManager(s.client, s.recoveryConfig).WithEventRecorder(s.recorder)
+	recovery := scheduler.NewRecoveryManager(s.client, s.policies.Policies, s.healthChecker)
+	recovery.SetRecoveryExecutor(executor)
+	recovery.SetIncidentManager(incidents)
+	if s.policyConfigMap != "" {
+		if err := recovery.WatchPolicies(s.namespace, s.policyConfigMap); err != nil {
+			klog.Errorf("Failed to watch recovery policies, running the policies loaded at startup: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.leading = true
+	s.recovery = recovery
+	s.incidents = incidents
+	s.mu.Unlock()
+
+	klog.Infof("Running %d recovery policies, registered actions: %v", len(recovery.Policies()), scheduler.RecoveryActionTypes())
+	s.healthChecker.SetAlerting(true)
+	s.setLeaderLabel(ctx, true)
+	incidents.Start()
+	recovery.Start()
+	// 恢复循环和恢复策略共用executor，恢复动作逐个执行
+	executor.StartRecoveryLoop(ctx)
+	recovery.Stop()
+	incidents.Stop()
+	s.healthChecker.SetAlerting(false)
+
+	s.mu.Lock()
+	s.leading = false
+	s.recovery = nil
+	s.incidents = nil
+	s.mu.Unlock()
+
+	// 任期已结束，ctx已取消
+	labelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.setLeaderLabel(labelCtx, false)
+}
+
+// setLeaderLabel 在本副本的Pod上设置或移除领导者标签
+func (s *recoveryService) setLeaderLabel(ctx context.Context, leading bool) {
+	if s.podName == "" {
+		return
+	}
+	value := "null"
+	if leading {
+		value = `"true"`
+	}
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%s}}}`, leaderLabel, value)
+	_, err := s.client.CoreV1().Pods(s.namespace).Patch(ctx, s.podName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		klog.Errorf("Failed to update leader label on pod %s/%s: %v", s.namespace, s.podName, err)
+	}
+}
+
+// readIncidentToken 读取事件API的令牌，文件不存在时返回空
+func readIncidentToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// authorizeIncidentUpdate 校验确认和解决请求的Bearer令牌
+func (s *recoveryService) authorizeIncidentUpdate(w http.ResponseWriter, r *http.Request) bool {
+	if s.incidentToken == "" {
+		http.Error(w, "acknowledging and resolving incidents is disabled, no incident token configured", http.StatusForbidden)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.incidentToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// current 返回当前任期的恢复组件，非领导者时为空
+func (s *recoveryService) current() (bool, *scheduler.RecoveryManager, *scheduler.IncidentManager) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.leading, s.recovery, s.incidents
+}
+
+// routes 设置 HTTP 路由
+func (s *recoveryService) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "ok")
+	})
+
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		leading, recovery, _ := s.current()
+		status := map[string]interface{}{
+			"leader":  leading,
+			"dryRun":  s.recoveryConfig.DryRun,
+			"healthy": s.healthChecker.IsHealthy(),
+			"health":  s.healthChecker.GetAllStatuses(),
+		}
+		if recovery != nil {
+			status["recovery"] = recovery.GetRecoveryStatus()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})
+
+	metricsHandler := s.metrics.Handler()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		s.updateMetrics()
+		metricsHandler.ServeHTTP(w, r)
+	})
+
+	// 事件只在领导者上维护，通过scheduler-recovery-leader Service访问；确认和解决需要令牌
+	incidentsHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && !s.authorizeIncidentUpdate(w, r) {
+			return
+		}
+		_, _, incidents := s.current()
+		if incidents == nil {
+			http.Error(w, "not the leader, incidents are served by the scheduler-recovery-leader service", http.StatusServiceUnavailable)
+			return
+		}
+		incidents.Handler().ServeHTTP(w, r)
+	}
+	mux.HandleFunc("/incidents", incidentsHandler)
+	mux.HandleFunc("/incidents/", incidentsHandler)
+
+	return mux
+}
+
+// updateMetrics 在抓取前根据当前状态刷新指标
+func (s *recoveryService) updateMetrics() {
+	for name, status := range s.healthChecker.GetAllStatuses() {
+		s.metrics.SetHealthStatus(name, status.Healthy)
+	}
+
+	leading, recovery, incidents := s.current()
+	if leading {
+		s.leaderGauge.Set(1)
+	} else {
+		s.leaderGauge.Set(0)
+	}
+
+	s.incidentGauge.Reset()
+	if incidents != nil {
+		for _, state := range []string{scheduler.IncidentOpen, scheduler.IncidentAcknowledged, scheduler.IncidentResolved} {
+			s.incidentGauge.WithLabelValues(state).Set(float64(len(incidents.Incidents(state))))
+		}
+	}
+
+	s.lastExecution.Reset()
+	if recovery != nil {
+		if executions, ok := recovery.GetRecoveryStatus()["lastExecution"].(map[string]time.Time); ok {
+			for policy, t := range executions {
+				s.lastExecution.WithLabelValues(policy).Set(float64(t.Unix()))
+			}
+		}
+	}
+}
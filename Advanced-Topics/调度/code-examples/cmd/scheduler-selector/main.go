@@ -0,0 +1,105 @@
+// scheduler-selector 智能调度器选择服务
+// 以变更准入Webhook的形式在Pod创建时设置spec.schedulerName，
+// 并可选运行调度器选择控制器，在Deployment、StatefulSet和CronJob的Pod模板上设置调度器
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kubernetes-fundamentals/internal/utils"
+	"github.com/kubernetes-fundamentals/pkg/scheduler"
+	"k8s.io/klog/v2"
+)
+
+func main() {
+	klog.InitFlags(nil)
+
+	// 解析命令行参数
+	var (
+		kubeconfig         = flag.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config, then ~/.kube/config)")
+		port               = flag.String("port", utils.GetEnvOrDefault("HTTPS_PORT", "8443"), "HTTPS port serving /mutate-pods and /healthz")
+		tlsCertFile        = flag.String("tls-cert-file", "/etc/scheduler-selector/tls/tls.crt", "TLS certificate for the admission webhook")
+		tlsKeyFile         = flag.String("tls-private-key-file", "/etc/scheduler-selector/tls/tls.key", "TLS private key for the admission webhook")
+		checkAvailability  = flag.Bool("check-availability", true, "Fall back to the next matching scheduler when the selected one has no fresh leader lease")
+		runController      = flag.Bool("controller", false, "Also set the scheduler on Deployment, StatefulSet and CronJob pod templates")
+		controllerInterval = flag.Duration("controller-interval", 5*time.Minute, "Reconcile interval of the pod template controller")
+		leaderElect        = flag.Bool("leader-elect", true, "Run the pod template controller only while holding the leader lease")
+		lockName           = flag.String("leader-elect-lock-name", "scheduler-selector", "Name of the leader election lease")
+		lockNamespace      = flag.String("leader-elect-namespace", "", "Namespace of the leader election lease (defaults to $POD_NAMESPACE, then kube-system)")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Select the scheduler of new pods through a mutating admission webhook.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	klog.Info("Starting scheduler selector...")
+
+	client, err := utils.GetKubernetesClient(*kubeconfig)
+	if err != nil {
+		klog.Fatalf("Failed to create Kubernetes client: %v", err)
+	}
+
+	selector := scheduler.NewSchedulerSelector(client)
+	if *checkAvailability {
+		selector.WithAvailability(scheduler.NewLiveSchedulerRegistry(client, nil),
+			scheduler.NewSchedulerEventRecorder(client, "scheduler-selector"))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /mutate-pods", scheduler.NewSchedulerSelectorWebhook(selector))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "ok")
+	})
+
+	// API Server调用Webhook的超时默认为10秒，读写超时与之对齐
+	server := &http.Server{
+		Addr:         ":" + *port,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	go func() {
+		klog.Infof("Starting admission webhook on port %s", *port)
+		if err := server.ListenAndServeTLS(*tlsCertFile, *tlsKeyFile); err != nil && err != http.ErrServerClosed {
+			klog.Fatalf("Admission webhook server failed: %v", err)
+		}
+	}()
+
+	if *runController {
+		controller := scheduler.NewSchedulerSelectorController(client, selector, *controllerInterval)
+		if *leaderElect {
+			err = utils.RunWithLeaderElection(ctx, client, utils.LeaderElectionConfig{
+				LockName:      *lockName,
+				LockNamespace: *lockNamespace,
+			}, controller.Run)
+			if err != nil {
+				klog.Errorf("Leader election failed: %v", err)
+			}
+		} else {
+			controller.Run(ctx)
+		}
+	} else {
+		<-ctx.Done()
+	}
+
+	klog.Info("Shutting down admission webhook...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		klog.Errorf("Admission webhook shutdown error: %v", err)
+	}
+}
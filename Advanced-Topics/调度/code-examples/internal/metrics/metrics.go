@@ -97,6 +97,11 @@ func (mc *MetricsCollector) SetHealthStatus(component string, healthy bool) {
 	mc.HealthStatus.WithLabelValues(component).Set(value)
 }
 
+// MustRegister registers additional collectors with the collector's registry
+func (mc *MetricsCollector) MustRegister(collectors ...prometheus.Collector) {
+	mc.registry.MustRegister(collectors...)
+}
+
 // Handler returns the HTTP handler for metrics
 func (mc *MetricsCollector) Handler() http.Handler {
 	return promhttp.HandlerFor(mc.registry, promhttp.HandlerOpts{})
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// LeaderElectionConfig holds leader election settings for controllers
+type LeaderElectionConfig struct {
+	LockNamespace string        `yaml:"lock_namespace" json:"lock_namespace"`
+	LockName      string        `yaml:"lock_name" json:"lock_name"`
+	Identity      string        `yaml:"identity" json:"identity"`
+	LeaseDuration time.Duration `yaml:"lease_duration" json:"lease_duration"`
+	RenewDeadline time.Duration `yaml:"renew_deadline" json:"renew_deadline"`
+	RetryPeriod   time.Duration `yaml:"retry_period" json:"retry_period"`
+}
+
+// RunWithLeaderElection runs fn only while holding the lease and returns when ctx is cancelled.
+// fn receives a context that is cancelled when leadership is lost.
+func RunWithLeaderElection(ctx context.Context, client kubernetes.Interface, config LeaderElectionConfig, fn func(ctx context.Context)) error {
+	if config.LockName == "" {
+		return fmt.Errorf("leader election lock name is required")
+	}
+	if config.LockNamespace == "" {
+		config.LockNamespace = GetEnvOrDefault("POD_NAMESPACE", metav1.NamespaceSystem)
+	}
+	if config.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("failed to determine leader election identity: %v", err)
+		}
+		config.Identity = hostname
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = 15 * time.Second
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = 10 * time.Second
+	}
+	if config.RetryPeriod == 0 {
+		config.RetryPeriod = 2 * time.Second
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LockName,
+			Namespace: config.LockNamespace,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.Identity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   config.LeaseDuration,
+		RenewDeadline:   config.RenewDeadline,
+		RetryPeriod:     config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            config.LockName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s acquired leadership of %s/%s", config.Identity, config.LockNamespace, config.LockName)
+				fn(ctx)
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("%s lost leadership of %s/%s", config.Identity, config.LockNamespace, config.LockName)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != config.Identity {
+					klog.Infof("Current leader of %s/%s is %s", config.LockNamespace, config.LockName, identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %v", err)
+	}
+
+	// Rejoin the election after losing leadership until ctx is cancelled
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// LeaderLeaseOptions identifies a leader election Lease and the pods competing for it
+type LeaderLeaseOptions struct {
+	Namespace         string        // defaults to kube-system
+	LeaseName         string        // defaults to kube-scheduler
+	CandidateSelector string        // label selector of the candidate pods, defaults to component=kube-scheduler
+	Grace             time.Duration // extra time past renewTime+leaseDuration before the lease counts as stale, defaults to 5s
+}
+
+// LeaderLeaseState is a point-in-time view of a leader Lease and its candidates
+type LeaderLeaseState struct {
+	Holder            string        // holderIdentity, empty when nobody holds the lease
+	HolderPod         string        // pod that owns Holder, empty when it no longer exists
+	HolderReady       bool          // HolderPod is running, ready and not terminating
+	Stale             bool          // the holder stopped renewing the lease
+	SinceRenew        time.Duration // time since the last renewal
+	HealthyCandidates []string      // ready candidate pods other than the holder
+
+	lease *coordinationv1.Lease
+}
+
+func (o LeaderLeaseOptions) withDefaults() LeaderLeaseOptions {
+	if o.Namespace == "" {
+		o.Namespace = metav1.NamespaceSystem
+	}
+	if o.LeaseName == "" {
+		o.LeaseName = "kube-scheduler"
+	}
+	if o.CandidateSelector == "" {
+		o.CandidateSelector = "component=kube-scheduler"
+	}
+	if o.Grace == 0 {
+		o.Grace = 5 * time.Second
+	}
+	return o
+}
+
+// InspectLeaderLease reads the leader Lease and matches its holder against the candidate pods.
+// Holder identities in the client-go format "<hostname>_<uuid>" are matched by pod name, or by
+// node name for host-network pods such as static kube-scheduler pods.
+func InspectLeaderLease(ctx context.Context, client kubernetes.Interface, opts LeaderLeaseOptions) (*LeaderLeaseState, error) {
+	opts = opts.withDefaults()
+
+	lease, err := client.CoordinationV1().Leases(opts.Namespace).Get(ctx, opts.LeaseName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lease %s/%s: %v", opts.Namespace, opts.LeaseName, err)
+	}
+	pods, err := client.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: opts.CandidateSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leader candidates: %v", err)
+	}
+
+	state := &LeaderLeaseState{lease: lease}
+	if lease.Spec.HolderIdentity != nil {
+		state.Holder = *lease.Spec.HolderIdentity
+	}
+
+	if state.Holder != "" {
+		duration := 15 * time.Second
+		if lease.Spec.LeaseDurationSeconds != nil {
+			duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+		}
+		if lease.Spec.RenewTime != nil {
+			state.SinceRenew = time.Since(lease.Spec.RenewTime.Time)
+		}
+		state.Stale = lease.Spec.RenewTime == nil || state.SinceRenew > duration+opts.Grace
+	}
+
+	hostname := state.Holder
+	if i := strings.LastIndex(hostname, "_"); i > 0 {
+		hostname = hostname[:i]
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		isHolder := state.Holder != "" &&
+			(pod.Name == hostname || (pod.Spec.HostNetwork && pod.Spec.NodeName == hostname))
+		switch {
+		case isHolder:
+			state.HolderPod = pod.Name
+			state.HolderReady = IsPodServing(pod)
+		case IsPodServing(pod):
+			state.HealthyCandidates = append(state.HealthyCandidates, pod.Name)
+		}
+	}
+	return state, nil
+}
+
+// ForceLeaderHandover clears the holder of a stale leader Lease so that a healthy candidate
+// takes over at its next retry instead of waiting for the old holder to come back.
+// It only clears the lease when the holder stopped renewing, its pod is gone or unready, and
+// at least one other candidate is ready. The update is guarded by the lease resourceVersion,
+// so a holder that renews concurrently keeps its lease.
+// It returns whether the lease was cleared together with a message describing the outcome.
+// A lease that has nothing to hand over (unheld, healthy, stale with a ready holder, or without
+// a ready candidate) is left alone and is not an error, so callers can move on to other actions.
+func ForceLeaderHandover(ctx context.Context, client kubernetes.Interface, opts LeaderLeaseOptions) (bool, string, error) {
+	opts = opts.withDefaults()
+
+	state, err := InspectLeaderLease(ctx, client, opts)
+	if err != nil {
+		return false, "", err
+	}
+
+	var skipped string
+	switch {
+	case state.Holder == "":
+		skipped = fmt.Sprintf("lease %s/%s has no holder, nothing to hand over", opts.Namespace, opts.LeaseName)
+	case !state.Stale:
+		skipped = fmt.Sprintf("lease %s/%s is held by %s and was renewed %v ago, leaving it alone",
+			opts.Namespace, opts.LeaseName, state.Holder, state.SinceRenew.Round(time.Second))
+	case state.HolderReady:
+		skipped = fmt.Sprintf("lease %s/%s is stale but holder pod %s is still ready; restart it instead of forcing a handover",
+			opts.Namespace, opts.LeaseName, state.HolderPod)
+	case len(state.HealthyCandidates) == 0:
+		skipped = fmt.Sprintf("lease %s/%s is stale but no ready candidate matches %q to take over",
+			opts.Namespace, opts.LeaseName, opts.CandidateSelector)
+	}
+	if skipped != "" {
+		klog.Info(skipped)
+		return false, skipped, nil
+	}
+
+	lease := state.lease.DeepCopy()
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	if _, err := client.CoordinationV1().Leases(opts.Namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if errors.IsConflict(err) {
+			return false, "", fmt.Errorf("lease %s/%s changed while handing over, not retrying: %v", opts.Namespace, opts.LeaseName, err)
+		}
+		return false, "", fmt.Errorf("failed to clear lease %s/%s: %v", opts.Namespace, opts.LeaseName, err)
+	}
+
+	holderPod := state.HolderPod
+	if holderPod == "" {
+		holderPod = "<gone>"
+	}
+	message := fmt.Sprintf("cleared stale lease %s/%s held by %s (pod %s, last renewed %v ago); ready candidates: %v",
+		opts.Namespace, opts.LeaseName, state.Holder, holderPod, state.SinceRenew.Round(time.Second), state.HealthyCandidates)
+	klog.Info(message)
+	return true, message, nil
+}
+
+// IsPodServing reports whether the pod is running, ready and not terminating
+func IsPodServing(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -14,8 +14,8 @@ import (
 	"k8s.io/klog/v2"
 )
 
-// GetKubernetesClient creates a Kubernetes client
-func GetKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
+// GetKubernetesConfig builds a REST config from kubeconfig, in-cluster config or ~/.kube/config
+func GetKubernetesConfig(kubeconfig string) (*rest.Config, error) {
 	var config *rest.Config
 	var err error
 
@@ -39,6 +39,16 @@ func GetKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
 		return nil, fmt.Errorf("failed to create kubernetes config: %v", err)
 	}
 
+	return config, nil
+}
+
+// GetKubernetesClient creates a Kubernetes client
+func GetKubernetesClient(kubeconfig string) (kubernetes.Interface, error) {
+	config, err := GetKubernetesConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %v", err)
@@ -1,23 +1,20 @@
 // health-recovery.go
+// 兼容入口 - 健康检查、告警和策略恢复均委托给pkg/scheduler中的实现
 package recovery
 
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"time"
 
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/kubernetes-fundamentals/pkg/scheduler"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
 
-// HealthChecker 健康检查器
+// HealthChecker 健康检查器，委托给scheduler.HealthChecker
 type HealthChecker struct {
-	checks   []HealthCheck
-	interval time.Duration
-	alerts   AlertManager
+	*scheduler.HealthChecker
 }
 
 // HealthCheck 健康检查定义
@@ -30,105 +27,37 @@ type HealthCheck struct {
 }
 
 // HealthStatus 健康状态
-type HealthStatus struct {
-	Name      string
-	Healthy   bool
-	LastCheck time.Time
-	Error     error
-}
+type HealthStatus = scheduler.HealthStatus
 
-// NewHealthChecker 创建健康检查器
+// NewHealthChecker 创建健康检查器，未设置Interval的检查使用interval
 func NewHealthChecker(checks []HealthCheck, interval time.Duration) *HealthChecker {
-	return &HealthChecker{
-		checks:   checks,
-		interval: interval,
-		alerts:   NewAlertManager(),
-	}
-}
-
-// Start 启动健康检查
-func (hc *HealthChecker) Start(ctx context.Context) {
-	ticker := time.NewTicker(hc.interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			hc.runHealthChecks(ctx)
-		}
-	}
-}
-
-// runHealthChecks 执行健康检查
-func (hc *HealthChecker) runHealthChecks(ctx context.Context) {
-	for _, check := range hc.checks {
-		go func(c HealthCheck) {
-			status := hc.performCheck(ctx, c)
-			hc.handleHealthStatus(status)
-		}(check)
-	}
-}
-
-// performCheck 执行单个健康检查
-func (hc *HealthChecker) performCheck(ctx context.Context, check HealthCheck) HealthStatus {
-	status := HealthStatus{
-		Name:      check.Name,
-		LastCheck: time.Now(),
-	}
-
-	client := &http.Client{
-		Timeout: check.Timeout,
-	}
-
-	var lastErr error
-	for i := 0; i <= check.Retries; i++ {
-		req, err := http.NewRequestWithContext(ctx, "GET", check.Endpoint, nil)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			lastErr = err
-			continue
+	converted := make([]scheduler.HealthCheck, 0, len(checks))
+	for _, check := range checks {
+		if check.Interval == 0 {
+			check.Interval = interval
 		}
-
-		resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			status.Healthy = true
-			return status
-		}
-
-		lastErr = fmt.Errorf("health check failed with status: %d", resp.StatusCode)
+		converted = append(converted, scheduler.HealthCheck{
+			Name:     check.Name,
+			URL:      check.Endpoint,
+			Interval: check.Interval,
+			Timeout:  check.Timeout,
+			Retries:  check.Retries,
+		})
 	}
-
-	status.Healthy = false
-	status.Error = lastErr
-	return status
+	alerts := NewAlertManager()
+	return &HealthChecker{HealthChecker: scheduler.NewHealthChecker(converted, alerts.AlertManager)}
 }
 
-// handleHealthStatus 处理健康状态
-func (hc *HealthChecker) handleHealthStatus(status HealthStatus) {
-	if !status.Healthy {
-		klog.Errorf("Health check failed for %s: %v", status.Name, status.Error)
-		hc.alerts.TriggerAlert(AlertEvent{
-			Name:      fmt.Sprintf("%sUnhealthy", status.Name),
-			Severity:  "critical",
-			Message:   fmt.Sprintf("Health check failed for %s: %v", status.Name, status.Error),
-			Timestamp: status.LastCheck,
-		})
-	} else {
-		klog.V(2).Infof("Health check passed for %s", status.Name)
-	}
+// Start 启动健康检查，直到ctx结束
+func (hc *HealthChecker) Start(ctx context.Context) {
+	hc.HealthChecker.Start()
+	<-ctx.Done()
+	hc.HealthChecker.Stop()
 }
 
-// AlertManager 告警管理器
+// AlertManager 告警管理器，委托给scheduler.AlertManager
 type AlertManager struct {
-	// 实现告警逻辑
+	*scheduler.AlertManager
 }
 
 // AlertEvent 告警事件
@@ -139,25 +68,31 @@ type AlertEvent struct {
 	Timestamp time.Time
 }
 
-// NewAlertManager 创建告警管理器
+// NewAlertManager 创建只记录日志的告警管理器
 func NewAlertManager() AlertManager {
-	return AlertManager{}
+	return AlertManager{AlertManager: scheduler.NewAlertManager("")}
 }
 
 // TriggerAlert 触发告警
 func (am *AlertManager) TriggerAlert(event AlertEvent) {
-	// 实现告警逻辑，如发送到 Prometheus Alertmanager
-	klog.Warningf("Alert triggered: %s - %s", event.Name, event.Message)
+	err := am.SendAlert(scheduler.AlertEvent{
+		Type:      event.Severity,
+		Message:   event.Message,
+		Timestamp: event.Timestamp,
+		Labels:    map[string]string{"alertname": event.Name},
+	})
+	if err != nil {
+		klog.Errorf("Failed to send alert %s: %v", event.Name, err)
+	}
 }
 
-// RecoveryManager 故障恢复管理器
+// RecoveryManager 故障恢复管理器，策略转换为scheduler.RecoveryPolicy后由scheduler.RecoveryManager执行
 type RecoveryManager struct {
-	client    kubernetes.Interface
-	policies  []RecoveryPolicy
+	manager   *scheduler.RecoveryManager
 	escalator *EscalationManager
 }
 
-// RecoveryPolicy 恢复策略
+// RecoveryPolicy 恢复策略，任一触发条件持续满足Duration时执行动作
 type RecoveryPolicy struct {
 	Name     string
 	Triggers []Trigger
@@ -166,13 +101,13 @@ type RecoveryPolicy struct {
 
 // Trigger 触发条件
 type Trigger struct {
-	Condition string
+	Condition string // scheduler-api == false、leader-election == false或pending-pods > 100
 	Duration  time.Duration
 }
 
 // Action 恢复动作
 type Action struct {
-	Type       string
+	Type       string // restart-pod、force-leader-election或reschedule-pods
 	Target     string
 	MaxRetries int
 	Backoff    string
@@ -180,255 +115,118 @@ type Action struct {
 	MaxPods    int
 }
 
-// NewRecoveryManager 创建恢复管理器
+// NewRecoveryManager 创建恢复管理器，无法转换的触发条件和动作记录日志后忽略
 func NewRecoveryManager(client kubernetes.Interface, policies []RecoveryPolicy) *RecoveryManager {
-	return &RecoveryManager{
-		client:    client,
-		policies:  policies,
+	rm := &RecoveryManager{
+		manager:   scheduler.NewRecoveryManager(client, convertPolicies(policies), nil),
 		escalator: NewEscalationManager(),
 	}
+	rm.manager.SetIncidentManager(rm.escalator.IncidentManager)
+	return rm
 }
 
-// Start 启动恢复管理器
+// Start 启动恢复管理器，直到ctx结束
 func (rm *RecoveryManager) Start(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			rm.checkAndRecover(ctx)
-		}
-	}
-}
-
-// checkAndRecover 检查并执行恢复
-func (rm *RecoveryManager) checkAndRecover(ctx context.Context) {
-	for _, policy := range rm.policies {
-		if rm.shouldTriggerRecovery(ctx, policy) {
-			klog.Infof("Triggering recovery policy: %s", policy.Name)
-			rm.executeRecovery(ctx, policy)
+	rm.escalator.Start()
+	rm.manager.Start()
+	<-ctx.Done()
+	rm.manager.Stop()
+	rm.escalator.Stop()
+}
+
+// GetRecoveryStatus 获取恢复状态
+func (rm *RecoveryManager) GetRecoveryStatus() map[string]interface{} {
+	return rm.manager.GetRecoveryStatus()
+}
+
+// convertPolicies 转换恢复策略
+// scheduler.RecoveryPolicy要求所有触发条件同时满足，这里的触发条件为任一满足，因此每个触发条件转换为一个策略
+func convertPolicies(policies []RecoveryPolicy) []scheduler.RecoveryPolicy {
+	var converted []scheduler.RecoveryPolicy
+	for _, policy := range policies {
+		var actions []scheduler.Action
+		for _, action := range policy.Actions {
+			convertedAction, err := convertAction(action)
+			if err != nil {
+				klog.Warningf("Recovery policy %s: %v", policy.Name, err)
+				continue
+			}
+			actions = append(actions, convertedAction)
 		}
-	}
-}
 
-// shouldTriggerRecovery 检查是否应该触发恢复
-func (rm *RecoveryManager) shouldTriggerRecovery(ctx context.Context, policy RecoveryPolicy) bool {
-	for _, trigger := range policy.Triggers {
-		if rm.evaluateCondition(ctx, trigger.Condition) {
-			// 检查条件持续时间
-			if rm.escalator.ShouldEscalate(policy.Name, trigger.Duration) {
-				return true
+		for i, trigger := range policy.Triggers {
+			convertedTrigger, err := convertTrigger(trigger)
+			if err != nil {
+				klog.Warningf("Recovery policy %s: %v", policy.Name, err)
+				continue
+			}
+			name := policy.Name
+			if len(policy.Triggers) > 1 {
+				name = fmt.Sprintf("%s/%d", policy.Name, i)
 			}
+			converted = append(converted, scheduler.RecoveryPolicy{
+				Name:     name,
+				Triggers: []scheduler.Trigger{convertedTrigger},
+				Actions:  actions,
+			})
 		}
 	}
-	return false
+	return converted
 }
 
-// evaluateCondition 评估触发条件
-func (rm *RecoveryManager) evaluateCondition(ctx context.Context, condition string) bool {
-	switch condition {
+// convertTrigger 将条件表达式转换为触发条件类型
+func convertTrigger(trigger Trigger) (scheduler.Trigger, error) {
+	converted := scheduler.Trigger{Duration: trigger.Duration}
+	switch trigger.Condition {
 	case "scheduler-api == false":
-		return !rm.checkSchedulerAPI(ctx)
+		converted.Type = scheduler.TriggerAPIUnavailable
 	case "leader-election == false":
-		return !rm.checkLeaderElection(ctx)
+		converted.Type = scheduler.TriggerLeaderElectionFailed
 	case "pending-pods > 100":
-		return rm.getPendingPodsCount(ctx) > 100
+		converted.Type = scheduler.TriggerPendingPodsHigh
+		converted.Threshold = 100
 	default:
-		return false
+		return converted, fmt.Errorf("unsupported trigger condition %q", trigger.Condition)
 	}
+	return converted, nil
 }
 
-// executeRecovery 执行恢复动作
-func (rm *RecoveryManager) executeRecovery(ctx context.Context, policy RecoveryPolicy) {
-	for _, action := range policy.Actions {
-		if err := rm.executeAction(ctx, action); err != nil {
-			klog.Errorf("Failed to execute recovery action %s: %v", action.Type, err)
-		} else {
-			klog.Infof("Successfully executed recovery action: %s", action.Type)
-		}
-	}
-}
-
-// executeAction 执行单个恢复动作
-func (rm *RecoveryManager) executeAction(ctx context.Context, action Action) error {
+// convertAction 将动作转换为已注册的恢复动作，Target为调度组件名称
+func convertAction(action Action) (scheduler.Action, error) {
 	switch action.Type {
 	case "restart-pod":
-		return rm.restartPod(ctx, action.Target)
+		return scheduler.Action{Type: scheduler.ActionRestartPod, Parameters: map[string]interface{}{
+			"labelSelector": fmt.Sprintf("component=%s", action.Target),
+		}}, nil
 	case "force-leader-election":
-		return rm.forceLeaderElection(ctx, action.Target)
+		return scheduler.Action{Type: scheduler.ActionForceLeaderElection, Parameters: map[string]interface{}{
+			"resourceName":  action.Target,
+			"labelSelector": fmt.Sprintf("component=%s", action.Target),
+		}}, nil
 	case "reschedule-pods":
-		return rm.reschedulePods(ctx, action.Filter, action.MaxPods)
-	default:
-		return fmt.Errorf("unknown action type: %s", action.Type)
-	}
-}
-
-// restartPod 重启 Pod
-func (rm *RecoveryManager) restartPod(ctx context.Context, target string) error {
-	// 获取调度器 Pod
-	pods, err := rm.client.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
-		LabelSelector: fmt.Sprintf("component=%s", target),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list pods: %v", err)
-	}
-
-	if len(pods.Items) == 0 {
-		return fmt.Errorf("no pods found for target: %s", target)
-	}
-
-	// 删除 Pod 以触发重启
-	for _, pod := range pods.Items {
-		err := rm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-		if err != nil {
-			klog.Errorf("Failed to delete pod %s/%s: %v", pod.Namespace, pod.Name, err)
-		} else {
-			klog.Infof("Deleted pod %s/%s for restart", pod.Namespace, pod.Name)
-		}
-	}
-
-	return nil
-}
-
-// forceLeaderElection 强制重新选举
-func (rm *RecoveryManager) forceLeaderElection(ctx context.Context, target string) error {
-	// 删除 leader election lease 以触发重新选举
-	err := rm.client.CoordinationV1().Leases("kube-system").Delete(ctx, target, metav1.DeleteOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to delete leader election lease: %v", err)
-	}
-
-	klog.Infof("Deleted leader election lease for %s", target)
-	return nil
-}
-
-// reschedulePods 重新调度 Pod
-func (rm *RecoveryManager) reschedulePods(ctx context.Context, filter string, maxPods int) error {
-	// 获取待调度的 Pod
-	pods, err := rm.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "status.phase=Pending",
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list pending pods: %v", err)
-	}
-
-	count := 0
-	for _, pod := range pods.Items {
-		if count >= maxPods {
-			break
-		}
-
-		// 检查 Pod 是否卡住（超过 5 分钟未调度）
-		if time.Since(pod.CreationTimestamp.Time) > 5*time.Minute {
-			// 删除并重新创建 Pod
-			if err := rm.recreatePod(ctx, &pod); err != nil {
-				klog.Errorf("Failed to recreate pod %s/%s: %v", pod.Namespace, pod.Name, err)
-			} else {
-				count++
-				klog.Infof("Recreated stuck pod %s/%s", pod.Namespace, pod.Name)
-			}
+		params := map[string]interface{}{}
+		if action.MaxPods > 0 {
+			params["maxPods"] = float64(action.MaxPods)
 		}
+		return scheduler.Action{Type: scheduler.ActionReschedulePods, Parameters: params}, nil
 	}
-
-	return nil
+	return scheduler.Action{}, fmt.Errorf("unknown action type: %s", action.Type)
 }
 
-// recreatePod 重新创建 Pod
-func (rm *RecoveryManager) recreatePod(ctx context.Context, pod *corev1.Pod) error {
-	// 创建新的 Pod 对象
-	newPod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        pod.Name + "-recovered",
-			Namespace:   pod.Namespace,
-			Labels:      pod.Labels,
-			Annotations: pod.Annotations,
-		},
-		Spec: pod.Spec,
-	}
-
-	// 清除调度相关字段
-	newPod.Spec.NodeName = ""
-	newPod.Spec.SchedulerName = ""
-
-	// 先创建新 Pod，避免服务中断
-	createdPod, err := rm.client.CoreV1().Pods(newPod.Namespace).Create(ctx, newPod, metav1.CreateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create new pod: %v", err)
-	}
-
-	// 等待新 Pod 开始调度
-	time.Sleep(5 * time.Second)
-
-	// 删除原 Pod
-	err = rm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-	if err != nil {
-		// 如果删除失败，尝试删除新创建的 Pod 以避免重复
-		rm.client.CoreV1().Pods(createdPod.Namespace).Delete(ctx, createdPod.Name, metav1.DeleteOptions{})
-		return fmt.Errorf("failed to delete original pod: %v", err)
-	}
-
-	return nil
-}
-
-// 辅助方法
-func (rm *RecoveryManager) checkSchedulerAPI(ctx context.Context) bool {
-	// 实现调度器 API 健康检查
-	return true
-}
-
-func (rm *RecoveryManager) checkLeaderElection(ctx context.Context) bool {
-	// 实现 leader election 检查
-	return true
-}
-
-func (rm *RecoveryManager) getPendingPodsCount(ctx context.Context) int {
-	pods, err := rm.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "status.phase=Pending",
-	})
-	if err != nil {
-		return 0
-	}
-	return len(pods.Items)
-}
-
-// EscalationManager 升级管理器
+// EscalationManager 升级管理器，基于scheduler.IncidentManager管理事件的打开、升级和解决
 type EscalationManager struct {
-	incidents map[string]*Incident
-}
-
-// Incident 事件记录
-type Incident struct {
-	Name      string
-	StartTime time.Time
-	Level     int
+	*scheduler.IncidentManager
 }
 
-// NewEscalationManager 创建升级管理器
+// NewEscalationManager 创建升级管理器，只通知日志接收器
 func NewEscalationManager() *EscalationManager {
-	return &EscalationManager{
-		incidents: make(map[string]*Incident),
-	}
+	// 空配置不会校验失败
+	incidents, _ := scheduler.NewIncidentManager(scheduler.RecoveryEscalation{})
+	return &EscalationManager{IncidentManager: incidents}
 }
 
-// ShouldEscalate 检查是否应该升级
+// ShouldEscalate 条件成立时打开事件，事件打开超过duration时返回true
 func (em *EscalationManager) ShouldEscalate(name string, duration time.Duration) bool {
-	incident, exists := em.incidents[name]
-	if !exists {
-		em.incidents[name] = &Incident{
-			Name:      name,
-			StartTime: time.Now(),
-			Level:     1,
-		}
-		return true
-	}
-
-	if time.Since(incident.StartTime) > duration {
-		incident.Level++
-		return true
-	}
-
-	return false
+	incident := em.Open(name, fmt.Sprintf("Recovery policy %s triggered", name), scheduler.AlertCritical, map[string]string{"policy": name})
+	return time.Since(incident.OpenedAt) >= duration
 }
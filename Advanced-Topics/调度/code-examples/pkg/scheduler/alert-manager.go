@@ -0,0 +1,1006 @@
+// alert-manager.go
+// 告警管理器 - 告警指纹去重、按标签分组、重复通知间隔、静默以及多接收器投递
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// 告警类型
+const (
+	AlertCritical = "critical"
+	AlertWarning  = "warning"
+	AlertResolved = "resolved"
+)
+
+// 告警接收器类型
+const (
+	AlertReceiverWebhook      = "webhook"
+	AlertReceiverSlack        = "slack"
+	AlertReceiverAlertmanager = "alertmanager"
+	AlertReceiverLog          = "log"   // 只写日志，不需要url
+	AlertReceiverPager        = "pager" // PagerDuty Events API v2兼容的寻呼接收器
+)
+
+// GroupByAll 按告警的全部标签分组，即每个告警单独通知
+const GroupByAll = "..."
+
+// minAlertRetryInterval 投递失败后再次发送分组通知的最小间隔，GroupInterval更短或为0时使用
+const minAlertRetryInterval = 30 * time.Second
+
+// AlertEvent 告警事件，标签相同的事件视为同一告警
+type AlertEvent struct {
+	Type      string            `json:"type"`
+	Message   string            `json:"message"`
+	Timestamp time.Time         `json:"timestamp"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// Fingerprint 告警指纹，由排序后的标签计算
+func (e AlertEvent) Fingerprint() string {
+	labels := e.Labels
+	if len(labels) == 0 {
+		labels = map[string]string{"message": e.Message}
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s\xff%s\xff", key, labels[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// AlertRoute 告警路由，Matchers全部匹配的告警发送到Receiver
+type AlertRoute struct {
+	Receiver       string            `json:"receiver"`
+	Matchers       map[string]string `json:"matchers,omitempty"`
+	GroupBy        []string          `json:"groupBy,omitempty"`        // 分组标签，GroupByAll表示每个告警单独成组，为空时所有告警合为一组
+	GroupWait      metav1.Duration   `json:"groupWait,omitempty"`      // 新分组第一次通知前的等待时间，0表示立即发送
+	GroupInterval  metav1.Duration   `json:"groupInterval,omitempty"`  // 分组有新变化时两次通知的最小间隔，默认等于GroupWait
+	RepeatInterval metav1.Duration   `json:"repeatInterval,omitempty"` // 告警持续未恢复时重复通知的间隔，默认4小时
+}
+
+// AlertReceiverConfig 告警接收器配置
+type AlertReceiverConfig struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // webhook、slack、alertmanager、log、pager
+	URL        string `json:"url"`
+	Channel    string `json:"channel,omitempty"`    // 仅slack
+	RoutingKey string `json:"routingKey,omitempty"` // 仅pager
+}
+
+// AlertManagerConfig 告警管理器配置
+type AlertManagerConfig struct {
+	Receivers []AlertReceiver
+	Routes    []AlertRoute // 按顺序使用第一个匹配的路由
+	Default   AlertRoute   // 没有路由匹配时使用，Receiver为空时使用第一个接收器
+	Backoff   wait.Backoff // 投递失败时的重试退避，Steps为0时使用默认值
+}
+
+// Silence 静默，生效期间Matchers全部匹配的告警不发送通知
+type Silence struct {
+	ID        string            `json:"id"`
+	Matchers  map[string]string `json:"matchers"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    time.Time         `json:"endsAt"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	Comment   string            `json:"comment,omitempty"`
+}
+
+// active 判断静默在now时是否生效
+func (s Silence) active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// AlertNotification 一次分组通知
+type AlertNotification struct {
+	Receiver    string            `json:"receiver"`
+	GroupKey    string            `json:"groupKey"`
+	GroupLabels map[string]string `json:"groupLabels"`
+	Status      string            `json:"status"` // 有未恢复告警时为firing，否则为resolved
+	Alerts      []NotifiedAlert   `json:"alerts"`
+
+	// RepeatInterval 未恢复告警的重复通知间隔，接收器据此判断告警多久没有再次通知就可以视为过期
+	RepeatInterval time.Duration `json:"-"`
+}
+
+// NotifiedAlert 通知中的单个告警
+type NotifiedAlert struct {
+	Fingerprint string            `json:"fingerprint"`
+	Status      string            `json:"status"` // firing或resolved
+	Severity    string            `json:"severity"`
+	Message     string            `json:"message"`
+	Labels      map[string]string `json:"labels"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt,omitempty"`
+}
+
+// AlertReceiver 告警接收器
+type AlertReceiver interface {
+	Name() string
+	Send(ctx context.Context, notification *AlertNotification) error
+}
+
+// AlertManager 告警管理器
+type AlertManager struct {
+	receivers map[string]AlertReceiver
+	routes    []AlertRoute
+	fallback  AlertRoute
+	backoff   wait.Backoff
+
+	mu       sync.Mutex
+	groups   map[string]*alertGroup
+	silences map[string]Silence
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// alertGroup 同一路由下分组标签相同的告警
+type alertGroup struct {
+	key          string
+	route        AlertRoute
+	labels       map[string]string
+	alerts       map[string]*trackedAlert
+	timer        *time.Timer
+	lastFlush    time.Time
+	lastNotified time.Time
+	retryAt      time.Time // 投递失败后下一次允许发送的时间
+	sending      bool
+}
+
+// trackedAlert 分组中的告警及其通知状态
+type trackedAlert struct {
+	event      AlertEvent
+	startsAt   time.Time
+	endsAt     time.Time
+	severity   string // 最近一次触发时的告警类型
+	resolved   bool
+	generation int64 // 告警状态每次变化加1
+	notified   int64 // 已成功通知的generation
+}
+
+// NewAlertManager 创建发送到单个webhook的告警管理器，webhookURL为空时只记录日志
+// 每个告警单独成组并立即发送，相同告警4小时内不重复通知
+func NewAlertManager(webhookURL string) *AlertManager {
+	config := AlertManagerConfig{Default: AlertRoute{GroupBy: []string{GroupByAll}}}
+	if webhookURL != "" {
+		config.Receivers = []AlertReceiver{NewWebhookReceiver("default", webhookURL, nil)}
+	}
+	return NewAlertManagerWithConfig(config)
+}
+
+// NewAlertManagerWithConfig 使用路由和接收器配置创建告警管理器
+func NewAlertManagerWithConfig(config AlertManagerConfig) *AlertManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	am := &AlertManager{
+		receivers: make(map[string]AlertReceiver),
+		routes:    config.Routes,
+		fallback:  config.Default,
+		backoff:   config.Backoff,
+		groups:    make(map[string]*alertGroup),
+		silences:  make(map[string]Silence),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for _, receiver := range config.Receivers {
+		am.receivers[receiver.Name()] = receiver
+	}
+	if am.fallback.Receiver == "" && len(config.Receivers) > 0 {
+		am.fallback.Receiver = config.Receivers[0].Name()
+	}
+	if am.backoff.Steps == 0 {
+		am.backoff = wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: 4, Cap: 30 * time.Second}
+	}
+	return am
+}
+
+// NewAlertManagerFromConfig 根据scheduler-health-config中的alerting配置创建告警管理器
+// webhookUrl为兼容旧配置的单个webhook接收器；cooldown作为默认的重复通知间隔
+func NewAlertManagerFromConfig(config HealthAlertingConfig) (*AlertManager, error) {
+	if !config.Enabled {
+		return NewAlertManager(""), nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var receivers []AlertReceiver
+	names := make(map[string]bool)
+	if config.WebhookURL != "" {
+		receivers = append(receivers, NewWebhookReceiver("default", config.WebhookURL, client))
+		names["default"] = true
+	}
+	for _, receiverConfig := range config.Receivers {
+		receiver, err := NewAlertReceiver(receiverConfig, client)
+		if err != nil {
+			return nil, err
+		}
+		names[receiver.Name()] = true
+		receivers = append(receivers, receiver)
+	}
+
+	route := config.Route
+	if len(route.GroupBy) == 0 {
+		route.GroupBy = []string{GroupByAll}
+	}
+	if route.RepeatInterval.Duration == 0 {
+		route.RepeatInterval = config.Cooldown
+	}
+	for _, r := range append([]AlertRoute{route}, config.Routes...) {
+		if r.Receiver != "" && !names[r.Receiver] {
+			return nil, fmt.Errorf("alert route references unknown receiver %q", r.Receiver)
+		}
+	}
+
+	return NewAlertManagerWithConfig(AlertManagerConfig{
+		Receivers: receivers,
+		Routes:    config.Routes,
+		Default:   route,
+	}), nil
+}
+
+// Stop 停止所有待发送的分组通知
+func (am *AlertManager) Stop() {
+	am.cancel()
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	for _, group := range am.groups {
+		if group.timer != nil {
+			group.timer.Stop()
+		}
+	}
+}
+
+// SendAlert 提交告警事件
+// 与上次状态相同的事件被去重；分组等待时间为0时同步发送并返回投递错误，否则在分组等待后异步发送
+func (am *AlertManager) SendAlert(alert AlertEvent) error {
+	if len(am.receivers) == 0 {
+		klog.V(2).Infof("Alert: %s - %s", alert.Type, alert.Message)
+		return nil
+	}
+	if alert.Timestamp.IsZero() {
+		alert.Timestamp = time.Now()
+	}
+
+	am.mu.Lock()
+	route := am.route(alert.Labels)
+	group := am.group(route, alert.Labels)
+	if !group.receive(alert) {
+		am.mu.Unlock()
+		klog.V(4).Infof("Alert %s deduplicated: %s", alert.Fingerprint(), alert.Message)
+		return nil
+	}
+	delay := group.nextFlush(time.Now())
+	if delay > 0 || group.sending {
+		am.schedule(group, delay)
+		am.mu.Unlock()
+		return nil
+	}
+	am.mu.Unlock()
+
+	return am.flushGroup(am.ctx, group.key, false)
+}
+
+// Flush 立即发送所有有变化的分组，忽略分组等待时间
+func (am *AlertManager) Flush(ctx context.Context) error {
+	am.mu.Lock()
+	keys := make([]string, 0, len(am.groups))
+	for key := range am.groups {
+		keys = append(keys, key)
+	}
+	am.mu.Unlock()
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if err := am.flushGroup(ctx, key, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AddSilence 添加静默，ID为空时自动生成，StartsAt为空时立即生效
+func (am *AlertManager) AddSilence(silence Silence) (string, error) {
+	if len(silence.Matchers) == 0 {
+		return "", fmt.Errorf("silence must have at least one matcher")
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return "", fmt.Errorf("silence must end after it starts")
+	}
+	if silence.ID == "" {
+		silence.ID = utilrand.String(10)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.silences[silence.ID] = silence
+	klog.Infof("Added silence %s until %s for %v", silence.ID, silence.EndsAt.Format(time.RFC3339), silence.Matchers)
+	return silence.ID, nil
+}
+
+// ExpireSilence 立即结束静默，被静默的告警在所在分组下次通知时发出
+func (am *AlertManager) ExpireSilence(id string) bool {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if _, exists := am.silences[id]; !exists {
+		return false
+	}
+	delete(am.silences, id)
+	return true
+}
+
+// Silences 返回生效中和尚未开始的静默，同时清理已过期的静默
+func (am *AlertManager) Silences() []Silence {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now()
+	var silences []Silence
+	for id, silence := range am.silences {
+		if !now.Before(silence.EndsAt) {
+			delete(am.silences, id)
+			continue
+		}
+		silences = append(silences, silence)
+	}
+	sort.Slice(silences, func(i, j int) bool { return silences[i].EndsAt.Before(silences[j].EndsAt) })
+	return silences
+}
+
+// IsSilenced 判断带有这些标签的告警当前是否被静默
+func (am *AlertManager) IsSilenced(labels map[string]string) bool {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	return am.silenced(labels, time.Now())
+}
+
+func (am *AlertManager) silenced(labels map[string]string, now time.Time) bool {
+	for _, silence := range am.silences {
+		if silence.active(now) && labelsMatch(labels, silence.Matchers) {
+			return true
+		}
+	}
+	return false
+}
+
+// route 返回第一个匹配的路由，路由未指定接收器时使用默认路由的接收器
+func (am *AlertManager) route(labels map[string]string) AlertRoute {
+	for _, route := range am.routes {
+		if labelsMatch(labels, route.Matchers) {
+			if route.Receiver == "" {
+				route.Receiver = am.fallback.Receiver
+			}
+			return route
+		}
+	}
+	return am.fallback
+}
+
+// group 返回告警所在的分组，不存在时创建
+func (am *AlertManager) group(route AlertRoute, labels map[string]string) *alertGroup {
+	groupLabels := make(map[string]string)
+	for _, name := range route.GroupBy {
+		if name == GroupByAll {
+			for key, value := range labels {
+				groupLabels[key] = value
+			}
+			break
+		}
+		if value, exists := labels[name]; exists {
+			groupLabels[name] = value
+		}
+	}
+	key := route.Receiver + ":" + AlertEvent{Labels: groupLabels}.Fingerprint()
+
+	group, exists := am.groups[key]
+	if !exists {
+		group = &alertGroup{
+			key:    key,
+			route:  route,
+			labels: groupLabels,
+			alerts: make(map[string]*trackedAlert),
+		}
+		am.groups[key] = group
+	}
+	return group
+}
+
+// schedule 在delay后发送分组通知，替换分组已有的计划
+func (am *AlertManager) schedule(group *alertGroup, delay time.Duration) {
+	if group.timer != nil {
+		group.timer.Stop()
+	}
+	key := group.key
+	group.timer = time.AfterFunc(delay, func() {
+		if err := am.flushGroup(am.ctx, key, true); err != nil {
+			klog.Errorf("Failed to send alert group %s: %v", key, err)
+		}
+	})
+}
+
+// flushGroup 发送分组通知并根据结果安排下一次通知
+// repeat为true时即使没有变化，超过重复间隔也再次发送未恢复的告警
+func (am *AlertManager) flushGroup(ctx context.Context, key string, repeat bool) error {
+	am.mu.Lock()
+	group, exists := am.groups[key]
+	if !exists || group.sending {
+		am.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	notification, sent := group.notification(now, repeat, func(labels map[string]string) bool {
+		return am.silenced(labels, now)
+	})
+	group.lastFlush = now
+	if notification == nil {
+		am.reschedule(group, now)
+		am.mu.Unlock()
+		return nil
+	}
+	group.sending = true
+	receiver := am.receivers[group.route.Receiver]
+	am.mu.Unlock()
+
+	var err error
+	if receiver == nil {
+		err = fmt.Errorf("unknown alert receiver %q", group.route.Receiver)
+	} else {
+		err = am.deliver(ctx, receiver, notification)
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	group.sending = false
+	switch {
+	case err == nil:
+		group.lastNotified = now
+		group.retryAt = time.Time{}
+		group.markNotified(sent)
+		klog.V(2).Infof("Sent %d alerts (%s) to receiver %s", len(notification.Alerts), notification.Status, receiver.Name())
+	case receiver == nil || permanentDeliveryError(err):
+		// 接收端拒绝了请求，重试也不会成功；丢弃这次通知，告警再次变化或到达重复间隔时才重新发送
+		group.lastNotified = now
+		group.markNotified(sent)
+		klog.Errorf("Dropping %d alerts for receiver %s: %v", len(notification.Alerts), group.route.Receiver, err)
+	default:
+		group.retryAt = now.Add(group.retryInterval())
+	}
+	am.reschedule(group, now)
+	return err
+}
+
+// reschedule 安排分组的下一次通知，分组为空时删除
+// 被静默的告警不算作待发送的变化，只在重复通知时随分组重新检查
+func (am *AlertManager) reschedule(group *alertGroup, now time.Time) {
+	if len(group.alerts) == 0 {
+		if group.timer != nil {
+			group.timer.Stop()
+		}
+		delete(am.groups, group.key)
+		return
+	}
+	if am.ctx.Err() != nil {
+		return
+	}
+	if group.pending(func(labels map[string]string) bool { return am.silenced(labels, now) }) {
+		am.schedule(group, group.nextFlush(now))
+		return
+	}
+	am.schedule(group, group.repeatInterval())
+}
+
+// deliver 发送通知，网络错误、429和5xx响应按退避策略重试
+func (am *AlertManager) deliver(ctx context.Context, receiver AlertReceiver, notification *AlertNotification) error {
+	return deliverNotification(ctx, am.backoff, receiver, notification)
+}
+
+// deliverNotification 发送通知，网络错误、429和5xx响应按backoff重试
+func deliverNotification(ctx context.Context, backoff wait.Backoff, receiver AlertReceiver, notification *AlertNotification) error {
+	return retry.OnError(backoff, func(err error) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		var deliveryErr *alertDeliveryError
+		if errors.As(err, &deliveryErr) {
+			return deliveryErr.retriable()
+		}
+		return true
+	}, func() error {
+		err := receiver.Send(ctx, notification)
+		if err != nil {
+			klog.V(2).Infof("Alert delivery to %s failed: %v", receiver.Name(), err)
+		}
+		return err
+	})
+}
+
+// receive 记录告警事件，状态没有变化时返回false
+func (g *alertGroup) receive(event AlertEvent) bool {
+	fingerprint := event.Fingerprint()
+	resolved := event.Type == AlertResolved
+	alert, exists := g.alerts[fingerprint]
+	if !exists {
+		if resolved {
+			// 从未触发过的告警无需发送恢复通知
+			return false
+		}
+		g.alerts[fingerprint] = &trackedAlert{event: event, severity: event.Type, startsAt: event.Timestamp, generation: 1}
+		return true
+	}
+
+	if alert.resolved == resolved && alert.event.Type == event.Type {
+		alert.event.Message = event.Message
+		return false
+	}
+	if !resolved && alert.resolved {
+		alert.startsAt = event.Timestamp
+		alert.endsAt = time.Time{}
+	}
+	if resolved {
+		alert.endsAt = event.Timestamp
+	} else {
+		alert.severity = event.Type
+	}
+	alert.event = event
+	alert.resolved = resolved
+	alert.generation++
+	return true
+}
+
+// pending 判断分组是否有尚未通知且未被静默的变化
+func (g *alertGroup) pending(silenced func(map[string]string) bool) bool {
+	for _, alert := range g.alerts {
+		if alert.notified != alert.generation && !silenced(alert.event.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextFlush 返回距离下一次允许发送的时间：新分组等待GroupWait，已发送过的分组间隔GroupInterval，
+// 投递失败后至少等到retryAt
+func (g *alertGroup) nextFlush(now time.Time) time.Duration {
+	var delay time.Duration
+	if g.lastFlush.IsZero() {
+		delay = g.route.GroupWait.Duration
+	} else {
+		delay = g.lastFlush.Add(g.groupInterval()).Sub(now)
+	}
+	if wait := g.retryAt.Sub(now); wait > delay {
+		delay = wait
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// groupInterval 分组两次通知的最小间隔，未设置时等于GroupWait
+func (g *alertGroup) groupInterval() time.Duration {
+	if g.route.GroupInterval.Duration > 0 {
+		return g.route.GroupInterval.Duration
+	}
+	return g.route.GroupWait.Duration
+}
+
+// retryInterval 投递失败后的重新发送间隔，不小于minAlertRetryInterval
+func (g *alertGroup) retryInterval() time.Duration {
+	if interval := g.groupInterval(); interval > minAlertRetryInterval {
+		return interval
+	}
+	return minAlertRetryInterval
+}
+
+func (g *alertGroup) repeatInterval() time.Duration {
+	if g.route.RepeatInterval.Duration > 0 {
+		return g.route.RepeatInterval.Duration
+	}
+	return 4 * time.Hour
+}
+
+// notification 生成分组通知，返回通知中各告警的generation；没有需要发送的内容时返回nil
+func (g *alertGroup) notification(now time.Time, repeat bool, silenced func(map[string]string) bool) (*AlertNotification, map[string]int64) {
+	due := repeat && now.Sub(g.lastNotified) >= g.repeatInterval()
+	notification := &AlertNotification{
+		Receiver:       g.route.Receiver,
+		GroupKey:       g.key,
+		GroupLabels:    g.labels,
+		Status:         "resolved",
+		RepeatInterval: g.repeatInterval(),
+	}
+	sent := make(map[string]int64)
+	changed := false
+
+	fingerprints := make([]string, 0, len(g.alerts))
+	for fingerprint := range g.alerts {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	for _, fingerprint := range fingerprints {
+		alert := g.alerts[fingerprint]
+		if silenced(alert.event.Labels) {
+			continue
+		}
+		if alert.resolved && alert.notified == alert.generation {
+			continue
+		}
+		if alert.notified != alert.generation {
+			changed = true
+		}
+
+		notified := NotifiedAlert{
+			Fingerprint: fingerprint,
+			Status:      "firing",
+			Severity:    alert.severity,
+			Message:     alert.event.Message,
+			Labels:      alert.event.Labels,
+			StartsAt:    alert.startsAt,
+		}
+		if alert.resolved {
+			notified.Status = "resolved"
+			notified.EndsAt = alert.endsAt
+		} else {
+			notification.Status = "firing"
+		}
+		notification.Alerts = append(notification.Alerts, notified)
+		sent[fingerprint] = alert.generation
+	}
+
+	if len(notification.Alerts) == 0 || (!changed && !due) {
+		return nil, nil
+	}
+	return notification, sent
+}
+
+// markNotified 标记已发送的告警，删除已通知恢复的告警
+func (g *alertGroup) markNotified(sent map[string]int64) {
+	for fingerprint, generation := range sent {
+		alert, exists := g.alerts[fingerprint]
+		if !exists || alert.generation != generation {
+			continue
+		}
+		alert.notified = generation
+		if alert.resolved {
+			delete(g.alerts, fingerprint)
+		}
+	}
+}
+
+// labelsMatch 判断labels是否包含matchers中的所有键值
+func labelsMatch(labels, matchers map[string]string) bool {
+	for key, value := range matchers {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// alertDeliveryError 接收端返回的非成功状态码
+type alertDeliveryError struct {
+	receiver   string
+	statusCode int
+}
+
+func (e *alertDeliveryError) Error() string {
+	return fmt.Sprintf("alert receiver %s returned status %d", e.receiver, e.statusCode)
+}
+
+// retriable 429和5xx可重试，其余4xx说明请求本身有问题
+func (e *alertDeliveryError) retriable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+}
+
+// permanentDeliveryError 判断投递错误是否为接收端拒绝请求的4xx，重新发送相同内容不会成功
+func permanentDeliveryError(err error) bool {
+	var deliveryErr *alertDeliveryError
+	return errors.As(err, &deliveryErr) && !deliveryErr.retriable()
+}
+
+// NewAlertReceiver 根据配置创建告警接收器
+func NewAlertReceiver(config AlertReceiverConfig, client *http.Client) (AlertReceiver, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("alert receiver name is required")
+	}
+	if config.Type == AlertReceiverLog {
+		return NewLogReceiver(config.Name), nil
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("alert receiver %s: url is required", config.Name)
+	}
+
+	switch config.Type {
+	case "", AlertReceiverWebhook:
+		return NewWebhookReceiver(config.Name, config.URL, client), nil
+	case AlertReceiverSlack:
+		return &SlackReceiver{name: config.Name, url: config.URL, channel: config.Channel, client: defaultAlertClient(client)}, nil
+	case AlertReceiverAlertmanager:
+		return NewAlertmanagerReceiver(config.Name, config.URL, client), nil
+	case AlertReceiverPager:
+		if config.RoutingKey == "" {
+			return nil, fmt.Errorf("alert receiver %s: routingKey is required", config.Name)
+		}
+		return &PagerReceiver{name: config.Name, url: config.URL, routingKey: config.RoutingKey, client: defaultAlertClient(client)}, nil
+	}
+	return nil, fmt.Errorf("alert receiver %s: unknown type %q", config.Name, config.Type)
+}
+
+func defaultAlertClient(client *http.Client) *http.Client {
+	if client == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return client
+}
+
+// postJSON 以JSON发送payload，非2xx响应返回alertDeliveryError
+func postJSON(ctx context.Context, client *http.Client, receiver, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert to %s: %v", receiver, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &alertDeliveryError{receiver: receiver, statusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// WebhookReceiver 通用webhook接收器，请求体为AlertNotification
+type WebhookReceiver struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewWebhookReceiver 创建webhook接收器，client为空时使用10秒超时的默认客户端
+func NewWebhookReceiver(name, url string, client *http.Client) *WebhookReceiver {
+	return &WebhookReceiver{name: name, url: url, client: defaultAlertClient(client)}
+}
+
+// Name 实现AlertReceiver
+func (r *WebhookReceiver) Name() string { return r.name }
+
+// Send 实现AlertReceiver
+func (r *WebhookReceiver) Send(ctx context.Context, notification *AlertNotification) error {
+	return postJSON(ctx, r.client, r.name, r.url, notification)
+}
+
+// SlackReceiver Slack兼容的incoming webhook接收器
+type SlackReceiver struct {
+	name    string
+	url     string
+	channel string
+	client  *http.Client
+}
+
+// slackMessage Slack incoming webhook消息
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string `json:"color"`
+	Title  string `json:"title"`
+	Text   string `json:"text"`
+	Footer string `json:"footer,omitempty"`
+}
+
+// Name 实现AlertReceiver
+func (r *SlackReceiver) Name() string { return r.name }
+
+// Send 实现AlertReceiver，每个告警一个附件，按严重程度着色
+func (r *SlackReceiver) Send(ctx context.Context, notification *AlertNotification) error {
+	firing := 0
+	for _, alert := range notification.Alerts {
+		if alert.Status == "firing" {
+			firing++
+		}
+	}
+	message := slackMessage{
+		Channel: r.channel,
+		Text:    fmt.Sprintf("[%s:%d] %s", strings.ToUpper(notification.Status), firing, formatLabels(notification.GroupLabels)),
+	}
+	for _, alert := range notification.Alerts {
+		color := "warning"
+		switch {
+		case alert.Status == "resolved":
+			color = "good"
+		case alert.Severity == AlertCritical:
+			color = "danger"
+		}
+		message.Attachments = append(message.Attachments, slackAttachment{
+			Color:  color,
+			Title:  fmt.Sprintf("[%s] %s", alert.Status, alert.Severity),
+			Text:   alert.Message,
+			Footer: formatLabels(alert.Labels),
+		})
+	}
+	return postJSON(ctx, r.client, r.name, r.url, message)
+}
+
+// AlertmanagerReceiver 通过v2 API将告警推送到Prometheus Alertmanager
+type AlertmanagerReceiver struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewAlertmanagerReceiver 创建Alertmanager接收器，url为Alertmanager地址，如http://alertmanager.monitoring:9093
+func NewAlertmanagerReceiver(name, url string, client *http.Client) *AlertmanagerReceiver {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), "/api/v2/alerts")
+	return &AlertmanagerReceiver{name: name, url: url + "/api/v2/alerts", client: defaultAlertClient(client)}
+}
+
+// postableAlert Alertmanager v2 API的告警格式
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt,omitempty"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// Name 实现AlertReceiver
+func (r *AlertmanagerReceiver) Name() string { return r.name }
+
+// Send 实现AlertReceiver，由Alertmanager负责后续的分组和通知
+// 恢复的告警带实际的endsAt；未恢复的告警按重复通知间隔的3倍设置endsAt，
+// 每次重复通知都会延后该时间，本服务停止发送后Alertmanager在到期时自动恢复，而不是按resolve_timeout反复翻转
+func (r *AlertmanagerReceiver) Send(ctx context.Context, notification *AlertNotification) error {
+	repeat := notification.RepeatInterval
+	if repeat <= 0 {
+		repeat = 4 * time.Hour
+	}
+	firingUntil := time.Now().Add(3 * repeat)
+
+	alerts := make([]postableAlert, 0, len(notification.Alerts))
+	for _, alert := range notification.Alerts {
+		labels := make(map[string]string, len(alert.Labels)+2)
+		for key, value := range alert.Labels {
+			labels[key] = value
+		}
+		if labels["alertname"] == "" {
+			labels["alertname"] = "SchedulerAlert"
+		}
+		if labels["severity"] == "" {
+			labels["severity"] = alert.Severity
+		}
+
+		postable := postableAlert{
+			Labels:      labels,
+			Annotations: map[string]string{"summary": alert.Message},
+			StartsAt:    alert.StartsAt,
+		}
+		if alert.Status == "resolved" {
+			endsAt := alert.EndsAt
+			postable.EndsAt = &endsAt
+		} else {
+			postable.EndsAt = &firingUntil
+		}
+		alerts = append(alerts, postable)
+	}
+	return postJSON(ctx, r.client, r.name, r.url, alerts)
+}
+
+// LogReceiver 只把通知写入日志的接收器
+type LogReceiver struct {
+	name string
+}
+
+// NewLogReceiver 创建日志接收器
+func NewLogReceiver(name string) *LogReceiver {
+	return &LogReceiver{name: name}
+}
+
+// Name 实现AlertReceiver
+func (r *LogReceiver) Name() string { return r.name }
+
+// Send 实现AlertReceiver
+func (r *LogReceiver) Send(ctx context.Context, notification *AlertNotification) error {
+	for _, alert := range notification.Alerts {
+		klog.Warningf("[%s] %s %s: %s (%s)", r.name, alert.Status, alert.Severity, alert.Message, formatLabels(alert.Labels))
+	}
+	return nil
+}
+
+// PagerReceiver 以PagerDuty Events API v2格式发送寻呼，每个告警一个事件，
+// 以告警指纹作为dedup_key，恢复的告警发送resolve事件
+type PagerReceiver struct {
+	name       string
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// pagerEvent Events API v2的事件格式
+type pagerEvent struct {
+	RoutingKey  string        `json:"routing_key"`
+	EventAction string        `json:"event_action"` // trigger或resolve
+	DedupKey    string        `json:"dedup_key"`
+	Payload     *pagerPayload `json:"payload,omitempty"`
+}
+
+type pagerPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"` // critical、error、warning或info
+	Timestamp     time.Time         `json:"timestamp"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Name 实现AlertReceiver
+func (r *PagerReceiver) Name() string { return r.name }
+
+// Send 实现AlertReceiver
+func (r *PagerReceiver) Send(ctx context.Context, notification *AlertNotification) error {
+	for _, alert := range notification.Alerts {
+		event := pagerEvent{RoutingKey: r.routingKey, EventAction: "trigger", DedupKey: alert.Fingerprint}
+		if alert.Status == "resolved" {
+			event.EventAction = "resolve"
+		} else {
+			severity := alert.Severity
+			if severity != AlertCritical {
+				severity = AlertWarning
+			}
+			event.Payload = &pagerPayload{
+				Summary:       alert.Message,
+				Source:        "scheduler-recovery",
+				Severity:      severity,
+				Timestamp:     alert.StartsAt,
+				CustomDetails: alert.Labels,
+			}
+		}
+		if err := postJSON(ctx, r.client, r.name, r.url, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatLabels 以key=value形式输出排序后的标签
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,351 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// alertReceiverServer 记录收到的请求，按statuses依次返回状态码，用完后一直返回最后一个
+type alertReceiverServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	paths    []string
+	bodies   [][]byte
+}
+
+func newAlertReceiverServer(t *testing.T, statuses ...int) *alertReceiverServer {
+	t.Helper()
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusOK}
+	}
+	s := &alertReceiverServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		status := s.statuses[min(len(s.bodies), len(s.statuses)-1)]
+		s.paths = append(s.paths, r.URL.Path)
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *alertReceiverServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// decode 把第i个请求体解析到out
+func (s *alertReceiverServer) decode(t *testing.T, i int, out interface{}) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i >= len(s.bodies) {
+		t.Fatalf("expected at least %d requests, got %d", i+1, len(s.bodies))
+	}
+	if err := json.Unmarshal(s.bodies[i], out); err != nil {
+		t.Fatalf("failed to decode request %d: %v", i, err)
+	}
+}
+
+// waitForRequests 等待收到n个请求
+func (s *alertReceiverServer) waitForRequests(t *testing.T, n int) {
+	t.Helper()
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 2*time.Second, true, func(context.Context) (bool, error) {
+		return s.count() >= n, nil
+	})
+	if err != nil {
+		t.Fatalf("expected %d requests, got %d", n, s.count())
+	}
+}
+
+// newTestAlertManager 创建发送到单个接收器的告警管理器，重试退避缩短到毫秒级
+func newTestAlertManager(t *testing.T, receiver AlertReceiver, route AlertRoute) *AlertManager {
+	t.Helper()
+	route.Receiver = receiver.Name()
+	am := NewAlertManagerWithConfig(AlertManagerConfig{
+		Receivers: []AlertReceiver{receiver},
+		Default:   route,
+		Backoff:   wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3},
+	})
+	t.Cleanup(am.Stop)
+	return am
+}
+
+func testAlert(alertType, check string) AlertEvent {
+	return AlertEvent{
+		Type:    alertType,
+		Message: check + " is unhealthy",
+		Labels:  map[string]string{"check": check, "component": "scheduler"},
+	}
+}
+
+func TestAlertManagerDeduplicatesUnchangedAlerts(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{GroupBy: []string{GroupByAll}})
+
+	for i := 0; i < 3; i++ {
+		if err := am.SendAlert(testAlert(AlertCritical, "lease")); err != nil {
+			t.Fatalf("SendAlert failed: %v", err)
+		}
+	}
+	if got := server.count(); got != 1 {
+		t.Fatalf("expected duplicate alerts to be sent once, got %d requests", got)
+	}
+
+	if err := am.SendAlert(testAlert(AlertResolved, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if got := server.count(); got != 2 {
+		t.Fatalf("expected a resolve notification, got %d requests", got)
+	}
+	var notification AlertNotification
+	server.decode(t, 1, &notification)
+	if notification.Status != "resolved" || len(notification.Alerts) != 1 || notification.Alerts[0].EndsAt.IsZero() {
+		t.Fatalf("unexpected resolve notification: %+v", notification)
+	}
+
+	// 从未触发过的告警恢复时不发送
+	if err := am.SendAlert(testAlert(AlertResolved, "other")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if got := server.count(); got != 2 {
+		t.Fatalf("expected no notification for an alert that never fired, got %d requests", got)
+	}
+}
+
+func TestAlertManagerGroupsAlertsByLabels(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{
+		GroupBy:   []string{"component"},
+		GroupWait: metav1.Duration{Duration: 100 * time.Millisecond},
+	})
+
+	for _, check := range []string{"lease", "queue"} {
+		if err := am.SendAlert(testAlert(AlertWarning, check)); err != nil {
+			t.Fatalf("SendAlert failed: %v", err)
+		}
+	}
+	if got := server.count(); got != 0 {
+		t.Fatalf("expected no notification before group wait, got %d requests", got)
+	}
+
+	server.waitForRequests(t, 1)
+	var notification AlertNotification
+	server.decode(t, 0, &notification)
+	if len(notification.Alerts) != 2 {
+		t.Fatalf("expected both alerts in one notification, got %d", len(notification.Alerts))
+	}
+	if notification.Status != "firing" || notification.GroupLabels["component"] != "scheduler" || len(notification.GroupLabels) != 1 {
+		t.Fatalf("unexpected group notification: %+v", notification)
+	}
+}
+
+func TestAlertManagerRetriesServerErrors(t *testing.T) {
+	server := newAlertReceiverServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{GroupBy: []string{GroupByAll}})
+
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err != nil {
+		t.Fatalf("expected delivery to succeed after retries: %v", err)
+	}
+	if got := server.count(); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestAlertManagerBacksOffAfterFailedFlush(t *testing.T) {
+	server := newAlertReceiverServer(t, http.StatusInternalServerError)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{GroupBy: []string{"component"}})
+
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	attempts := server.count()
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts within one flush, got %d", attempts)
+	}
+
+	// 分组等待和间隔为0时，失败后的下一次发送仍要等待minAlertRetryInterval
+	if err := am.SendAlert(testAlert(AlertCritical, "queue")); err != nil {
+		t.Fatalf("expected the new alert to be queued behind the retry delay: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := server.count(); got != attempts {
+		t.Fatalf("expected no deliveries during the retry delay, got %d more", got-attempts)
+	}
+}
+
+func TestAlertManagerDropsPermanentFailures(t *testing.T) {
+	server := newAlertReceiverServer(t, http.StatusNotFound)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{GroupBy: []string{GroupByAll}})
+
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := server.count(); got != 1 {
+		t.Fatalf("expected a single attempt for a 404 receiver, got %d", got)
+	}
+
+	// 丢弃后相同告警不再重发，直到状态变化
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if got := server.count(); got != 1 {
+		t.Fatalf("expected dropped alert not to be resent, got %d requests", got)
+	}
+}
+
+func TestAlertManagerSilences(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	am := newTestAlertManager(t, NewWebhookReceiver("webhook", server.URL, nil), AlertRoute{GroupBy: []string{GroupByAll}})
+
+	id, err := am.AddSilence(Silence{
+		Matchers: map[string]string{"check": "lease"},
+		EndsAt:   time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("AddSilence failed: %v", err)
+	}
+	if !am.IsSilenced(map[string]string{"check": "lease", "component": "scheduler"}) {
+		t.Fatal("expected labels to be silenced")
+	}
+
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	if err := am.SendAlert(testAlert(AlertCritical, "queue")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := server.count(); got != 1 {
+		t.Fatalf("expected only the unsilenced alert to be sent without retrying the silenced one, got %d requests", got)
+	}
+	var notification AlertNotification
+	server.decode(t, 0, &notification)
+	if notification.Alerts[0].Labels["check"] != "queue" {
+		t.Fatalf("expected the unsilenced alert, got %+v", notification.Alerts[0])
+	}
+
+	if !am.ExpireSilence(id) {
+		t.Fatal("expected silence to be expired")
+	}
+	if err := am.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got := server.count(); got != 2 {
+		t.Fatalf("expected the previously silenced alert after expiry, got %d requests", got)
+	}
+	server.decode(t, 1, &notification)
+	if notification.Alerts[0].Labels["check"] != "lease" {
+		t.Fatalf("expected the previously silenced alert, got %+v", notification.Alerts[0])
+	}
+}
+
+func TestSlackReceiverPayload(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	receiver, err := NewAlertReceiver(AlertReceiverConfig{Name: "slack", Type: AlertReceiverSlack, URL: server.URL, Channel: "#oncall"}, nil)
+	if err != nil {
+		t.Fatalf("NewAlertReceiver failed: %v", err)
+	}
+	am := newTestAlertManager(t, receiver, AlertRoute{GroupBy: []string{GroupByAll}})
+
+	if err := am.SendAlert(testAlert(AlertCritical, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	var message slackMessage
+	server.decode(t, 0, &message)
+	if message.Channel != "#oncall" || len(message.Attachments) != 1 {
+		t.Fatalf("unexpected slack message: %+v", message)
+	}
+	if attachment := message.Attachments[0]; attachment.Color != "danger" || attachment.Text != "lease is unhealthy" {
+		t.Fatalf("unexpected slack attachment: %+v", attachment)
+	}
+
+	if err := am.SendAlert(testAlert(AlertResolved, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	server.decode(t, 1, &message)
+	if message.Attachments[0].Color != "good" {
+		t.Fatalf("expected resolved attachment to be green, got %+v", message.Attachments[0])
+	}
+}
+
+func TestAlertmanagerReceiverPayload(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	am := newTestAlertManager(t, NewAlertmanagerReceiver("alertmanager", server.URL+"/", nil), AlertRoute{
+		GroupBy:        []string{GroupByAll},
+		RepeatInterval: metav1.Duration{Duration: 5 * time.Minute},
+	})
+
+	if err := am.SendAlert(testAlert(AlertWarning, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	var alerts []postableAlert
+	server.decode(t, 0, &alerts)
+	if server.paths[0] != "/api/v2/alerts" {
+		t.Fatalf("unexpected alertmanager path %s", server.paths[0])
+	}
+	if len(alerts) != 1 || alerts[0].Labels["alertname"] != "SchedulerAlert" || alerts[0].Labels["severity"] != AlertWarning {
+		t.Fatalf("unexpected alertmanager alerts: %+v", alerts)
+	}
+	// 未恢复告警的endsAt必须晚于下一次重复通知，否则Alertmanager会在两次通知之间将其恢复
+	if alerts[0].EndsAt == nil || time.Until(*alerts[0].EndsAt) <= 5*time.Minute {
+		t.Fatalf("expected firing alert to end after the next repeat, got %v", alerts[0].EndsAt)
+	}
+
+	resolved := testAlert(AlertResolved, "lease")
+	resolved.Timestamp = time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := am.SendAlert(resolved); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	server.decode(t, 1, &alerts)
+	if alerts[0].EndsAt == nil || !alerts[0].EndsAt.Equal(resolved.Timestamp) {
+		t.Fatalf("expected resolved alert to end at %v, got %v", resolved.Timestamp, alerts[0].EndsAt)
+	}
+}
+
+func TestPagerReceiverPayload(t *testing.T) {
+	server := newAlertReceiverServer(t)
+	receiver, err := NewAlertReceiver(AlertReceiverConfig{Name: "pager", Type: AlertReceiverPager, URL: server.URL, RoutingKey: "key"}, nil)
+	if err != nil {
+		t.Fatalf("NewAlertReceiver failed: %v", err)
+	}
+	am := newTestAlertManager(t, receiver, AlertRoute{GroupBy: []string{GroupByAll}})
+
+	alert := testAlert(AlertCritical, "lease")
+	if err := am.SendAlert(alert); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	var event pagerEvent
+	server.decode(t, 0, &event)
+	if event.EventAction != "trigger" || event.RoutingKey != "key" || event.DedupKey != alert.Fingerprint() {
+		t.Fatalf("unexpected trigger event: %+v", event)
+	}
+	if event.Payload == nil || event.Payload.Severity != AlertCritical || event.Payload.Summary != alert.Message {
+		t.Fatalf("unexpected trigger payload: %+v", event.Payload)
+	}
+
+	if err := am.SendAlert(testAlert(AlertResolved, "lease")); err != nil {
+		t.Fatalf("SendAlert failed: %v", err)
+	}
+	var resolve pagerEvent
+	server.decode(t, 1, &resolve)
+	if resolve.EventAction != "resolve" || resolve.DedupKey != alert.Fingerprint() || resolve.Payload != nil {
+		t.Fatalf("unexpected resolve event: %+v", resolve)
+	}
+}
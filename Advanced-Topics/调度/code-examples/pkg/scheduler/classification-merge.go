@@ -0,0 +1,349 @@
+// classification-merge.go
+// 分类结果合并 - 以非破坏方式将工作负载配置文件的调度提示合并到Pod上，并记录新增内容以便审计和回滚
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// WorkloadTypeLabel 工作负载类型标签
+	WorkloadTypeLabel = "workload.kubernetes.io/type"
+	// ClassificationAnnotation 记录分类器对Pod所做修改的注解，值为ClassificationRecord的JSON
+	ClassificationAnnotation = "workload.kubernetes.io/classification"
+
+	// ClassificationSourceStatic 基于Pod静态规格的分类
+	ClassificationSourceStatic = "static"
+	// ClassificationSourceUsage 基于历史资源使用的分类
+	ClassificationSourceUsage = "usage"
+)
+
+// ClassificationRecord 分类器修改记录
+// 只记录分类器实际新增的内容，用户原有的约束不会出现在记录中
+type ClassificationRecord struct {
+	WorkloadType string `json:"workloadType"`
+	// 分类来源（"static"或"usage"）、置信度和分类依据
+	Source     string  `json:"source,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	// 分类器设置的调度器以及设置前的值，未修改调度器时为空
+	Scheduler         string `json:"scheduler,omitempty"`
+	PreviousScheduler string `json:"previousScheduler,omitempty"`
+	// 分类器新增的标签
+	Labels map[string]string `json:"labels,omitempty"`
+	// 节点亲和性新增内容
+	NodeAffinity *NodeAffinityAddition `json:"nodeAffinity,omitempty"`
+	// Pod反亲和性新增内容
+	PodAntiAffinity *PodAntiAffinityAddition `json:"podAntiAffinity,omitempty"`
+	// 新增的容忍度和拓扑分布约束
+	Tolerations    []v1.Toleration               `json:"tolerations,omitempty"`
+	TopologySpread []v1.TopologySpreadConstraint `json:"topologySpread,omitempty"`
+}
+
+// NodeAffinityAddition 节点亲和性新增内容
+type NodeAffinityAddition struct {
+	// Pod原本没有必需约束时，分类器新建的节点选择器项
+	Terms []v1.NodeSelectorTerm `json:"terms,omitempty"`
+	// 合并进用户已有节点选择器项的表达式，键为选择器项下标
+	TermRequirements map[int][]v1.NodeSelectorRequirement `json:"termRequirements,omitempty"`
+	// 新增的偏好调度项
+	Preferred []v1.PreferredSchedulingTerm `json:"preferred,omitempty"`
+}
+
+// PodAntiAffinityAddition Pod反亲和性新增内容
+type PodAntiAffinityAddition struct {
+	Required  []v1.PodAffinityTerm         `json:"required,omitempty"`
+	Preferred []v1.WeightedPodAffinityTerm `json:"preferred,omitempty"`
+}
+
+// mergeSchedulingHints 将配置文件的调度提示合并到Pod上
+// 用户已指定的调度器、标签和约束保持不变，重复的容忍度和拓扑分布约束会被跳过
+func mergeSchedulingHints(pod *v1.Pod, profile *WorkloadProfile) *ClassificationRecord {
+	record := &ClassificationRecord{WorkloadType: profile.Type}
+	hints := profile.SchedulingHints
+
+	// 仅在用户未指定调度器（或使用默认调度器）时设置
+	if hints.PreferredScheduler != "" && pod.Spec.SchedulerName != hints.PreferredScheduler &&
+		(pod.Spec.SchedulerName == "" || pod.Spec.SchedulerName == v1.DefaultSchedulerName) {
+		record.Scheduler = hints.PreferredScheduler
+		record.PreviousScheduler = pod.Spec.SchedulerName
+		pod.Spec.SchedulerName = hints.PreferredScheduler
+	}
+
+	// 标签已存在时不覆盖
+	if pod.Labels == nil {
+		pod.Labels = make(map[string]string)
+	}
+	for key, value := range map[string]string{
+		WorkloadTypeLabel:     profile.Type,
+		WorkloadPriorityLabel: profile.Priority,
+	} {
+		if _, exists := pod.Labels[key]; exists || value == "" {
+			continue
+		}
+		pod.Labels[key] = value
+		if record.Labels == nil {
+			record.Labels = make(map[string]string)
+		}
+		record.Labels[key] = value
+	}
+
+	if hints.NodeAffinity != nil {
+		if pod.Spec.Affinity == nil {
+			pod.Spec.Affinity = &v1.Affinity{}
+		}
+		if pod.Spec.Affinity.NodeAffinity == nil {
+			pod.Spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+		}
+		record.NodeAffinity = mergeNodeAffinity(pod.Spec.Affinity.NodeAffinity, hints.NodeAffinity)
+	}
+
+	if hints.PodAntiAffinity != nil {
+		if pod.Spec.Affinity == nil {
+			pod.Spec.Affinity = &v1.Affinity{}
+		}
+		if pod.Spec.Affinity.PodAntiAffinity == nil {
+			pod.Spec.Affinity.PodAntiAffinity = &v1.PodAntiAffinity{}
+		}
+		record.PodAntiAffinity = mergePodAntiAffinity(pod.Spec.Affinity.PodAntiAffinity, hints.PodAntiAffinity)
+	}
+
+	// 容忍度去重：键、操作符、值和效果都相同视为重复
+	for _, toleration := range hints.Tolerations {
+		if hasToleration(pod.Spec.Tolerations, toleration) {
+			continue
+		}
+		pod.Spec.Tolerations = append(pod.Spec.Tolerations, toleration)
+		record.Tolerations = append(record.Tolerations, toleration)
+	}
+
+	// 拓扑分布约束去重：同一拓扑键和不满足策略只允许一条，用户已有的优先
+	for _, constraint := range hints.TopologySpread {
+		if hasTopologySpread(pod.Spec.TopologySpreadConstraints, constraint) {
+			continue
+		}
+		pod.Spec.TopologySpreadConstraints = append(pod.Spec.TopologySpreadConstraints, constraint)
+		record.TopologySpread = append(record.TopologySpread, constraint)
+	}
+
+	pruneEmptyAffinity(pod)
+	return record
+}
+
+// mergeNodeAffinity 合并节点亲和性
+// 必需约束采用"与"语义：配置文件的表达式追加到用户的每一个选择器项中，不会放宽用户约束
+func mergeNodeAffinity(target, hint *v1.NodeAffinity) *NodeAffinityAddition {
+	added := &NodeAffinityAddition{}
+
+	if required := hint.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && len(required.NodeSelectorTerms) > 0 {
+		switch {
+		case target.RequiredDuringSchedulingIgnoredDuringExecution == nil ||
+			len(target.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) == 0:
+			target.RequiredDuringSchedulingIgnoredDuringExecution = required.DeepCopy()
+			added.Terms = required.DeepCopy().NodeSelectorTerms
+		case len(required.NodeSelectorTerms) == 1:
+			hintTerm := required.NodeSelectorTerms[0]
+			terms := target.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			for i := range terms {
+				for _, req := range hintTerm.MatchExpressions {
+					if containsSemantic(terms[i].MatchExpressions, req) {
+						continue
+					}
+					terms[i].MatchExpressions = append(terms[i].MatchExpressions, *req.DeepCopy())
+					if added.TermRequirements == nil {
+						added.TermRequirements = make(map[int][]v1.NodeSelectorRequirement)
+					}
+					added.TermRequirements[i] = append(added.TermRequirements[i], *req.DeepCopy())
+				}
+			}
+		default:
+			// 多个"或"项与用户约束求交会产生笛卡尔积，无法可靠回滚，保留用户约束
+			klog.Warningf("Skipping required node affinity merge: profile has %d node selector terms and pod already defines its own",
+				len(required.NodeSelectorTerms))
+		}
+	}
+
+	for _, term := range hint.PreferredDuringSchedulingIgnoredDuringExecution {
+		if containsSemantic(target.PreferredDuringSchedulingIgnoredDuringExecution, term) {
+			continue
+		}
+		target.PreferredDuringSchedulingIgnoredDuringExecution = append(target.PreferredDuringSchedulingIgnoredDuringExecution, *term.DeepCopy())
+		added.Preferred = append(added.Preferred, *term.DeepCopy())
+	}
+
+	if len(added.Terms) == 0 && len(added.TermRequirements) == 0 && len(added.Preferred) == 0 {
+		return nil
+	}
+	return added
+}
+
+// mergePodAntiAffinity 合并Pod反亲和性，只追加用户尚未定义的项
+func mergePodAntiAffinity(target, hint *v1.PodAntiAffinity) *PodAntiAffinityAddition {
+	added := &PodAntiAffinityAddition{}
+
+	for _, term := range hint.RequiredDuringSchedulingIgnoredDuringExecution {
+		if containsSemantic(target.RequiredDuringSchedulingIgnoredDuringExecution, term) {
+			continue
+		}
+		target.RequiredDuringSchedulingIgnoredDuringExecution = append(target.RequiredDuringSchedulingIgnoredDuringExecution, *term.DeepCopy())
+		added.Required = append(added.Required, *term.DeepCopy())
+	}
+
+	for _, term := range hint.PreferredDuringSchedulingIgnoredDuringExecution {
+		if containsSemantic(target.PreferredDuringSchedulingIgnoredDuringExecution, term) {
+			continue
+		}
+		target.PreferredDuringSchedulingIgnoredDuringExecution = append(target.PreferredDuringSchedulingIgnoredDuringExecution, *term.DeepCopy())
+		added.Preferred = append(added.Preferred, *term.DeepCopy())
+	}
+
+	if len(added.Required) == 0 && len(added.Preferred) == 0 {
+		return nil
+	}
+	return added
+}
+
+// revertClassification 根据记录撤销分类器新增的内容
+// 用户在分类后自行修改过的字段（如调度器）不会被还原
+func revertClassification(pod *v1.Pod, record *ClassificationRecord) {
+	if record.Scheduler != "" && pod.Spec.SchedulerName == record.Scheduler {
+		pod.Spec.SchedulerName = record.PreviousScheduler
+	}
+
+	for key, value := range record.Labels {
+		if pod.Labels[key] == value {
+			delete(pod.Labels, key)
+		}
+	}
+
+	if affinity := pod.Spec.Affinity; affinity != nil {
+		if added := record.NodeAffinity; added != nil && affinity.NodeAffinity != nil {
+			revertNodeAffinity(affinity.NodeAffinity, added)
+		}
+		if added := record.PodAntiAffinity; added != nil && affinity.PodAntiAffinity != nil {
+			anti := affinity.PodAntiAffinity
+			anti.RequiredDuringSchedulingIgnoredDuringExecution = removeSemantic(anti.RequiredDuringSchedulingIgnoredDuringExecution, added.Required)
+			anti.PreferredDuringSchedulingIgnoredDuringExecution = removeSemantic(anti.PreferredDuringSchedulingIgnoredDuringExecution, added.Preferred)
+		}
+	}
+	pruneEmptyAffinity(pod)
+
+	pod.Spec.Tolerations = removeSemantic(pod.Spec.Tolerations, record.Tolerations)
+	pod.Spec.TopologySpreadConstraints = removeSemantic(pod.Spec.TopologySpreadConstraints, record.TopologySpread)
+}
+
+func revertNodeAffinity(target *v1.NodeAffinity, added *NodeAffinityAddition) {
+	if required := target.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+		for i, reqs := range added.TermRequirements {
+			if i < len(required.NodeSelectorTerms) {
+				required.NodeSelectorTerms[i].MatchExpressions = removeSemantic(required.NodeSelectorTerms[i].MatchExpressions, reqs)
+			}
+		}
+		required.NodeSelectorTerms = removeSemantic(required.NodeSelectorTerms, added.Terms)
+		if len(required.NodeSelectorTerms) == 0 {
+			target.RequiredDuringSchedulingIgnoredDuringExecution = nil
+		}
+	}
+	target.PreferredDuringSchedulingIgnoredDuringExecution = removeSemantic(target.PreferredDuringSchedulingIgnoredDuringExecution, added.Preferred)
+}
+
+// pruneEmptyAffinity 清理合并或回滚后留下的空亲和性结构
+func pruneEmptyAffinity(pod *v1.Pod) {
+	affinity := pod.Spec.Affinity
+	if affinity == nil {
+		return
+	}
+	if na := affinity.NodeAffinity; na != nil &&
+		na.RequiredDuringSchedulingIgnoredDuringExecution == nil && len(na.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		affinity.NodeAffinity = nil
+	}
+	if anti := affinity.PodAntiAffinity; anti != nil &&
+		len(anti.RequiredDuringSchedulingIgnoredDuringExecution) == 0 && len(anti.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		affinity.PodAntiAffinity = nil
+	}
+	if affinity.NodeAffinity == nil && affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+		pod.Spec.Affinity = nil
+	}
+}
+
+// GetClassificationRecord 读取Pod上的分类记录，未分类时返回nil
+func GetClassificationRecord(pod *v1.Pod) (*ClassificationRecord, error) {
+	raw, exists := pod.Annotations[ClassificationAnnotation]
+	if !exists || raw == "" {
+		return nil, nil
+	}
+
+	var record ClassificationRecord
+	if err := json.Unmarshal([]byte(raw), &record); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation on pod %s/%s: %v", ClassificationAnnotation, pod.Namespace, pod.Name, err)
+	}
+	return &record, nil
+}
+
+func setClassificationRecord(pod *v1.Pod, record *ClassificationRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal classification record: %v", err)
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[ClassificationAnnotation] = string(data)
+	return nil
+}
+
+func hasToleration(tolerations []v1.Toleration, toleration v1.Toleration) bool {
+	for i := range tolerations {
+		if tolerations[i].MatchToleration(&toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTopologySpread(constraints []v1.TopologySpreadConstraint, constraint v1.TopologySpreadConstraint) bool {
+	for _, existing := range constraints {
+		if existing.TopologyKey == constraint.TopologyKey && existing.WhenUnsatisfiable == constraint.WhenUnsatisfiable {
+			return true
+		}
+	}
+	return false
+}
+
+// containsSemantic 判断items中是否存在与item语义相等的元素
+func containsSemantic[T any](items []T, item T) bool {
+	for i := range items {
+		if equality.Semantic.DeepEqual(items[i], item) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSemantic 从items中移除与removed语义相等的元素，每个被移除元素只匹配一次
+func removeSemantic[T any](items, removed []T) []T {
+	if len(removed) == 0 {
+		return items
+	}
+	used := make([]bool, len(removed))
+	var result []T
+	for _, item := range items {
+		matched := false
+		for j := range removed {
+			if !used[j] && equality.Semantic.DeepEqual(item, removed[j]) {
+				used[j] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,296 @@
+// dynamic-quota-arbitration.go
+// 动态配额仲裁 - 汇总各命名空间的扩容请求，按优先级在集群剩余容量内分配配额增量
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// quotaCapacityResources 配额资源与节点可分配资源的对应关系，未列出的资源不参与仲裁
+var quotaCapacityResources = map[v1.ResourceName]v1.ResourceName{
+	v1.ResourceRequestsCPU:    v1.ResourceCPU,
+	v1.ResourceCPU:            v1.ResourceCPU,
+	v1.ResourceRequestsMemory: v1.ResourceMemory,
+	v1.ResourceMemory:         v1.ResourceMemory,
+	v1.ResourcePods:           v1.ResourcePods,
+}
+
+// growthRequest 单个命名空间某种节点资源的扩容请求
+// 对应同一节点资源的多个配额资源（如cpu和requests.cpu）合并为一个请求，按其中最大的增量仲裁
+type growthRequest struct {
+	namespace string
+	priority  int
+	requested int64                     // 请求的增量，单位见quotaUnits
+	granted   int64                     // 获批的增量
+	resources map[v1.ResourceName]int64 // 各配额资源请求的增量
+}
+
+// SetReservedCapacity 设置仲裁时为系统组件等预留、不分配给动态配额的集群资源
+// 键为节点资源名称，如cpu、memory、pods
+func (drqm *DynamicResourceQuotaManager) SetReservedCapacity(reserved v1.ResourceList) {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	drqm.reserved = reserved.DeepCopy()
+}
+
+// HasPendingGrowth 判断是否有等待仲裁的扩容请求
+func (drqm *DynamicResourceQuotaManager) HasPendingGrowth() bool {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	return len(drqm.pendingGrowth) > 0
+}
+
+// Arbitrate 对所有等待中的扩容请求进行仲裁并应用获批的配额
+// 可用容量 = 集群可分配资源 - 预留资源 - 所有受管命名空间当前配额；
+// 按Priority从高到低分配，同一优先级容量不足时按请求量等比例分配。
+// 优先级只在同一次仲裁的请求之间生效，调用方应在所有受管命名空间协调完成后再仲裁。
+// 返回配额发生变化的命名空间
+func (drqm *DynamicResourceQuotaManager) Arbitrate(ctx context.Context) ([]string, error) {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+
+	if len(drqm.pendingGrowth) == 0 {
+		return nil, nil
+	}
+
+	available, err := drqm.availableCapacity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster capacity: %v", err)
+	}
+
+	// 按节点资源汇总各命名空间请求的增量，不受集群容量限制的配额资源单独处理
+	requests := make(map[v1.ResourceName]map[string]*growthRequest)
+	limited := make(map[v1.ResourceName]bool)
+	for namespace, targets := range drqm.pendingGrowth {
+		config, exists := drqm.quotas[namespace]
+		if !exists {
+			continue
+		}
+		current := boundQuota(drqm.metrics.CurrentQuota[namespace], config)
+		for name, target := range targets {
+			requested := quotaUnits(name, target) - quotaUnits(name, current[name])
+			if requested <= 0 {
+				continue
+			}
+
+			key := name
+			if capacityName, exists := quotaCapacityResources[name]; exists {
+				key = capacityName
+				limited[key] = true
+			}
+			if requests[key] == nil {
+				requests[key] = make(map[string]*growthRequest)
+			}
+			request, exists := requests[key][namespace]
+			if !exists {
+				request = &growthRequest{
+					namespace: namespace,
+					priority:  config.Priority,
+					resources: make(map[v1.ResourceName]int64),
+				}
+				requests[key][namespace] = request
+			}
+			request.resources[name] = requested
+			if requested > request.requested {
+				request.requested = requested
+			}
+		}
+	}
+
+	grants := make(map[string]v1.ResourceList)
+	for key, byNamespace := range requests {
+		resourceRequests := make([]*growthRequest, 0, len(byNamespace))
+		for _, request := range byNamespace {
+			resourceRequests = append(resourceRequests, request)
+		}
+		if limited[key] {
+			allocate(resourceRequests, available[key])
+			available[key] -= sumGranted(resourceRequests)
+		} else {
+			for _, request := range resourceRequests {
+				request.granted = request.requested
+			}
+		}
+
+		for _, request := range resourceRequests {
+			if request.granted < request.requested {
+				granted, requested := unitsQuantity(key, request.granted), unitsQuantity(key, request.requested)
+				klog.Infof("Quota growth of %s for namespace %s limited by cluster capacity: granted %s of %s",
+					key, request.namespace, granted.String(), requested.String())
+			}
+			if request.granted <= 0 {
+				continue
+			}
+			if grants[request.namespace] == nil {
+				grants[request.namespace] = v1.ResourceList{}
+			}
+			for name, requested := range request.resources {
+				grants[request.namespace][name] = unitsQuantity(name, min(request.granted, requested))
+			}
+		}
+	}
+
+	// 请求处理后即清空，未获批的部分在命名空间下次协调时重新提出
+	drqm.pendingGrowth = make(map[string]v1.ResourceList)
+
+	var updated []string
+	var errs []error
+	for namespace, grant := range grants {
+		if err := drqm.applyGrowth(ctx, namespace, grant); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %v", namespace, err))
+			continue
+		}
+		updated = append(updated, namespace)
+	}
+	sort.Strings(updated)
+
+	if len(errs) > 0 {
+		return updated, fmt.Errorf("failed to apply quota growth: %v", errs)
+	}
+	return updated, nil
+}
+
+// applyGrowth 在当前配额基础上增加获批的增量并应用
+func (drqm *DynamicResourceQuotaManager) applyGrowth(ctx context.Context, namespace string, grant v1.ResourceList) error {
+	config, exists := drqm.quotas[namespace]
+	if !exists {
+		return nil
+	}
+
+	newQuota := boundQuota(drqm.metrics.CurrentQuota[namespace], config)
+	for name, delta := range grant {
+		value := newQuota[name]
+		value.Add(delta)
+		newQuota[name] = boundQuantity(name, value, config)
+	}
+
+	if err := drqm.updateResourceQuota(ctx, namespace, newQuota); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	drqm.metrics.LastScaling[namespace] = now
+	if drqm.metrics.ResourceScaling[namespace] == nil {
+		drqm.metrics.ResourceScaling[namespace] = make(map[v1.ResourceName]time.Time)
+	}
+	for name := range grant {
+		drqm.metrics.ResourceScaling[namespace][name] = now
+	}
+	drqm.metrics.CurrentQuota[namespace] = newQuota
+	klog.Infof("Increased quota for namespace %s", namespace)
+
+	return drqm.saveState(ctx, namespace, true)
+}
+
+// availableCapacity 计算可用于配额增长的集群容量，单位见quotaUnits
+func (drqm *DynamicResourceQuotaManager) availableCapacity(ctx context.Context) (map[v1.ResourceName]int64, error) {
+	nodes, err := drqm.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[v1.ResourceName]int64)
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !isNodeReady(&node) {
+			continue
+		}
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourcePods} {
+			if value, exists := node.Status.Allocatable[name]; exists {
+				available[name] += quotaUnits(name, value)
+			}
+		}
+	}
+
+	for name, value := range drqm.reserved {
+		available[name] -= quotaUnits(name, value)
+	}
+
+	// 扣除所有受管命名空间已分配的配额；同一命名空间的cpu和requests.cpu等限制的是同一份资源，只扣除较大者
+	for namespace, config := range drqm.quotas {
+		current := boundQuota(drqm.metrics.CurrentQuota[namespace], config)
+		allocated := make(map[v1.ResourceName]int64)
+		for name, value := range current {
+			if capacityName, limited := quotaCapacityResources[name]; limited {
+				allocated[capacityName] = max(allocated[capacityName], quotaUnits(name, value))
+			}
+		}
+		for name, value := range allocated {
+			available[name] -= value
+		}
+	}
+
+	return available, nil
+}
+
+// allocate 按优先级从高到低分配容量，同一优先级容量不足时按请求量等比例分配
+func allocate(requests []*growthRequest, available int64) {
+	sort.SliceStable(requests, func(i, j int) bool {
+		if requests[i].priority != requests[j].priority {
+			return requests[i].priority > requests[j].priority
+		}
+		return requests[i].namespace < requests[j].namespace
+	})
+
+	for start := 0; start < len(requests); {
+		end := start
+		var total int64
+		for end < len(requests) && requests[end].priority == requests[start].priority {
+			total += requests[end].requested
+			end++
+		}
+
+		group := requests[start:end]
+		switch {
+		case available <= 0:
+			// 容量已耗尽，低优先级请求不再分配
+		case total <= available:
+			for _, request := range group {
+				request.granted = request.requested
+			}
+			available -= total
+		default:
+			for _, request := range group {
+				request.granted = int64(float64(available) * float64(request.requested) / float64(total))
+			}
+			available = 0
+		}
+		start = end
+	}
+}
+
+func sumGranted(requests []*growthRequest) int64 {
+	var total int64
+	for _, request := range requests {
+		total += request.granted
+	}
+	return total
+}
+
+// quotaUnits 将资源量转换为仲裁使用的整数单位：CPU为毫核，其余为基本单位
+func quotaUnits(name v1.ResourceName, quantity resource.Quantity) int64 {
+	switch name {
+	case v1.ResourceCPU, v1.ResourceRequestsCPU:
+		return quantity.MilliValue()
+	}
+	return quantity.Value()
+}
+
+// unitsQuantity 将仲裁单位转换回资源量，内存按MiB向下取整
+func unitsQuantity(name v1.ResourceName, units int64) resource.Quantity {
+	switch name {
+	case v1.ResourceCPU, v1.ResourceRequestsCPU:
+		return *resource.NewMilliQuantity(units, resource.DecimalSI)
+	case v1.ResourceMemory, v1.ResourceRequestsMemory:
+		const mebibyte = 1024 * 1024
+		return *resource.NewQuantity(units/mebibyte*mebibyte, resource.BinarySI)
+	}
+	return *resource.NewQuantity(units, resource.DecimalSI)
+}
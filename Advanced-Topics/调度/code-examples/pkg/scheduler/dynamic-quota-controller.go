@@ -0,0 +1,343 @@
+// dynamic-quota-controller.go
+// 动态配额控制器 - 按周期和Pod事件协调各命名空间的动态配额，支持领导者选举
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kubernetes-fundamentals/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// quotaArbitrationKey 工作队列中表示执行配额仲裁的键，命名空间名称不会与其冲突
+const quotaArbitrationKey = "~arbitration"
+
+// DynamicQuotaControllerConfig 动态配额控制器配置
+type DynamicQuotaControllerConfig struct {
+	ResyncInterval time.Duration              // 周期性协调所有命名空间的间隔
+	LeaderElection utils.LeaderElectionConfig // 领导者选举配置，LockName为空时不启用
+}
+
+// DynamicQuotaController 动态配额控制器
+// Pod增删和请求量变化时把所在命名空间加入工作队列，同时按周期加入所有受管命名空间；
+// 单个工作协程串行调用DynamicResourceQuotaManager.ReconcileNamespace。
+// 扩容请求在每个周期所有命名空间协调之后统一仲裁，Pod事件触发的协调只更新请求，
+// 避免低优先级命名空间在高优先级命名空间提出请求之前抢先获得容量
+type DynamicQuotaController struct {
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface // 为空时不监听DynamicQuotaPolicy，只使用代码中添加的配置
+	manager       *DynamicResourceQuotaManager
+	config        DynamicQuotaControllerConfig
+	queue         workqueue.RateLimitingInterface
+
+	policyIndexer cache.Indexer   // DynamicQuotaPolicy本地缓存
+	policyManaged map[string]bool // 配置来自DynamicQuotaPolicy的命名空间
+}
+
+// NewDynamicQuotaController 创建动态配额控制器
+// dynamicClient非空时同时协调各命名空间的DynamicQuotaPolicy并回写其状态
+func NewDynamicQuotaController(client kubernetes.Interface, dynamicClient dynamic.Interface, manager *DynamicResourceQuotaManager, config DynamicQuotaControllerConfig) *DynamicQuotaController {
+	if config.ResyncInterval == 0 {
+		config.ResyncInterval = time.Minute
+	}
+	return &DynamicQuotaController{
+		client:        client,
+		dynamicClient: dynamicClient,
+		manager:       manager,
+		config:        config,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "dynamic-quota"),
+		policyManaged: make(map[string]bool),
+	}
+}
+
+// Run 运行控制器直到ctx取消；配置了领导者选举时只有领导者执行协调
+func (c *DynamicQuotaController) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+
+	if c.config.LeaderElection.LockName == "" {
+		c.run(ctx)
+		return nil
+	}
+	return utils.RunWithLeaderElection(ctx, c.client, c.config.LeaderElection, c.run)
+}
+
+func (c *DynamicQuotaController) run(ctx context.Context) {
+	c.manager.InvalidateState()
+
+	factory := informers.NewSharedInformerFactory(c.client, 0)
+	podInformer := factory.Core().V1().Pods().Informer()
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// 只有调度结果或终止状态变化会影响配额使用量
+			oldPod, ok1 := oldObj.(*v1.Pod)
+			newPod, ok2 := newObj.(*v1.Pod)
+			if ok1 && ok2 && oldPod.Status.Phase == newPod.Status.Phase && oldPod.Spec.NodeName == newPod.Spec.NodeName {
+				return
+			}
+			c.enqueuePod(newObj)
+		},
+		DeleteFunc: c.enqueuePod,
+	})
+
+	synced := []cache.InformerSynced{podInformer.HasSynced}
+	if c.dynamicClient != nil {
+		policyFactory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamicClient, 0)
+		policyInformer := policyFactory.ForResource(DynamicQuotaPolicyGVR).Informer()
+		policyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: c.enqueueObject,
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				// 状态子资源更新不改变generation，忽略以避免回写状态后再次触发协调
+				oldPolicy, ok1 := oldObj.(*unstructured.Unstructured)
+				newPolicy, ok2 := newObj.(*unstructured.Unstructured)
+				if ok1 && ok2 && oldPolicy.GetGeneration() == newPolicy.GetGeneration() {
+					return
+				}
+				c.enqueueObject(newObj)
+			},
+			DeleteFunc: c.enqueueObject,
+		})
+		c.policyIndexer = policyInformer.GetIndexer()
+		policyFactory.Start(ctx.Done())
+		synced = append(synced, policyInformer.HasSynced)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		klog.Error("Failed to sync informers for dynamic quota controller")
+		return
+	}
+	if c.policyIndexer != nil {
+		for _, namespace := range c.policyIndexer.ListIndexFuncValues(cache.NamespaceIndex) {
+			c.queue.Add(namespace)
+		}
+	}
+
+	go wait.UntilWithContext(ctx, c.enqueueAll, c.config.ResyncInterval)
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+
+	klog.Info("Dynamic quota controller started")
+	<-ctx.Done()
+	klog.Info("Dynamic quota controller stopped")
+}
+
+// enqueuePod 把Pod所在的受管命名空间加入队列，同一命名空间的多次事件会在队列中合并
+func (c *DynamicQuotaController) enqueuePod(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return
+	}
+	if c.manager.HasQuotaConfig(pod.Namespace) {
+		c.queue.Add(pod.Namespace)
+	}
+}
+
+// enqueueObject 把DynamicQuotaPolicy所在命名空间加入队列
+func (c *DynamicQuotaController) enqueueObject(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if accessor, err := apimeta.Accessor(obj); err == nil {
+		c.queue.Add(accessor.GetNamespace())
+	}
+}
+
+// enqueueAll 加入所有受管命名空间，最后加入仲裁键
+// 队列按加入顺序处理，仲裁在本周期的命名空间协调完成后执行，覆盖所有命名空间的扩容请求
+func (c *DynamicQuotaController) enqueueAll(ctx context.Context) {
+	for _, namespace := range c.manager.Namespaces() {
+		c.queue.Add(namespace)
+	}
+	c.queue.Add(quotaArbitrationKey)
+}
+
+func (c *DynamicQuotaController) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *DynamicQuotaController) processNextItem(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+
+	// 失去领导权后阻塞在Get上的旧协程不再处理，把命名空间留给新的领导者周期
+	if ctx.Err() != nil {
+		c.queue.Add(item)
+		return false
+	}
+
+	namespace := item.(string)
+	if namespace == quotaArbitrationKey {
+		return c.processArbitration(ctx, item)
+	}
+	policies, active := c.syncPolicies(namespace)
+
+	err := c.manager.ReconcileNamespace(ctx, namespace)
+	if err != nil {
+		klog.Errorf("Failed to reconcile dynamic quota for namespace %s: %v", namespace, err)
+	}
+
+	for _, policy := range policies {
+		if statusErr := c.updatePolicyStatus(ctx, policy, policy == active, err); statusErr != nil {
+			klog.Errorf("Failed to update status of %s %s/%s: %v", dynamicQuotaPolicyKind, policy.GetNamespace(), policy.GetName(), statusErr)
+		}
+	}
+
+	if err != nil {
+		c.queue.AddRateLimited(item)
+		return true
+	}
+	c.queue.Forget(item)
+	return true
+}
+
+// processArbitration 仲裁等待中的扩容请求，配额变化的命名空间重新入队以刷新策略状态
+func (c *DynamicQuotaController) processArbitration(ctx context.Context, item interface{}) bool {
+	updated, err := c.manager.Arbitrate(ctx)
+	for _, namespace := range updated {
+		c.queue.Add(namespace)
+	}
+	if err != nil {
+		klog.Errorf("Failed to arbitrate dynamic quota growth: %v", err)
+		c.queue.AddRateLimited(item)
+		return true
+	}
+	c.queue.Forget(item)
+	return true
+}
+
+// syncPolicies 根据命名空间内的DynamicQuotaPolicy更新配额管理器的配置
+// 返回命名空间内所有策略（按名称排序）以及生效的策略；规格无效的策略不会生效
+func (c *DynamicQuotaController) syncPolicies(namespace string) ([]*unstructured.Unstructured, *unstructured.Unstructured) {
+	if c.policyIndexer == nil {
+		return nil, nil
+	}
+
+	objs, err := c.policyIndexer.ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		klog.Errorf("Failed to list %s in namespace %s: %v", dynamicQuotaPolicyKind, namespace, err)
+		return nil, nil
+	}
+
+	var policies []*unstructured.Unstructured
+	for _, obj := range objs {
+		if policy, ok := obj.(*unstructured.Unstructured); ok {
+			policies = append(policies, policy)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].GetName() < policies[j].GetName()
+	})
+
+	var active *unstructured.Unstructured
+	var config *ResourceQuotaConfig
+	if len(policies) > 0 {
+		policy, err := DynamicQuotaPolicyFromUnstructured(policies[0])
+		if err == nil {
+			config, err = policy.ToQuotaConfig()
+		}
+		if err != nil {
+			klog.Errorf("Invalid %s %s/%s: %v", dynamicQuotaPolicyKind, namespace, policies[0].GetName(), err)
+		} else {
+			active = policies[0]
+		}
+	}
+
+	switch {
+	case config != nil:
+		c.manager.AddQuotaConfig(config)
+		c.policyManaged[namespace] = true
+	case c.policyManaged[namespace]:
+		// 策略被删除或变为无效，停止管理该命名空间；已应用的配额保持不变
+		c.manager.RemoveQuotaConfig(namespace)
+		delete(c.policyManaged, namespace)
+	}
+
+	return policies, active
+}
+
+// updatePolicyStatus 回写DynamicQuotaPolicy状态，状态未变化时不发起请求
+func (c *DynamicQuotaController) updatePolicyStatus(ctx context.Context, obj *unstructured.Unstructured, active bool, reconcileErr error) error {
+	policy, err := DynamicQuotaPolicyFromUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	status := *policy.Status.DeepCopy()
+	status.ObservedGeneration = policy.Generation
+	condition := metav1.Condition{
+		Type:               DynamicQuotaPolicyReady,
+		ObservedGeneration: policy.Generation,
+	}
+
+	switch {
+	case !active:
+		if _, specErr := policy.ToQuotaConfig(); specErr != nil {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = DynamicQuotaReasonInvalid
+			condition.Message = specErr.Error()
+		} else {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = DynamicQuotaReasonConflict
+			condition.Message = "another DynamicQuotaPolicy in this namespace takes precedence"
+		}
+	case reconcileErr != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = DynamicQuotaReasonFailed
+		condition.Message = reconcileErr.Error()
+	default:
+		quotaStatus := c.manager.GetNamespaceStatus(policy.Namespace)
+		status.CurrentQuota = quotaStatus.CurrentQuota
+		if status.CurrentQuota == nil {
+			status.CurrentQuota = policy.Spec.BaseQuota
+		}
+		if !quotaStatus.LastScaling.IsZero() {
+			status.LastScalingTime = &metav1.Time{Time: quotaStatus.LastScaling}
+		}
+		if quotaStatus.LastUsage != nil {
+			status.ObservedUsage = usageToResourceList(quotaStatus.LastUsage)
+			status.ObservedAt = &metav1.Time{Time: quotaStatus.LastUsage.Timestamp}
+		}
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = DynamicQuotaReasonReconciled
+		condition.Message = "dynamic quota reconciled"
+	}
+	apimeta.SetStatusCondition(&status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(status, policy.Status) {
+		return nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	updated := obj.DeepCopy()
+	if err := unstructured.SetNestedMap(updated.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(DynamicQuotaPolicyGVR).Namespace(policy.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,170 @@
+// dynamic-quota-policy.go
+// DynamicQuotaPolicy自定义资源 - 团队通过命名空间内的CR自助声明动态配额策略
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DynamicQuotaPolicyGVR DynamicQuotaPolicy资源的GroupVersionResource
+var DynamicQuotaPolicyGVR = schema.GroupVersionResource{
+	Group:    "scheduling.kubernetes-fundamentals.io",
+	Version:  "v1alpha1",
+	Resource: "dynamicquotapolicies",
+}
+
+// DynamicQuotaPolicy 命名空间级动态配额策略
+// 每个命名空间只生效一个策略，存在多个时按名称排序取第一个，其余标记为Conflict
+type DynamicQuotaPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DynamicQuotaPolicySpec   `json:"spec"`
+	Status DynamicQuotaPolicyStatus `json:"status,omitempty"`
+}
+
+// DynamicQuotaPolicySpec 动态配额策略规格
+type DynamicQuotaPolicySpec struct {
+	BaseQuota       v1.ResourceList           `json:"baseQuota"`                 // 基础资源配额，也是缩容的下限
+	MaxQuota        v1.ResourceList           `json:"maxQuota"`                  // 最大资源配额限制
+	ScalingRules    []DynamicQuotaScalingRule `json:"scalingRules,omitempty"`    // 扩缩容规则
+	Cooldown        *metav1.Duration          `json:"cooldown,omitempty"`        // 规则未设置冷却时间时使用的默认值
+	SmoothingWindow *metav1.Duration          `json:"smoothingWindow,omitempty"` // 使用量平滑窗口
+	Priority        int                       `json:"priority,omitempty"`        // 配额优先级
+}
+
+// DynamicQuotaScalingRule 扩缩容规则，阈值为使用量占当前配额的比例
+type DynamicQuotaScalingRule struct {
+	MetricType         string           `json:"metricType"`                   // "cpu_usage", "memory_usage", "pod_count"
+	Threshold          float64          `json:"threshold"`                    // 触发扩容的使用率阈值
+	ScaleDownThreshold float64          `json:"scaleDownThreshold,omitempty"` // 触发缩容的使用率阈值
+	ScaleFactor        float64          `json:"scaleFactor"`                  // 扩缩容倍数
+	Cooldown           *metav1.Duration `json:"cooldown,omitempty"`           // 冷却时间
+}
+
+// DynamicQuotaPolicyStatus 动态配额策略状态
+type DynamicQuotaPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	CurrentQuota       v1.ResourceList    `json:"currentQuota,omitempty"`    // 当前生效的配额
+	LastScalingTime    *metav1.Time       `json:"lastScalingTime,omitempty"` // 最后一次调整配额的时间
+	ObservedUsage      v1.ResourceList    `json:"observedUsage,omitempty"`   // 最近一次观测到的资源请求量
+	ObservedAt         *metav1.Time       `json:"observedAt,omitempty"`      // 观测时间
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// DeepCopy 复制状态，避免修改informer缓存中的对象
+func (s *DynamicQuotaPolicyStatus) DeepCopy() *DynamicQuotaPolicyStatus {
+	out := *s
+	out.CurrentQuota = s.CurrentQuota.DeepCopy()
+	out.ObservedUsage = s.ObservedUsage.DeepCopy()
+	if s.LastScalingTime != nil {
+		out.LastScalingTime = s.LastScalingTime.DeepCopy()
+	}
+	if s.ObservedAt != nil {
+		out.ObservedAt = s.ObservedAt.DeepCopy()
+	}
+	if s.Conditions != nil {
+		out.Conditions = make([]metav1.Condition, len(s.Conditions))
+		for i := range s.Conditions {
+			s.Conditions[i].DeepCopyInto(&out.Conditions[i])
+		}
+	}
+	return &out
+}
+
+// DynamicQuotaPolicy条件类型和原因
+const (
+	DynamicQuotaPolicyReady      = "Ready"
+	DynamicQuotaReasonReconciled = "Reconciled"
+	DynamicQuotaReasonFailed     = "ReconcileFailed"
+	DynamicQuotaReasonInvalid    = "InvalidSpec"
+	DynamicQuotaReasonConflict   = "Conflict"
+	defaultDynamicQuotaCooldown  = 5 * time.Minute
+	dynamicQuotaPolicyKind       = "DynamicQuotaPolicy"
+)
+
+// DynamicQuotaPolicyFromUnstructured 将动态客户端返回的对象转换为DynamicQuotaPolicy
+func DynamicQuotaPolicyFromUnstructured(obj *unstructured.Unstructured) (*DynamicQuotaPolicy, error) {
+	var policy DynamicQuotaPolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), &policy); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s/%s: %v", dynamicQuotaPolicyKind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	return &policy, nil
+}
+
+// ToQuotaConfig 校验策略并转换为配额管理器使用的ResourceQuotaConfig
+func (p *DynamicQuotaPolicy) ToQuotaConfig() (*ResourceQuotaConfig, error) {
+	if len(p.Spec.BaseQuota) == 0 {
+		return nil, fmt.Errorf("spec.baseQuota is required")
+	}
+	for name, base := range p.Spec.BaseQuota {
+		if max, exists := p.Spec.MaxQuota[name]; exists && max.Cmp(base) < 0 {
+			return nil, fmt.Errorf("spec.maxQuota[%s]=%s is below spec.baseQuota[%s]=%s", name, max.String(), name, base.String())
+		}
+	}
+
+	defaultCooldown := defaultDynamicQuotaCooldown
+	if p.Spec.Cooldown != nil {
+		defaultCooldown = p.Spec.Cooldown.Duration
+	}
+
+	config := &ResourceQuotaConfig{
+		Namespace: p.Namespace,
+		BaseQuota: p.Spec.BaseQuota.DeepCopy(),
+		MaxQuota:  p.Spec.MaxQuota.DeepCopy(),
+		Priority:  p.Spec.Priority,
+	}
+	if p.Spec.SmoothingWindow != nil {
+		config.SmoothingWindow = p.Spec.SmoothingWindow.Duration
+	}
+	for i, rule := range p.Spec.ScalingRules {
+		name, known := scalingResourceName(rule.MetricType)
+		if !known {
+			return nil, fmt.Errorf("spec.scalingRules[%d]: unknown metricType %q", i, rule.MetricType)
+		}
+		// 规则以基础配额为下限调整对应资源，基础配额中没有该资源时规则永远不会生效
+		if _, exists := p.Spec.BaseQuota[name]; !exists {
+			return nil, fmt.Errorf("spec.scalingRules[%d]: metricType %s requires spec.baseQuota[%s]", i, rule.MetricType, name)
+		}
+		if rule.ScaleFactor < 1 {
+			return nil, fmt.Errorf("spec.scalingRules[%d]: scaleFactor must be at least 1", i)
+		}
+		if rule.Threshold <= 0 || rule.Threshold >= 1 {
+			return nil, fmt.Errorf("spec.scalingRules[%d]: threshold must be between 0 and 1", i)
+		}
+		if rule.ScaleDownThreshold < 0 || rule.ScaleDownThreshold >= rule.Threshold {
+			return nil, fmt.Errorf("spec.scalingRules[%d]: scaleDownThreshold must be below threshold", i)
+		}
+
+		cooldown := defaultCooldown
+		if rule.Cooldown != nil {
+			cooldown = rule.Cooldown.Duration
+		}
+		config.ScalingRules = append(config.ScalingRules, ScalingRule{
+			MetricType:           rule.MetricType,
+			UtilizationThreshold: rule.Threshold,
+			ScaleDownThreshold:   rule.ScaleDownThreshold,
+			ScaleFactor:          rule.ScaleFactor,
+			CooldownTime:         cooldown,
+		})
+	}
+
+	return config, nil
+}
+
+// usageToResourceList 将使用快照转换为ResourceList，用于状态上报
+func usageToResourceList(usage *ResourceUsage) v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceRequestsCPU:    *resource.NewMilliQuantity(int64(usage.CPU*1000), resource.DecimalSI),
+		v1.ResourceRequestsMemory: *resource.NewQuantity(int64(usage.Memory*1024*1024*1024), resource.BinarySI),
+		v1.ResourcePods:           *resource.NewQuantity(int64(usage.Pods), resource.DecimalSI),
+	}
+}
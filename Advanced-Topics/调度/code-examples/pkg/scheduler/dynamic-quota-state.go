@@ -0,0 +1,105 @@
+// dynamic-quota-state.go
+// 动态配额状态持久化 - 将平滑窗口内的使用历史和最后扩缩容时间保存到ConfigMap，控制器重启后冷却期不会被重置
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// QuotaStateConfigMapName 每个受管命名空间中保存动态配额状态的ConfigMap名称
+	QuotaStateConfigMapName = "dynamic-quota-state"
+	quotaStateKey           = "state.json"
+)
+
+// QuotaState 单个命名空间的动态配额状态
+// UsageHistory只包含平滑窗口内的记录，状态大小不随运行时间增长
+type QuotaState struct {
+	UsageHistory    []ResourceUsage               `json:"usageHistory"`
+	LastScaling     time.Time                     `json:"lastScaling,omitempty"`
+	ResourceScaling map[v1.ResourceName]time.Time `json:"resourceScaling,omitempty"`
+	CurrentQuota    v1.ResourceList               `json:"currentQuota,omitempty"`
+}
+
+// QuotaStateStore 动态配额状态存储
+type QuotaStateStore interface {
+	// Load 读取命名空间状态，不存在时返回nil
+	Load(ctx context.Context, namespace string) (*QuotaState, error)
+	// Save 保存命名空间状态
+	Save(ctx context.Context, namespace string, state *QuotaState) error
+}
+
+// ConfigMapQuotaStateStore 基于ConfigMap的状态存储
+// 状态保存在受管命名空间内的dynamic-quota-state ConfigMap中，随命名空间删除而清理
+type ConfigMapQuotaStateStore struct {
+	client kubernetes.Interface
+}
+
+// NewConfigMapQuotaStateStore 创建基于ConfigMap的状态存储
+func NewConfigMapQuotaStateStore(client kubernetes.Interface) *ConfigMapQuotaStateStore {
+	return &ConfigMapQuotaStateStore{client: client}
+}
+
+// Load 实现QuotaStateStore
+func (s *ConfigMapQuotaStateStore) Load(ctx context.Context, namespace string) (*QuotaState, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(namespace).Get(ctx, QuotaStateConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, exists := cm.Data[quotaStateKey]
+	if !exists {
+		return nil, nil
+	}
+
+	var state QuotaState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return nil, fmt.Errorf("invalid quota state in configmap %s/%s: %v", namespace, QuotaStateConfigMapName, err)
+	}
+	return &state, nil
+}
+
+// Save 实现QuotaStateStore
+func (s *ConfigMapQuotaStateStore) Save(ctx context.Context, namespace string, state *QuotaState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quota state: %v", err)
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(namespace)
+	cm, err := configMaps.Get(ctx, QuotaStateConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      QuotaStateConfigMapName,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": DynamicQuotaFieldManager,
+				},
+			},
+			Data: map[string]string{quotaStateKey: string(data)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[quotaStateKey] = string(data)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
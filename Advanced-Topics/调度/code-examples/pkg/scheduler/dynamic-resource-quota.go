@@ -5,11 +5,17 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
@@ -17,44 +23,74 @@ import (
 // DynamicResourceQuotaManager 动态资源配额管理器
 // 负责监控命名空间资源使用情况并自动调整配额
 type DynamicResourceQuotaManager struct {
-	client  kubernetes.Interface            // Kubernetes客户端
-	quotas  map[string]*ResourceQuotaConfig // 命名空间配额配置映射
-	metrics *QuotaMetrics                   // 配额使用指标和历史数据
+	mu         sync.Mutex
+	client     kubernetes.Interface            // Kubernetes客户端
+	quotas     map[string]*ResourceQuotaConfig // 命名空间配额配置映射
+	metrics    *QuotaMetrics                   // 配额使用指标和历史数据
+	stateStore QuotaStateStore                 // 使用历史和扩缩容时间的持久化存储，为空时只保存在内存
+	loaded     map[string]bool                 // 已从持久化存储加载状态的命名空间
+	savedAt    map[string]time.Time            // 各命名空间最近一次写入持久化存储的时间
+
+	reserved      v1.ResourceList            // 仲裁时不分配给动态配额的集群预留资源
+	pendingGrowth map[string]v1.ResourceList // 等待仲裁的扩容目标配额
 }
 
 // ResourceQuotaConfig 资源配额配置
 // 定义命名空间的基础配额、最大配额和扩缩容规则
 type ResourceQuotaConfig struct {
-	Namespace    string          // 命名空间名称
-	BaseQuota    v1.ResourceList // 基础资源配额
-	MaxQuota     v1.ResourceList // 最大资源配额限制
-	ScalingRules []ScalingRule   // 自动扩缩容规则列表
-	Priority     int             // 配额优先级，影响资源分配顺序
+	Namespace       string          // 命名空间名称
+	BaseQuota       v1.ResourceList // 基础资源配额，也是缩容的下限
+	MaxQuota        v1.ResourceList // 最大资源配额限制
+	ScalingRules    []ScalingRule   // 自动扩缩容规则列表
+	Priority        int             // 配额优先级，影响资源分配顺序
+	SmoothingWindow time.Duration   // 平滑窗口，扩容看窗口内平均值、缩容看窗口内峰值，默认10分钟
 }
 
 // ScalingRule 扩缩容规则
-// 定义触发配额调整的条件和调整策略
+// 定义触发配额调整的条件和调整策略，阈值均为使用量占当前配额的比例
 type ScalingRule struct {
-	MetricType   string        // 监控指标类型："cpu_usage", "memory_usage", "pod_count"
-	Threshold    float64       // 触发扩容的阈值
-	ScaleFactor  float64       // 扩容倍数，如1.5表示扩容50%
-	CooldownTime time.Duration // 冷却时间，防止频繁调整
+	MetricType           string        // 监控指标类型："cpu_usage", "memory_usage", "pod_count"
+	UtilizationThreshold float64       // 触发扩容的使用率阈值（使用量/当前配额），取值(0, 1)，如0.8
+	ScaleDownThreshold   float64       // 触发缩容的使用率阈值，需小于UtilizationThreshold，默认为其一半
+	ScaleFactor          float64       // 扩缩容倍数，如1.5表示扩容50%，缩容时按该倍数收缩
+	CooldownTime         time.Duration // 冷却时间，防止频繁调整，默认5分钟
 }
 
 // QuotaMetrics 配额使用指标
 // 存储历史使用数据和扩缩容时间记录
 type QuotaMetrics struct {
-	UsageHistory map[string][]ResourceUsage // 各命名空间的资源使用历史
-	LastScaling  map[string]time.Time       // 各命名空间的最后扩缩容时间
+	UsageHistory    map[string][]ResourceUsage               // 各命名空间的资源使用历史
+	LastScaling     map[string]time.Time                     // 各命名空间的最后扩缩容时间
+	ResourceScaling map[string]map[v1.ResourceName]time.Time // 各命名空间每种资源的最后扩缩容时间，用于规则冷却
+	CurrentQuota    map[string]v1.ResourceList               // 各命名空间最近一次应用的配额
+}
+
+const (
+	// DynamicQuotaName 动态配额管理器维护的ResourceQuota名称
+	DynamicQuotaName = "dynamic-quota"
+	// DynamicQuotaFieldManager 服务端应用时使用的字段管理器名称
+	DynamicQuotaFieldManager = "dynamic-quota-controller"
+
+	// defaultSmoothingWindow 未配置平滑窗口时使用的默认值
+	defaultSmoothingWindow = 10 * time.Minute
+	// quotaStateSaveInterval 配额未变化时写回持久化存储的最小间隔
+	quotaStateSaveInterval = time.Minute
+)
+
+// NamespaceQuotaStatus 命名空间的动态配额状态快照
+type NamespaceQuotaStatus struct {
+	CurrentQuota v1.ResourceList // 最近一次应用的配额，尚未调整过时为nil
+	LastScaling  time.Time       // 最后扩缩容时间
+	LastUsage    *ResourceUsage  // 最近一次观测到的使用情况
 }
 
 // ResourceUsage 资源使用情况快照
 // 记录某个时间点的资源使用状态
 type ResourceUsage struct {
-	Timestamp time.Time // 记录时间戳
-	CPU       float64   // CPU使用量（核心数）
-	Memory    float64   // 内存使用量（GB）
-	Pods      int       // Pod数量
+	Timestamp time.Time `json:"timestamp"` // 记录时间戳
+	CPU       float64   `json:"cpu"`       // CPU使用量（核心数）
+	Memory    float64   `json:"memory"`    // 内存使用量（GB）
+	Pods      int       `json:"pods"`      // Pod数量
 }
 
 // NewDynamicResourceQuotaManager 创建新的动态资源配额管理器实例
@@ -64,47 +100,228 @@ func NewDynamicResourceQuotaManager(client kubernetes.Interface) *DynamicResourc
 		client: client,
 		quotas: make(map[string]*ResourceQuotaConfig), // 初始化配额配置映射
 		metrics: &QuotaMetrics{
-			UsageHistory: make(map[string][]ResourceUsage), // 初始化使用历史
-			LastScaling:  make(map[string]time.Time),       // 初始化扩缩容时间记录
+			UsageHistory:    make(map[string][]ResourceUsage), // 初始化使用历史
+			LastScaling:     make(map[string]time.Time),       // 初始化扩缩容时间记录
+			ResourceScaling: make(map[string]map[v1.ResourceName]time.Time),
+			CurrentQuota:    make(map[string]v1.ResourceList),
 		},
+		loaded:        make(map[string]bool),
+		savedAt:       make(map[string]time.Time),
+		pendingGrowth: make(map[string]v1.ResourceList),
+	}
+}
+
+// SetStateStore 设置状态持久化存储，重启后从中恢复使用历史和冷却期
+func (drqm *DynamicResourceQuotaManager) SetStateStore(store QuotaStateStore) {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	drqm.stateStore = store
+	drqm.loaded = make(map[string]bool)
+}
+
+// InvalidateState 丢弃已加载标记，下次协调时重新从持久化存储读取
+// 重新获得领导权时调用，避免沿用其他副本领导期间已过期的内存状态
+func (drqm *DynamicResourceQuotaManager) InvalidateState() {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	if drqm.stateStore != nil {
+		drqm.loaded = make(map[string]bool)
 	}
 }
 
 // AddQuotaConfig 添加命名空间的配额配置
 // 为指定命名空间设置动态配额管理规则
 func (drqm *DynamicResourceQuotaManager) AddQuotaConfig(config *ResourceQuotaConfig) {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
 	drqm.quotas[config.Namespace] = config
 	klog.Infof("Added quota config for namespace: %s", config.Namespace)
 }
 
+// RemoveQuotaConfig 移除命名空间的配额配置
+func (drqm *DynamicResourceQuotaManager) RemoveQuotaConfig(namespace string) {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	delete(drqm.quotas, namespace)
+	delete(drqm.loaded, namespace)
+	delete(drqm.savedAt, namespace)
+	delete(drqm.pendingGrowth, namespace)
+	klog.Infof("Removed quota config for namespace: %s", namespace)
+}
+
+// HasQuotaConfig 判断命名空间是否配置了动态配额
+func (drqm *DynamicResourceQuotaManager) HasQuotaConfig(namespace string) bool {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	_, exists := drqm.quotas[namespace]
+	return exists
+}
+
+// GetNamespaceStatus 返回命名空间的动态配额状态快照
+func (drqm *DynamicResourceQuotaManager) GetNamespaceStatus(namespace string) NamespaceQuotaStatus {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+
+	status := NamespaceQuotaStatus{
+		CurrentQuota: drqm.metrics.CurrentQuota[namespace].DeepCopy(),
+		LastScaling:  drqm.metrics.LastScaling[namespace],
+	}
+	if history := drqm.metrics.UsageHistory[namespace]; len(history) > 0 {
+		last := history[len(history)-1]
+		status.LastUsage = &last
+	}
+	return status
+}
+
+// Namespaces 返回所有配置了动态配额的命名空间
+func (drqm *DynamicResourceQuotaManager) Namespaces() []string {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+	namespaces := make([]string, 0, len(drqm.quotas))
+	for namespace := range drqm.quotas {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
 // UpdateQuotas 更新所有命名空间的资源配额
-// 定期执行的主要逻辑，监控使用情况并根据规则调整配额
+// 定期执行的主要逻辑，监控使用情况并根据规则调整配额，最后统一仲裁扩容请求
 func (drqm *DynamicResourceQuotaManager) UpdateQuotas(ctx context.Context) error {
-	for namespace, config := range drqm.quotas {
-		// 获取当前命名空间的资源使用情况
-		usage, err := drqm.getCurrentUsage(ctx, namespace)
-		if err != nil {
-			klog.Errorf("Failed to get usage for namespace %s: %v", namespace, err)
-			continue
+	for _, namespace := range drqm.Namespaces() {
+		if err := drqm.ReconcileNamespace(ctx, namespace); err != nil {
+			klog.Errorf("Failed to reconcile quota for namespace %s: %v", namespace, err)
 		}
+	}
+	_, err := drqm.Arbitrate(ctx)
+	return err
+}
+
+// ReconcileNamespace 协调单个命名空间的资源配额
+// 采集使用情况、按规则计算新配额并应用，状态变化写回持久化存储；
+// 缩容和上下限修正立即生效，扩容记为等待仲裁的请求，由Arbitrate统一分配
+func (drqm *DynamicResourceQuotaManager) ReconcileNamespace(ctx context.Context, namespace string) error {
+	drqm.mu.Lock()
+	defer drqm.mu.Unlock()
+
+	config, exists := drqm.quotas[namespace]
+	if !exists {
+		return nil
+	}
+
+	if err := drqm.loadState(ctx, namespace); err != nil {
+		return err
+	}
 
-		// 记录使用历史，用于趋势分析
-		drqm.recordUsage(namespace, usage)
-
-		// 根据扩缩容规则计算新的配额
-		newQuota := drqm.calculateNewQuota(namespace, config, usage)
-		if newQuota != nil {
-			// 应用新的配额设置
-			err = drqm.updateResourceQuota(ctx, namespace, newQuota)
-			if err != nil {
-				klog.Errorf("Failed to update quota for namespace %s: %v", namespace, err)
-			} else {
-				// 记录扩缩容时间，用于冷却期控制
-				drqm.metrics.LastScaling[namespace] = time.Now()
-				klog.Infof("Updated quota for namespace %s", namespace)
+	// 获取当前命名空间的资源使用情况
+	usage, err := drqm.getCurrentUsage(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to get usage: %v", err)
+	}
+
+	// 记录使用历史，用于趋势分析
+	drqm.recordUsage(namespace, usage)
+
+	// 根据扩缩容规则计算新的配额
+	newQuota, scaled := drqm.calculateNewQuota(namespace, config, usage.Timestamp)
+	delete(drqm.pendingGrowth, namespace)
+	if newQuota != nil {
+		// 扩容部分暂不应用，交给仲裁按集群容量和优先级分配
+		current := boundQuota(drqm.metrics.CurrentQuota[namespace], config)
+		var shrunk []v1.ResourceName
+		for _, resourceName := range scaled {
+			target := newQuota[resourceName]
+			if target.Cmp(current[resourceName]) <= 0 {
+				shrunk = append(shrunk, resourceName)
+				continue
+			}
+			if drqm.pendingGrowth[namespace] == nil {
+				drqm.pendingGrowth[namespace] = v1.ResourceList{}
 			}
+			drqm.pendingGrowth[namespace][resourceName] = target
+			newQuota[resourceName] = current[resourceName]
+		}
+		scaled = shrunk
+
+		applied, exists := drqm.metrics.CurrentQuota[namespace]
+		if exists && equality.Semantic.DeepEqual(newQuota, applied) {
+			newQuota = nil
+		}
+	}
+	if newQuota != nil {
+		// 应用新的配额设置
+		if err := drqm.updateResourceQuota(ctx, namespace, newQuota); err != nil {
+			return fmt.Errorf("failed to update quota: %v", err)
+		}
+		// 记录扩缩容时间，用于冷却期控制
+		drqm.metrics.LastScaling[namespace] = usage.Timestamp
+		if len(scaled) > 0 && drqm.metrics.ResourceScaling[namespace] == nil {
+			drqm.metrics.ResourceScaling[namespace] = make(map[v1.ResourceName]time.Time)
+		}
+		for _, resourceName := range scaled {
+			drqm.metrics.ResourceScaling[namespace][resourceName] = usage.Timestamp
+		}
+		drqm.metrics.CurrentQuota[namespace] = newQuota
+		klog.Infof("Updated quota for namespace %s", namespace)
+	}
+
+	return drqm.saveState(ctx, namespace, newQuota != nil)
+}
+
+// loadState 首次协调命名空间时从持久化存储恢复状态
+func (drqm *DynamicResourceQuotaManager) loadState(ctx context.Context, namespace string) error {
+	if drqm.stateStore == nil || drqm.loaded[namespace] {
+		return nil
+	}
+
+	state, err := drqm.stateStore.Load(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("failed to load quota state: %v", err)
+	}
+	if state != nil {
+		drqm.metrics.UsageHistory[namespace] = state.UsageHistory
+		if !state.LastScaling.IsZero() {
+			drqm.metrics.LastScaling[namespace] = state.LastScaling
+		}
+		if state.ResourceScaling != nil {
+			drqm.metrics.ResourceScaling[namespace] = state.ResourceScaling
 		}
+		if state.CurrentQuota != nil {
+			drqm.metrics.CurrentQuota[namespace] = state.CurrentQuota
+		}
+	}
+	drqm.loaded[namespace] = true
+	return nil
+}
+
+// saveState 将命名空间的使用历史和扩缩容时间写入持久化存储
+// 只保存平滑窗口内的使用记录；配额未变化（changed为false）时按quotaStateSaveInterval限制写入频率
+func (drqm *DynamicResourceQuotaManager) saveState(ctx context.Context, namespace string, changed bool) error {
+	if drqm.stateStore == nil {
+		return nil
+	}
+	now := time.Now()
+	if !changed && now.Sub(drqm.savedAt[namespace]) < quotaStateSaveInterval {
+		return nil
+	}
+
+	var window time.Duration
+	if config, exists := drqm.quotas[namespace]; exists {
+		window = smoothingWindow(config)
+	} else {
+		window = defaultSmoothingWindow
 	}
+
+	state := &QuotaState{
+		UsageHistory:    usageWindow(drqm.metrics.UsageHistory[namespace], now.Add(-window)),
+		LastScaling:     drqm.metrics.LastScaling[namespace],
+		ResourceScaling: drqm.metrics.ResourceScaling[namespace],
+		CurrentQuota:    drqm.metrics.CurrentQuota[namespace],
+	}
+	if err := drqm.stateStore.Save(ctx, namespace, state); err != nil {
+		return fmt.Errorf("failed to save quota state: %v", err)
+	}
+	drqm.savedAt[namespace] = now
 	return nil
 }
 
@@ -143,98 +360,234 @@ func (drqm *DynamicResourceQuotaManager) getCurrentUsage(ctx context.Context, na
 }
 
 // calculateNewQuota 根据使用情况和扩缩容规则计算新的资源配额
-// 核心的配额调整逻辑，支持CPU、内存和Pod数量的动态调整
-func (drqm *DynamicResourceQuotaManager) calculateNewQuota(namespace string, config *ResourceQuotaConfig, usage *ResourceUsage) v1.ResourceList {
-	// 检查冷却期，防止频繁调整配额
-	lastScaling, exists := drqm.metrics.LastScaling[namespace]
-	if exists && time.Since(lastScaling) < 5*time.Minute {
-		return nil // 冷却期内不调整
+// 以当前生效配额为基准双向调整：平滑窗口内平均使用率超过UtilizationThreshold时扩容，
+// 窗口内峰值使用率低于ScaleDownThreshold时缩容，调整目标落在两个阈值之间以避免来回抖动。
+// 结果始终限制在[BaseQuota, MaxQuota]之间；返回nil表示无需更新，同时返回按规则调整过的资源
+func (drqm *DynamicResourceQuotaManager) calculateNewQuota(namespace string, config *ResourceQuotaConfig, now time.Time) (v1.ResourceList, []v1.ResourceName) {
+	applied := drqm.metrics.CurrentQuota[namespace]
+	current := boundQuota(applied, config)
+	needsUpdate := applied == nil || !equality.Semantic.DeepEqual(current, applied)
+
+	samples := usageWindow(drqm.metrics.UsageHistory[namespace], now.Add(-smoothingWindow(config)))
+	if len(samples) == 0 {
+		if needsUpdate {
+			return current, nil
+		}
+		return nil, nil
 	}
 
-	// 基于基础配额创建新配额
-	newQuota := config.BaseQuota.DeepCopy()
-	needsUpdate := false
+	newQuota := current.DeepCopy()
+	var scaled []v1.ResourceName
 
 	// 遍历所有扩缩容规则
 	for _, rule := range config.ScalingRules {
-		var currentValue float64
-		var resourceName v1.ResourceName
+		resourceName, ok := scalingResourceName(rule.MetricType)
+		if !ok || rule.UtilizationThreshold <= 0 || rule.ScaleFactor <= 0 {
+			continue
+		}
+		if rule.UtilizationThreshold >= 1 {
+			// 阈值是使用率而不是绝对用量，超出范围的规则不会生效
+			klog.Warningf("Ignoring %s rule for namespace %s: utilization threshold %g is not a ratio in (0, 1)",
+				rule.MetricType, namespace, rule.UtilizationThreshold)
+			continue
+		}
+		quota, exists := newQuota[resourceName]
+		if !exists || quota.IsZero() {
+			continue
+		}
 
-		// 根据监控指标类型获取当前值和对应的资源名称
-		switch rule.MetricType {
-		case "cpu_usage":
-			currentValue = usage.CPU
-			resourceName = v1.ResourceRequestsCPU
-		case "memory_usage":
-			currentValue = usage.Memory
-			resourceName = v1.ResourceRequestsMemory
-		case "pod_count":
-			currentValue = float64(usage.Pods)
-			resourceName = v1.ResourcePods
-		default:
+		// 按规则检查冷却期，防止频繁调整配额
+		cooldown := rule.CooldownTime
+		if cooldown == 0 {
+			cooldown = defaultDynamicQuotaCooldown
+		}
+		if last, exists := drqm.metrics.ResourceScaling[namespace][resourceName]; exists && now.Sub(last) < cooldown {
 			continue
 		}
 
-		// 检查是否超过阈值，需要扩容
-		if currentValue > rule.Threshold {
-			currentQuota := newQuota[resourceName]
-			var newValue resource.Quantity
-
-			// 根据资源类型计算新的配额值
-			switch resourceName {
-			case v1.ResourceRequestsCPU:
-				newValue = resource.MustParse(fmt.Sprintf("%.1f", currentValue*rule.ScaleFactor))
-			case v1.ResourceRequestsMemory:
-				newValue = resource.MustParse(fmt.Sprintf("%.1fGi", currentValue*rule.ScaleFactor))
-			case v1.ResourcePods:
-				newValue = resource.MustParse(fmt.Sprintf("%.0f", currentValue*rule.ScaleFactor))
-			}
+		scaleDownThreshold := rule.ScaleDownThreshold
+		if scaleDownThreshold <= 0 || scaleDownThreshold >= rule.UtilizationThreshold {
+			scaleDownThreshold = rule.UtilizationThreshold / 2
+		}
+		// 调整后的使用率落在两个阈值的中点，保证调整后不会立即触发反向调整
+		targetUtilization := (rule.UtilizationThreshold + scaleDownThreshold) / 2
 
-			// 检查是否超过最大配额限制
-			maxQuota := config.MaxQuota[resourceName]
-			if newValue.Cmp(maxQuota) > 0 {
-				newValue = maxQuota
-			}
+		average, peak := usageStats(samples, rule.MetricType)
+		capacity := quantityToUsage(resourceName, quota)
 
-			// 只有当新值与当前值不同时才更新
-			if newValue.Cmp(currentQuota) != 0 {
-				newQuota[resourceName] = newValue
-				needsUpdate = true
-			}
+		var target float64
+		switch {
+		case average/capacity > rule.UtilizationThreshold:
+			target = math.Max(capacity*math.Max(rule.ScaleFactor, 1), average/targetUtilization)
+		case peak/capacity < scaleDownThreshold:
+			target = math.Max(capacity/math.Max(rule.ScaleFactor, 1), peak/targetUtilization)
+		default:
+			continue
+		}
+
+		newValue := boundQuantity(resourceName, usageToQuantity(resourceName, target), config)
+		if newValue.Cmp(quota) != 0 {
+			klog.V(2).Infof("Scaling %s quota for namespace %s from %s to %s (average %.2f, peak %.2f)",
+				resourceName, namespace, quota.String(), newValue.String(), average, peak)
+			newQuota[resourceName] = newValue
+			scaled = append(scaled, resourceName)
 		}
 	}
 
 	// 返回新配额或nil（如果不需要更新）
-	if needsUpdate {
-		return newQuota
+	if needsUpdate || len(scaled) > 0 {
+		return newQuota, scaled
+	}
+	return nil, nil
+}
+
+// boundQuota 以最近应用的配额为基准，补齐并限制在[BaseQuota, MaxQuota]之间
+// 尚未应用过配额或配置调整了上下限时，据此修正当前配额
+func boundQuota(applied v1.ResourceList, config *ResourceQuotaConfig) v1.ResourceList {
+	quota := config.BaseQuota.DeepCopy()
+	for name, value := range applied {
+		if _, managed := config.BaseQuota[name]; managed {
+			quota[name] = boundQuantity(name, value, config)
+		}
+	}
+	return quota
+}
+
+// boundQuantity 将配额值限制在[BaseQuota, MaxQuota]之间
+func boundQuantity(name v1.ResourceName, value resource.Quantity, config *ResourceQuotaConfig) resource.Quantity {
+	if base, exists := config.BaseQuota[name]; exists && value.Cmp(base) < 0 {
+		value = base
+	}
+	if max, exists := config.MaxQuota[name]; exists && value.Cmp(max) > 0 {
+		value = max
+	}
+	return value
+}
+
+// smoothingWindow 返回配置的平滑窗口，未配置时使用默认值
+func smoothingWindow(config *ResourceQuotaConfig) time.Duration {
+	if config.SmoothingWindow == 0 {
+		return defaultSmoothingWindow
+	}
+	return config.SmoothingWindow
+}
+
+// usageWindow 返回since之后的使用记录，窗口内没有记录时使用最近一条
+func usageWindow(history []ResourceUsage, since time.Time) []ResourceUsage {
+	for i, record := range history {
+		if !record.Timestamp.Before(since) {
+			return history[i:]
+		}
+	}
+	if len(history) > 0 {
+		return history[len(history)-1:]
 	}
 	return nil
 }
 
-// updateResourceQuota 更新或创建命名空间的ResourceQuota对象
-// 将计算出的新配额应用到Kubernetes集群中
-func (drqm *DynamicResourceQuotaManager) updateResourceQuota(ctx context.Context, namespace string, quota v1.ResourceList) error {
-	// 构建ResourceQuota对象
-	resourceQuota := &v1.ResourceQuota{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "dynamic-quota", // 固定名称，便于管理
-			Namespace: namespace,
-		},
-		Spec: v1.ResourceQuotaSpec{
-			Hard: quota, // 设置硬限制
-		},
+// usageStats 计算窗口内指标的平均值和峰值
+func usageStats(samples []ResourceUsage, metricType string) (average, peak float64) {
+	for _, sample := range samples {
+		var value float64
+		switch metricType {
+		case "cpu_usage":
+			value = sample.CPU
+		case "memory_usage":
+			value = sample.Memory
+		case "pod_count":
+			value = float64(sample.Pods)
+		}
+		average += value
+		peak = math.Max(peak, value)
+	}
+	return average / float64(len(samples)), peak
+}
+
+// scalingResourceName 返回监控指标对应的配额资源名称
+func scalingResourceName(metricType string) (v1.ResourceName, bool) {
+	switch metricType {
+	case "cpu_usage":
+		return v1.ResourceRequestsCPU, true
+	case "memory_usage":
+		return v1.ResourceRequestsMemory, true
+	case "pod_count":
+		return v1.ResourcePods, true
+	}
+	return "", false
+}
+
+// quantityToUsage 将配额值转换为与ResourceUsage相同的单位（CPU核心数、内存GB、Pod数量）
+func quantityToUsage(name v1.ResourceName, quantity resource.Quantity) float64 {
+	switch name {
+	case v1.ResourceRequestsCPU:
+		return float64(quantity.MilliValue()) / 1000
+	case v1.ResourceRequestsMemory:
+		return float64(quantity.Value()) / (1024 * 1024 * 1024)
 	}
+	return float64(quantity.Value())
+}
+
+// usageToQuantity 将ResourceUsage单位的数值向上取整转换为配额值
+func usageToQuantity(name v1.ResourceName, value float64) resource.Quantity {
+	switch name {
+	case v1.ResourceRequestsCPU:
+		return *resource.NewMilliQuantity(int64(math.Ceil(value*1000)), resource.DecimalSI)
+	case v1.ResourceRequestsMemory:
+		// 按MiB取整，避免出现难以阅读的字节数
+		mebibytes := int64(math.Ceil(value * 1024))
+		return *resource.NewQuantity(mebibytes*1024*1024, resource.BinarySI)
+	}
+	return *resource.NewQuantity(int64(math.Ceil(value)), resource.DecimalSI)
+}
 
-	// 尝试更新现有的ResourceQuota
-	_, err := drqm.client.CoreV1().ResourceQuotas(namespace).Update(ctx, resourceQuota, metav1.UpdateOptions{})
+// updateResourceQuota 通过服务端应用（Server-Side Apply）更新或创建命名空间的ResourceQuota对象
+// 只声明管理器负责的配额项，limits、存储、对象数量等其他键保持不变；
+// 其他字段管理器（如kubectl edit）修改过的配额项会产生冲突，此时不强制覆盖而是返回错误。
+// 旧版本通过Update创建的对象属于其他字段管理器，首次应用时强制接管本管理器声明的配额项，之后不再强制
+func (drqm *DynamicResourceQuotaManager) updateResourceQuota(ctx context.Context, namespace string, quota v1.ResourceList) error {
+	resourceQuota := corev1ac.ResourceQuota(DynamicQuotaName, namespace).
+		WithLabels(map[string]string{
+			"app.kubernetes.io/managed-by": DynamicQuotaFieldManager,
+		}).
+		WithSpec(corev1ac.ResourceQuotaSpec().WithHard(quota))
+
+	migrate, err := drqm.needsOwnershipMigration(ctx, namespace)
 	if err != nil {
-		// 如果ResourceQuota不存在则创建新的
-		_, err = drqm.client.CoreV1().ResourceQuotas(namespace).Create(ctx, resourceQuota, metav1.CreateOptions{})
+		return err
+	}
+	if migrate {
+		klog.Infof("Taking over quota entries of legacy resourcequota %s/%s as field manager %s", namespace, DynamicQuotaName, DynamicQuotaFieldManager)
 	}
 
+	_, err = drqm.client.CoreV1().ResourceQuotas(namespace).Apply(ctx, resourceQuota, metav1.ApplyOptions{
+		FieldManager: DynamicQuotaFieldManager,
+		Force:        migrate,
+	})
+	if errors.IsConflict(err) {
+		return fmt.Errorf("resourcequota %s/%s has quota entries managed by another field manager, remove them or hand them back to %s: %v",
+			namespace, DynamicQuotaName, DynamicQuotaFieldManager, err)
+	}
 	return err
 }
 
+// needsOwnershipMigration 判断ResourceQuota是否由旧版本的Update/Create路径创建、从未被本管理器应用过
+func (drqm *DynamicResourceQuotaManager) needsOwnershipMigration(ctx context.Context, namespace string) (bool, error) {
+	existing, err := drqm.client.CoreV1().ResourceQuotas(namespace).Get(ctx, DynamicQuotaName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get resourcequota %s/%s: %v", namespace, DynamicQuotaName, err)
+	}
+
+	for _, entry := range existing.ManagedFields {
+		if entry.Manager == DynamicQuotaFieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // recordUsage 记录命名空间的资源使用历史
 // 维护滑动窗口的使用数据，用于趋势分析和决策
 func (drqm *DynamicResourceQuotaManager) recordUsage(namespace string, usage *ResourceUsage) {
@@ -3,14 +3,13 @@
 package scheduler
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 )
 
@@ -22,6 +21,13 @@ type HealthCheck struct {
 	Timeout     time.Duration `json:"timeout"`
 	Retries     int           `json:"retries"`
 	ExpectedCode int          `json:"expectedCode"`
+
+	// Type 检查类型：http（默认）、tcp、lease、prometheus、pendingPods，对应的探针配置见health-probes.go
+	Type        string            `json:"type,omitempty"`
+	TCP         *TCPProbe         `json:"tcp,omitempty"`
+	Lease       *LeaseProbe       `json:"lease,omitempty"`
+	Prometheus  *PrometheusProbe  `json:"prometheus,omitempty"`
+	PendingPods *PendingPodsProbe `json:"pendingPods,omitempty"`
 }
 
 // HealthStatus 健康状态
@@ -40,6 +46,8 @@ type HealthChecker struct {
 	mu          sync.RWMutex
 	alertMgr    *AlertManager
 	httpClient  *http.Client
+	client      kubernetes.Interface // lease和pendingPods检查使用
+	alertsOff   bool                 // 不发送告警，多副本部署时非领导者副本关闭告警
 	ctx         context.Context
 	cancel      context.CancelFunc
 }
@@ -59,6 +67,32 @@ func NewHealthChecker(checks []HealthCheck, alertMgr *AlertManager) *HealthCheck
 	}
 }
 
+// WithKubernetesClient 设置lease和pendingPods检查使用的Kubernetes客户端
+func (hc *HealthChecker) WithKubernetesClient(client kubernetes.Interface) *HealthChecker {
+	hc.client = client
+	return hc
+}
+
+// SetAlerting 开启或关闭状态变化告警，默认开启；多副本部署时只由领导者发送告警
+// 开启时为当前不健康的检查补发告警，新的领导者接管时不会漏掉仍在持续的故障
+func (hc *HealthChecker) SetAlerting(enabled bool) {
+	hc.mu.Lock()
+	hc.alertsOff = !enabled
+	var unhealthy []HealthStatus
+	if enabled {
+		for _, status := range hc.statuses {
+			if !status.Healthy {
+				unhealthy = append(unhealthy, status)
+			}
+		}
+	}
+	hc.mu.Unlock()
+
+	for _, status := range unhealthy {
+		hc.handleHealthStatusChange(status, HealthStatus{Name: status.Name, Healthy: true})
+	}
+}
+
 // Start 启动健康检查
 func (hc *HealthChecker) Start() {
 	klog.Info("Starting health checker...")
@@ -76,6 +110,9 @@ func (hc *HealthChecker) Stop() {
 
 // runHealthCheck 运行单个健康检查
 func (hc *HealthChecker) runHealthCheck(check HealthCheck) {
+	if check.Interval <= 0 {
+		check.Interval = 30 * time.Second
+	}
 	ticker := time.NewTicker(check.Interval)
 	defer ticker.Stop()
 
@@ -94,41 +131,37 @@ func (hc *HealthChecker) performCheck(check HealthCheck) {
 	start := time.Now()
 	var lastErr error
 
-	// 重试机制
-	for i := 0; i <= check.Retries; i++ {
-		ctx, cancel := context.WithTimeout(hc.ctx, check.Timeout)
-		req, err := http.NewRequestWithContext(ctx, "GET", check.URL, nil)
-		if err != nil {
-			lastErr = err
-			cancel()
-			continue
-		}
+	probe, err := hc.probeFor(check)
+	if err != nil {
+		lastErr = fmt.Errorf("invalid health check: %v", err)
+	}
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
 
-		resp, err := hc.httpClient.Do(req)
+	// 重试机制
+	for i := 0; probe != nil && i <= check.Retries; i++ {
+		ctx, cancel := context.WithTimeout(hc.ctx, timeout)
+		err := probe.Probe(ctx)
 		cancel()
 
-		if err != nil {
-			lastErr = err
-			if i < check.Retries {
-				time.Sleep(time.Second * time.Duration(i+1))
-				continue
-			}
-		} else {
-			resp.Body.Close()
-			if resp.StatusCode == check.ExpectedCode {
-				// 健康检查成功
-				status := HealthStatus{
-					Name:      check.Name,
-					Healthy:   true,
-					Message:   "OK",
-					Timestamp: time.Now(),
-					Latency:   time.Since(start),
-				}
-				hc.updateStatus(status)
-				return
-			} else {
-				lastErr = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if err == nil {
+			// 健康检查成功
+			status := HealthStatus{
+				Name:      check.Name,
+				Healthy:   true,
+				Message:   "OK",
+				Timestamp: time.Now(),
+				Latency:   time.Since(start),
 			}
+			hc.updateStatus(status)
+			return
+		}
+
+		lastErr = err
+		if i < check.Retries {
+			time.Sleep(time.Second * time.Duration(i+1))
 		}
 	}
 
@@ -160,7 +193,10 @@ func (hc *HealthChecker) updateStatus(status HealthStatus) {
 
 // handleHealthStatusChange 处理健康状态变化
 func (hc *HealthChecker) handleHealthStatusChange(current, previous HealthStatus) {
-	if hc.alertMgr == nil {
+	hc.mu.RLock()
+	alertsOff := hc.alertsOff
+	hc.mu.RUnlock()
+	if hc.alertMgr == nil || alertsOff {
 		return
 	}
 
@@ -168,10 +204,10 @@ func (hc *HealthChecker) handleHealthStatusChange(current, previous HealthStatus
 	var message string
 
 	if !current.Healthy {
-		alertType = "critical"
+		alertType = AlertCritical
 		message = fmt.Sprintf("Health check %s failed: %s", current.Name, current.Message)
 	} else {
-		alertType = "resolved"
+		alertType = AlertResolved
 		message = fmt.Sprintf("Health check %s recovered", current.Name)
 	}
 
@@ -185,7 +221,9 @@ func (hc *HealthChecker) handleHealthStatusChange(current, previous HealthStatus
 		},
 	}
 
-	hc.alertMgr.SendAlert(alert)
+	if err := hc.alertMgr.SendAlert(alert); err != nil {
+		klog.Errorf("Failed to send alert for health check %s: %v", current.Name, err)
+	}
 }
 
 // GetStatus 获取健康状态
@@ -220,54 +258,3 @@ func (hc *HealthChecker) IsHealthy() bool {
 	}
 	return true
 }
-
-// AlertManager 告警管理器
-type AlertManager struct {
-	webhookURL string
-	httpClient *http.Client
-}
-
-// AlertEvent 告警事件
-type AlertEvent struct {
-	Type      string            `json:"type"`
-	Message   string            `json:"message"`
-	Timestamp time.Time         `json:"timestamp"`
-	Labels    map[string]string `json:"labels"`
-}
-
-// NewAlertManager 创建告警管理器
-func NewAlertManager(webhookURL string) *AlertManager {
-	return &AlertManager{
-		webhookURL: webhookURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
-}
-
-// SendAlert 发送告警
-func (am *AlertManager) SendAlert(alert AlertEvent) error {
-	if am.webhookURL == "" {
-		klog.V(2).Infof("Alert: %s - %s", alert.Type, alert.Message)
-		return nil
-	}
-
-	payload, err := json.Marshal(alert)
-	if err != nil {
-		return fmt.Errorf("failed to marshal alert: %v", err)
-	}
-
-	resp, err := am.httpClient.Post(am.webhookURL, "application/json", 
-		bytes.NewBuffer(payload))
-	if err != nil {
-		return fmt.Errorf("failed to send alert: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
-	}
-
-	klog.V(2).Infof("Alert sent successfully: %s", alert.Message)
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,156 @@
+// health-findings.go
+// 健康发现和恢复规则 - 健康检查返回带组件、条件、严重程度和证据的发现，
+// 恢复规则表将发现映射为恢复动作，错误信息的措辞不再影响恢复
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// 健康发现的组件
+const (
+	ComponentScheduler = "scheduler"
+	ComponentNodes     = "nodes"
+	ComponentQueue     = "queue"
+)
+
+// 健康发现的条件
+const (
+	ConditionCheckFailed         = "check_failed"          // 无法完成检查，如API请求失败
+	ConditionSchedulerMissing    = "scheduler_missing"     // 没有调度器Pod
+	ConditionSchedulerNotRunning = "scheduler_not_running" // 没有运行中的调度器Pod
+	ConditionNoNodes             = "no_nodes"              // 集群没有节点
+	ConditionNodesNotReady       = "nodes_not_ready"       // 就绪节点少于一半
+	ConditionQueueOverloaded     = "queue_overloaded"      // Pending Pod过多
+	ConditionSchedulingStalled   = "scheduling_stalled"    // 长时间Pending的Pod过多
+)
+
+// HealthFinding 一次健康检查发现的问题
+type HealthFinding struct {
+	Component string            `json:"component"`
+	Condition string            `json:"condition"`
+	Severity  string            `json:"severity"` // critical或warning
+	Message   string            `json:"message"`
+	Evidence  map[string]string `json:"evidence,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// String 返回发现的摘要
+func (f HealthFinding) String() string {
+	return fmt.Sprintf("%s/%s (%s): %s", f.Component, f.Condition, f.Severity, f.Message)
+}
+
+// markUnhealthy 将发现记录到健康状态
+func (f *HealthFinding) markUnhealthy(health *HealthStatus) (*HealthStatus, *HealthFinding) {
+	f.Timestamp = health.Timestamp
+	health.Healthy = false
+	health.Message = f.Message
+	return health, f
+}
+
+// formatFindings 返回发现的摘要，按严重程度排序
+func formatFindings(findings []HealthFinding) string {
+	sorted := append([]HealthFinding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity == AlertCritical && sorted[j].Severity != AlertCritical
+	})
+	parts := make([]string, 0, len(sorted))
+	for _, finding := range sorted {
+		parts = append(parts, finding.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// recoveryRule 将健康发现映射为恢复动作，Enabled根据恢复策略决定规则是否生效
+type recoveryRule struct {
+	Component string
+	Condition string
+	Action    string
+	Target    string
+	Enabled   func(strategies RecoveryStrategies) bool
+}
+
+// recoveryRules 恢复规则表，同一发现按顺序执行匹配的动作
+// 检查失败、没有调度器Pod和没有节点不是这些动作能修复的，没有对应规则，只报告
+var recoveryRules = []recoveryRule{
+	{
+		Component: ComponentScheduler,
+		Condition: ConditionSchedulerNotRunning,
+		Action:    ActionRestartScheduler,
+		Target:    "kube-scheduler",
+		Enabled:   func(s RecoveryStrategies) bool { return s.SchedulerRestart },
+	},
+	{
+		Component: ComponentNodes,
+		Condition: ConditionNodesNotReady,
+		Action:    ActionDrainUnhealthyNodes,
+		Target:    "cluster",
+		Enabled:   func(s RecoveryStrategies) bool { return s.NodeDraining },
+	},
+	{
+		Component: ComponentQueue,
+		Condition: ConditionQueueOverloaded,
+		Action:    ActionEvictStuckPods,
+		Target:    "cluster",
+		Enabled:   func(s RecoveryStrategies) bool { return s.PodEviction },
+	},
+	{
+		Component: ComponentQueue,
+		Condition: ConditionSchedulingStalled,
+		Action:    ActionEvictStuckPods,
+		Target:    "cluster",
+		Enabled:   func(s RecoveryStrategies) bool { return s.PodEviction },
+	},
+	{
+		Component: ComponentQueue,
+		Condition: ConditionQueueOverloaded,
+		Action:    ActionRebalanceResources,
+		Target:    "cluster",
+		Enabled:   func(s RecoveryStrategies) bool { return s.ResourceRebalancing },
+	},
+	{
+		Component: ComponentQueue,
+		Condition: ConditionSchedulingStalled,
+		Action:    ActionRebalanceResources,
+		Target:    "cluster",
+		Enabled:   func(s RecoveryStrategies) bool { return s.ResourceRebalancing },
+	},
+}
+
+// planRecoveryActions 按恢复规则表为发现确定恢复动作，同一动作只执行一次
+func planRecoveryActions(findings []HealthFinding, strategies RecoveryStrategies) []RecoveryAction {
+	var actions []RecoveryAction
+	planned := make(map[string]bool)
+	for _, finding := range findings {
+		matched := false
+		for _, rule := range recoveryRules {
+			if rule.Component != finding.Component || rule.Condition != finding.Condition {
+				continue
+			}
+			matched = true
+			if !rule.Enabled(strategies) {
+				klog.V(2).Infof("Recovery action %s for %s/%s is disabled", rule.Action, finding.Component, finding.Condition)
+				continue
+			}
+			if planned[rule.Action] {
+				continue
+			}
+			planned[rule.Action] = true
+			actions = append(actions, RecoveryAction{
+				Type:      rule.Action,
+				Target:    rule.Target,
+				Timestamp: time.Now(),
+				Status:    RecoveryActionPending,
+			})
+		}
+		if !matched {
+			klog.Warningf("No recovery rule for %s, manual intervention required", finding)
+		}
+	}
+	return actions
+}
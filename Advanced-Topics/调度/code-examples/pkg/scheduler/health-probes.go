@@ -0,0 +1,451 @@
+// health-probes.go
+// 健康检查探针 - HTTP、TCP连接、租约新鲜度、Prometheus查询阈值和待调度Pod时长
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// 健康检查类型
+const (
+	HealthCheckHTTP        = "http"
+	HealthCheckTCP         = "tcp"
+	HealthCheckLease       = "lease"
+	HealthCheckPrometheus  = "prometheus"
+	HealthCheckPendingPods = "pendingPods"
+)
+
+// TCPProbe TCP连接探针，能建立连接即视为健康
+type TCPProbe struct {
+	Address string `json:"address"` // host:port
+}
+
+// LeaseProbe 租约新鲜度探针，用于检查调度器领导者是否在按时续约
+type LeaseProbe struct {
+	Namespace    string          `json:"namespace"`    // 默认kube-system
+	Name         string          `json:"name"`         // 如kube-scheduler
+	MaxStaleness metav1.Duration `json:"maxStaleness"` // renewTime+leaseDuration之后允许的额外时间，默认5s
+}
+
+// PrometheusProbe Prometheus查询阈值探针，查询结果的每个序列都满足条件时视为健康
+type PrometheusProbe struct {
+	URL       string  `json:"url"`       // Prometheus地址，如http://prometheus.monitoring:9090
+	Query     string  `json:"query"`     // PromQL即时查询
+	Operator  string  `json:"operator"`  // 健康条件：<、<=、>、>=、==、!=
+	Threshold float64 `json:"threshold"` // 阈值
+}
+
+// PendingPodsProbe 待调度Pod时长探针，等待调度超过MaxAge的Pod数量超过MaxPods时视为不健康
+type PendingPodsProbe struct {
+	Namespace     string          `json:"namespace"`     // 为空时检查所有命名空间
+	SchedulerName string          `json:"schedulerName"` // 为空时检查所有调度器
+	MaxAge        metav1.Duration `json:"maxAge"`        // 待调度时长阈值
+	MaxPods       int             `json:"maxPods"`       // 允许超过时长阈值的Pod数量，默认0
+}
+
+// HealthCheckConfig 健康检查配置文件，对应scheduler-health-config中的health-checks.json
+type HealthCheckConfig struct {
+	Checks   []HealthCheck        `json:"checks"`
+	Alerting HealthAlertingConfig `json:"alerting"`
+}
+
+// HealthAlertingConfig 健康检查告警配置，见NewAlertManagerFromConfig
+type HealthAlertingConfig struct {
+	WebhookURL string                `json:"webhookUrl"`
+	Enabled    bool                  `json:"enabled"`
+	Cooldown   metav1.Duration       `json:"cooldown"` // 默认路由的重复通知间隔
+	Receivers  []AlertReceiverConfig `json:"receivers,omitempty"`
+	Route      AlertRoute            `json:"route,omitempty"`  // 默认路由
+	Routes     []AlertRoute          `json:"routes,omitempty"` // 按顺序匹配的路由
+}
+
+// HealthProbe 健康探针，返回nil表示健康
+type HealthProbe interface {
+	Probe(ctx context.Context) error
+}
+
+// UnmarshalJSON 支持interval和timeout使用"30s"形式的时长字符串
+func (c *HealthCheck) UnmarshalJSON(data []byte) error {
+	type plain HealthCheck
+	var raw struct {
+		plain
+		Interval metav1.Duration `json:"interval"`
+		Timeout  metav1.Duration `json:"timeout"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = HealthCheck(raw.plain)
+	c.Interval = raw.Interval.Duration
+	c.Timeout = raw.Timeout.Duration
+	return nil
+}
+
+// ParseHealthCheckConfig 解析health-checks.json并校验每个检查
+func ParseHealthCheckConfig(data []byte) (*HealthCheckConfig, error) {
+	var config HealthCheckConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse health check config: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range config.Checks {
+		check := &config.Checks[i]
+		if check.Name == "" {
+			return nil, fmt.Errorf("checks[%d]: name is required", i)
+		}
+		if names[check.Name] {
+			return nil, fmt.Errorf("checks[%d]: duplicate name %q", i, check.Name)
+		}
+		names[check.Name] = true
+		if err := check.validate(); err != nil {
+			return nil, fmt.Errorf("check %s: %v", check.Name, err)
+		}
+	}
+	return &config, nil
+}
+
+// LoadHealthCheckConfigFile 从挂载的ConfigMap文件加载健康检查配置
+func LoadHealthCheckConfigFile(path string) (*HealthCheckConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read health check config: %v", err)
+	}
+	return ParseHealthCheckConfig(data)
+}
+
+// LoadHealthCheckConfigFromConfigMap 从scheduler-health-config ConfigMap加载健康检查配置
+func LoadHealthCheckConfigFromConfigMap(ctx context.Context, client kubernetes.Interface, namespace, name string) (*HealthCheckConfig, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configmap %s/%s: %v", namespace, name, err)
+	}
+	data, exists := cm.Data["health-checks.json"]
+	if !exists {
+		return nil, fmt.Errorf("configmap %s/%s has no health-checks.json", namespace, name)
+	}
+	return ParseHealthCheckConfig([]byte(data))
+}
+
+// checkType 返回检查类型，未指定时根据配置的探针推断，默认为HTTP
+func (c *HealthCheck) checkType() string {
+	switch {
+	case c.Type != "":
+		return c.Type
+	case c.TCP != nil:
+		return HealthCheckTCP
+	case c.Lease != nil:
+		return HealthCheckLease
+	case c.Prometheus != nil:
+		return HealthCheckPrometheus
+	case c.PendingPods != nil:
+		return HealthCheckPendingPods
+	}
+	return HealthCheckHTTP
+}
+
+// validate 校验检查类型与对应探针配置
+func (c *HealthCheck) validate() error {
+	switch c.checkType() {
+	case HealthCheckHTTP:
+		if c.URL == "" {
+			return fmt.Errorf("url is required for http checks")
+		}
+	case HealthCheckTCP:
+		if c.TCP == nil || c.TCP.Address == "" {
+			return fmt.Errorf("tcp.address is required for tcp checks")
+		}
+	case HealthCheckLease:
+		if c.Lease == nil || c.Lease.Name == "" {
+			return fmt.Errorf("lease.name is required for lease checks")
+		}
+	case HealthCheckPrometheus:
+		if c.Prometheus == nil || c.Prometheus.URL == "" || c.Prometheus.Query == "" {
+			return fmt.Errorf("prometheus.url and prometheus.query are required for prometheus checks")
+		}
+		if _, err := compareValue(0, c.Prometheus.Operator, 0); err != nil {
+			return err
+		}
+	case HealthCheckPendingPods:
+		if c.PendingPods == nil || c.PendingPods.MaxAge.Duration <= 0 {
+			return fmt.Errorf("pendingPods.maxAge is required for pendingPods checks")
+		}
+	default:
+		return fmt.Errorf("unknown check type %q", c.Type)
+	}
+	return nil
+}
+
+// probeFor 根据检查配置创建探针
+func (hc *HealthChecker) probeFor(check HealthCheck) (HealthProbe, error) {
+	if err := check.validate(); err != nil {
+		return nil, err
+	}
+
+	switch check.checkType() {
+	case HealthCheckTCP:
+		return &tcpProbe{address: check.TCP.Address}, nil
+	case HealthCheckLease:
+		if hc.client == nil {
+			return nil, fmt.Errorf("lease checks require a kubernetes client")
+		}
+		return &leaseProbe{client: hc.client, config: *check.Lease}, nil
+	case HealthCheckPrometheus:
+		return &prometheusProbe{httpClient: hc.httpClient, config: *check.Prometheus}, nil
+	case HealthCheckPendingPods:
+		if hc.client == nil {
+			return nil, fmt.Errorf("pendingPods checks require a kubernetes client")
+		}
+		return &pendingPodsProbe{client: hc.client, config: *check.PendingPods}, nil
+	}
+
+	expectedCode := check.ExpectedCode
+	if expectedCode == 0 {
+		expectedCode = http.StatusOK
+	}
+	return &httpProbe{httpClient: hc.httpClient, url: check.URL, expectedCode: expectedCode}, nil
+}
+
+// httpProbe HTTP GET探针
+type httpProbe struct {
+	httpClient   *http.Client
+	url          string
+	expectedCode int
+}
+
+func (p *httpProbe) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != p.expectedCode {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// tcpProbe TCP连接探针
+type tcpProbe struct {
+	address string
+}
+
+func (p *tcpProbe) Probe(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// leaseProbe 租约新鲜度探针
+type leaseProbe struct {
+	client kubernetes.Interface
+	config LeaseProbe
+}
+
+func (p *leaseProbe) Probe(ctx context.Context) error {
+	namespace := p.config.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceSystem
+	}
+	grace := p.config.MaxStaleness.Duration
+	if grace == 0 {
+		grace = 5 * time.Second
+	}
+
+	lease, err := p.client.CoordinationV1().Leases(namespace).Get(ctx, p.config.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("leader lease %s/%s not readable: %v", namespace, p.config.Name, err)
+	}
+	return leaseFreshness(lease, grace, time.Now())
+}
+
+// leaseFreshness 租约存在持有者且renewTime+leaseDuration+grace未过期时返回nil
+func leaseFreshness(lease *coordinationv1.Lease, grace time.Duration, now time.Time) error {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return fmt.Errorf("leader lease %s/%s has no holder", lease.Namespace, lease.Name)
+	}
+	if lease.Spec.RenewTime == nil {
+		return fmt.Errorf("leader lease %s/%s was never renewed", lease.Namespace, lease.Name)
+	}
+
+	duration := 15 * time.Second
+	if lease.Spec.LeaseDurationSeconds != nil {
+		duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+	}
+	if age := now.Sub(lease.Spec.RenewTime.Time); age > duration+grace {
+		return fmt.Errorf("leader lease %s/%s held by %s not renewed for %v",
+			lease.Namespace, lease.Name, *lease.Spec.HolderIdentity, age.Round(time.Second))
+	}
+	return nil
+}
+
+// prometheusProbe Prometheus即时查询探针
+type prometheusProbe struct {
+	httpClient *http.Client
+	config     PrometheusProbe
+}
+
+// prometheusQueryResponse Prometheus /api/v1/query响应
+type prometheusQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func (p *prometheusProbe) Probe(ctx context.Context) error {
+	endpoint := p.config.URL + "/api/v1/query?" + url.Values{"query": {p.config.Query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("prometheus query failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result prometheusQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid prometheus response (status %d): %v", resp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("prometheus query failed: %s", result.Error)
+	}
+
+	values, err := prometheusValues(result.Data.ResultType, result.Data.Result)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("prometheus query %q returned no data", p.config.Query)
+	}
+	for _, value := range values {
+		healthy, _ := compareValue(value, p.config.Operator, p.config.Threshold)
+		if !healthy {
+			return fmt.Errorf("query %q returned %g, expected %s %g", p.config.Query, value, p.config.Operator, p.config.Threshold)
+		}
+	}
+	return nil
+}
+
+// prometheusValues 从scalar或vector结果中取出数值
+func prometheusValues(resultType string, raw json.RawMessage) ([]float64, error) {
+	var samples [][]interface{}
+	switch resultType {
+	case "scalar":
+		var sample []interface{}
+		if err := json.Unmarshal(raw, &sample); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	case "vector":
+		var series []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(raw, &series); err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			samples = append(samples, s.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported prometheus result type %q", resultType)
+	}
+
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		if len(sample) != 2 {
+			return nil, fmt.Errorf("malformed prometheus sample %v", sample)
+		}
+		text, ok := sample[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("malformed prometheus sample value %v", sample[1])
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// compareValue 按运算符比较，运算符不合法时返回错误
+func compareValue(value float64, operator string, threshold float64) (bool, error) {
+	switch operator {
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", operator)
+}
+
+// pendingPodsProbe 待调度Pod时长探针
+type pendingPodsProbe struct {
+	client kubernetes.Interface
+	config PendingPodsProbe
+}
+
+func (p *pendingPodsProbe) Probe(ctx context.Context) error {
+	pods, err := p.client.CoreV1().Pods(p.config.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pending pods: %v", err)
+	}
+
+	now := time.Now()
+	stuck := 0
+	var oldest string
+	var oldestAge time.Duration
+	for _, pod := range pods.Items {
+		if p.config.SchedulerName != "" && pod.Spec.SchedulerName != p.config.SchedulerName {
+			continue
+		}
+		age := now.Sub(pod.CreationTimestamp.Time)
+		if age <= p.config.MaxAge.Duration {
+			continue
+		}
+		stuck++
+		if age > oldestAge {
+			oldest, oldestAge = pod.Namespace+"/"+pod.Name, age
+		}
+	}
+
+	if stuck > p.config.MaxPods {
+		return fmt.Errorf("%d pods pending longer than %v, oldest %s for %v",
+			stuck, p.config.MaxAge.Duration, oldest, oldestAge.Round(time.Second))
+	}
+	return nil
+}
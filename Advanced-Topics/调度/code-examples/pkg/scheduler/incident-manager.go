@@ -0,0 +1,743 @@
+// incident-manager.go
+// 事件管理 - 恢复策略触发时打开事件，按级别和延迟逐级通知接收器（日志→webhook→寻呼）并执行该级别的恢复动作，
+// 支持确认、解决，触发条件恢复后自动解决，并提供列出和确认事件的HTTP API；设置存储后事件在领导者切换后保留
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// 事件状态
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// incidentLogReceiver 内置的日志接收器名称，未配置接收器的级别使用它
+const incidentLogReceiver = "log"
+
+// defaultEscalationInterval 未配置escalationInterval时级别之间的间隔
+const defaultEscalationInterval = 10 * time.Minute
+
+// incidentEvaluateInterval 检查升级和发送通知的间隔
+const incidentEvaluateInterval = 15 * time.Second
+
+// maxResolvedIncidents 保留的已解决事件数量上限
+const maxResolvedIncidents = 100
+
+// maxIncidentNotifications 每个事件保留的通知记录数量上限，接收器持续失败时重试记录不会无限增长
+const maxIncidentNotifications = 20
+
+// incidentLoadRetryInterval 加载持久化事件失败后的重试间隔
+const incidentLoadRetryInterval = 5 * time.Second
+
+var (
+	errIncidentNotFound = errors.New("incident not found")
+	errIncidentResolved = errors.New("incident already resolved")
+)
+
+// Incident 事件
+type Incident struct {
+	ID             string                 `json:"id"`
+	Key            string                 `json:"key"` // 事件来源，如恢复策略名称，同一来源同时只有一个未解决的事件
+	Summary        string                 `json:"summary"`
+	Severity       string                 `json:"severity"`
+	Labels         map[string]string      `json:"labels,omitempty"`
+	State          string                 `json:"state"`
+	Level          int                    `json:"level"` // 已通知到的升级级别，0表示尚未通知
+	OpenedAt       time.Time              `json:"openedAt"`
+	LastSeen       time.Time              `json:"lastSeen"` // 最近一次触发的时间
+	AcknowledgedAt *time.Time             `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string                 `json:"acknowledgedBy,omitempty"`
+	ResolvedAt     *time.Time             `json:"resolvedAt,omitempty"`
+	ResolvedBy     string                 `json:"resolvedBy,omitempty"` // auto表示触发条件恢复后自动解决
+	Notifications  []IncidentNotification `json:"notifications,omitempty"`
+	Actions        []IncidentActionRun    `json:"actions,omitempty"` // 升级级别执行的恢复动作
+}
+
+// IncidentNotification 事件的一次通知
+type IncidentNotification struct {
+	Level    int       `json:"level"`
+	Receiver string    `json:"receiver"`
+	Status   string    `json:"status"` // firing或resolved
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// IncidentActionRun 事件升级时执行的一次恢复动作
+type IncidentActionRun struct {
+	Level int       `json:"level"`
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// incidentState 事件及其通知状态
+type incidentState struct {
+	Incident
+	healthySince    time.Time            // 触发条件恢复的开始时间，零值表示仍在触发
+	notified        map[string]bool      // 已成功发送firing通知的接收器
+	resolveNotified map[string]bool      // 已成功发送resolved通知的接收器
+	resolveSent     bool                 // resolved通知已全部发送
+	retryAt         map[string]time.Time // 发送失败的接收器下次重试的时间
+}
+
+// escalationStep 升级链中的一级
+type escalationStep struct {
+	level     int
+	delay     time.Duration
+	receivers []string
+	actions   []Action
+}
+
+// incidentActionRequest 待执行的升级动作
+type incidentActionRequest struct {
+	incident string
+	level    int
+	action   Action
+}
+
+// incidentDelivery 待发送的通知
+type incidentDelivery struct {
+	incident     string
+	level        int
+	receiver     AlertReceiver
+	notification *AlertNotification
+}
+
+// IncidentManager 事件管理器
+// 事件打开后依次在各级别的delay到达时通知该级别的接收器并执行该级别的动作，确认后停止升级；
+// 通知失败的接收器在minAlertRetryInterval后重试，解决时向所有已通知的接收器发送resolved通知
+type IncidentManager struct {
+	mu           sync.Mutex
+	steps        []escalationStep
+	receivers    map[string]AlertReceiver
+	resolveAfter time.Duration
+	backoff      wait.Backoff
+	runAction    func(ctx context.Context, action Action) error // 执行升级动作，为空时只记录
+	store        IncidentStore                                  // 持久化存储，为空时事件只保存在内存中
+	dirty        bool                                           // 有未保存的变更
+
+	incidents map[string]*incidentState // 按ID
+	active    map[string]string         // 来源到未解决事件ID
+
+	kick   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewIncidentManager 根据恢复升级配置创建事件管理器
+func NewIncidentManager(config RecoveryEscalation) (*IncidentManager, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	im := &IncidentManager{
+		backoff:   wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: 4, Cap: 30 * time.Second},
+		incidents: make(map[string]*incidentState),
+		active:    make(map[string]string),
+		kick:      make(chan struct{}, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	if err := im.SetEscalation(config); err != nil {
+		cancel()
+		return nil, err
+	}
+	return im, nil
+}
+
+// SetEscalation 替换升级链和接收器，已打开的事件按新的升级链继续升级
+func (im *IncidentManager) SetEscalation(config RecoveryEscalation) error {
+	if err := validateEscalation(&config); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	receivers := map[string]AlertReceiver{incidentLogReceiver: NewLogReceiver(incidentLogReceiver)}
+	for _, receiverConfig := range config.Receivers {
+		receiver, err := NewAlertReceiver(receiverConfig, client)
+		if err != nil {
+			return err
+		}
+		receivers[receiver.Name()] = receiver
+	}
+
+	interval := config.EscalationInterval.Duration
+	if interval == 0 {
+		interval = defaultEscalationInterval
+	}
+	steps := make([]escalationStep, 0, len(config.Levels))
+	for _, level := range config.Levels {
+		step := escalationStep{level: level.Level, delay: level.Delay.Duration, receivers: level.Receivers, actions: level.Actions}
+		if step.delay == 0 {
+			step.delay = time.Duration(level.Level-1) * interval
+		}
+		if len(step.receivers) == 0 {
+			step.receivers = []string{incidentLogReceiver}
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		steps = append(steps, escalationStep{level: 1, receivers: []string{incidentLogReceiver}})
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].level < steps[j].level })
+
+	im.mu.Lock()
+	im.steps = steps
+	im.receivers = receivers
+	im.resolveAfter = config.ResolveAfter.Duration
+	im.mu.Unlock()
+	im.trigger()
+	return nil
+}
+
+// SetActionRunner 设置执行升级动作的函数，事件升级到某一级别时依次执行该级别的动作
+func (im *IncidentManager) SetActionRunner(run func(ctx context.Context, action Action) error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.runAction = run
+}
+
+// WithStore 设置事件的持久化存储，需在Start之前调用
+func (im *IncidentManager) WithStore(store IncidentStore) *IncidentManager {
+	im.store = store
+	return im
+}
+
+// Start 启动升级循环，设置了存储时先加载已保存的事件，加载成功前不升级也不发送通知
+func (im *IncidentManager) Start() {
+	go func() {
+		if im.store != nil {
+			err := wait.PollUntilContextCancel(im.ctx, incidentLoadRetryInterval, true, func(ctx context.Context) (bool, error) {
+				if err := im.load(ctx); err != nil {
+					klog.Errorf("Failed to load incidents, retrying: %v", err)
+					return false, nil
+				}
+				return true, nil
+			})
+			if err != nil {
+				return
+			}
+		}
+
+		ticker := time.NewTicker(incidentEvaluateInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-im.ctx.Done():
+				return
+			case <-ticker.C:
+			case <-im.kick:
+			}
+			im.evaluate(im.ctx, time.Now())
+		}
+	}()
+}
+
+// Stop 停止升级循环
+func (im *IncidentManager) Stop() {
+	im.cancel()
+}
+
+// trigger 让升级循环尽快检查一次
+func (im *IncidentManager) trigger() {
+	select {
+	case im.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Open 为来源打开事件，来源已有未解决的事件时更新其最近触发时间并返回该事件
+func (im *IncidentManager) Open(key, summary, severity string, labels map[string]string) Incident {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	now := time.Now()
+	if id, exists := im.active[key]; exists {
+		state := im.incidents[id]
+		state.LastSeen = now
+		state.Summary = summary
+		state.healthySince = time.Time{}
+		im.dirty = true
+		return state.snapshot()
+	}
+
+	state := &incidentState{
+		Incident: Incident{
+			ID:       key + "-" + utilrand.String(5),
+			Key:      key,
+			Summary:  summary,
+			Severity: severity,
+			Labels:   labels,
+			State:    IncidentOpen,
+			OpenedAt: now,
+			LastSeen: now,
+		},
+	}
+	state.init()
+	im.incidents[state.ID] = state
+	im.active[key] = state.ID
+	im.dirty = true
+	klog.Warningf("Opened incident %s: %s", state.ID, summary)
+	im.trigger()
+	return state.snapshot()
+}
+
+// Recovered 报告来源的触发条件已恢复，持续resolveAfter后自动解决事件
+func (im *IncidentManager) Recovered(key string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	id, exists := im.active[key]
+	if !exists {
+		return
+	}
+	state := im.incidents[id]
+	now := time.Now()
+	if state.healthySince.IsZero() {
+		state.healthySince = now
+		im.dirty = true
+	}
+	if now.Sub(state.healthySince) >= im.resolveAfter {
+		im.resolve(state, "auto", now)
+	}
+}
+
+// Acknowledge 确认事件，确认后不再升级；重复确认不改变确认人
+func (im *IncidentManager) Acknowledge(id, by string) (Incident, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	state, exists := im.incidents[id]
+	switch {
+	case !exists:
+		return Incident{}, errIncidentNotFound
+	case state.State == IncidentResolved:
+		return state.snapshot(), errIncidentResolved
+	case state.State == IncidentOpen:
+		now := time.Now()
+		state.State = IncidentAcknowledged
+		state.AcknowledgedAt = &now
+		state.AcknowledgedBy = by
+		im.dirty = true
+		im.trigger()
+		klog.Infof("Incident %s acknowledged by %s", id, by)
+	}
+	return state.snapshot(), nil
+}
+
+// Resolve 手动解决事件
+func (im *IncidentManager) Resolve(id, by string) (Incident, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	state, exists := im.incidents[id]
+	if !exists {
+		return Incident{}, errIncidentNotFound
+	}
+	if state.State == IncidentResolved {
+		return state.snapshot(), errIncidentResolved
+	}
+	im.resolve(state, by, time.Now())
+	return state.snapshot(), nil
+}
+
+// resolve 解决事件并安排resolved通知，调用方需持有im.mu
+func (im *IncidentManager) resolve(state *incidentState, by string, now time.Time) {
+	state.State = IncidentResolved
+	state.ResolvedAt = &now
+	state.ResolvedBy = by
+	delete(im.active, state.Key)
+	im.dirty = true
+	klog.Infof("Incident %s resolved by %s", state.ID, by)
+	im.trigger()
+}
+
+// Incidents 返回指定状态的事件，state为空时返回全部，按打开时间倒序
+func (im *IncidentManager) Incidents(state string) []Incident {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	incidents := make([]Incident, 0, len(im.incidents))
+	for _, incident := range im.incidents {
+		if state == "" || incident.State == state {
+			incidents = append(incidents, incident.snapshot())
+		}
+	}
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].OpenedAt.After(incidents[j].OpenedAt) })
+	return incidents
+}
+
+// Get 返回指定事件
+func (im *IncidentManager) Get(id string) (Incident, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	state, exists := im.incidents[id]
+	if !exists {
+		return Incident{}, false
+	}
+	return state.snapshot(), true
+}
+
+// evaluate 升级到期的事件，发送通知并执行升级动作，有变更时保存事件
+func (im *IncidentManager) evaluate(ctx context.Context, now time.Time) {
+	var deliveries []incidentDelivery
+	var actions []incidentActionRequest
+
+	im.mu.Lock()
+	for _, state := range im.incidents {
+		switch {
+		case state.State == IncidentOpen:
+			for _, step := range im.steps {
+				if now.Sub(state.OpenedAt) < step.delay {
+					continue
+				}
+				if step.level > state.Level {
+					state.Level = step.level
+					im.dirty = true
+					for _, action := range step.actions {
+						actions = append(actions, incidentActionRequest{incident: state.ID, level: step.level, action: action})
+					}
+				}
+				// 已到期级别中尚未成功通知的接收器，失败后按retryAt重试
+				for _, name := range step.receivers {
+					receiver, exists := im.receivers[name]
+					if !exists || state.notified[name] || now.Before(state.retryAt[name]) {
+						continue
+					}
+					deliveries = append(deliveries, incidentDelivery{
+						incident: state.ID, level: step.level, receiver: receiver,
+						notification: state.notification(name, "firing"),
+					})
+				}
+			}
+		case state.State == IncidentResolved && !state.resolveSent:
+			pending := 0
+			for name := range state.notified {
+				receiver, exists := im.receivers[name]
+				if !exists || state.resolveNotified[name] {
+					continue
+				}
+				pending++
+				if now.Before(state.retryAt[name]) {
+					continue
+				}
+				deliveries = append(deliveries, incidentDelivery{
+					incident: state.ID, level: state.Level, receiver: receiver,
+					notification: state.notification(name, "resolved"),
+				})
+			}
+			if pending == 0 {
+				state.resolveSent = true
+				im.dirty = true
+			}
+		}
+	}
+	im.pruneResolved()
+	im.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		err := deliverNotification(ctx, im.backoff, delivery.receiver, delivery.notification)
+		if err != nil {
+			klog.Errorf("Failed to notify %s about incident %s: %v", delivery.receiver.Name(), delivery.incident, err)
+		}
+		im.recordDelivery(delivery, err)
+	}
+	if len(actions) > 0 {
+		im.runActions(ctx, actions)
+	}
+	im.save(ctx)
+}
+
+// recordDelivery 记录通知结果：成功或永久失败时标记接收器已通知，其余失败在minAlertRetryInterval后重试
+func (im *IncidentManager) recordDelivery(delivery incidentDelivery, err error) {
+	name := delivery.receiver.Name()
+	record := IncidentNotification{
+		Level:    delivery.level,
+		Receiver: name,
+		Status:   delivery.notification.Status,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	state, exists := im.incidents[delivery.incident]
+	if !exists {
+		return
+	}
+	state.Notifications = append(state.Notifications, record)
+	if excess := len(state.Notifications) - maxIncidentNotifications; excess > 0 {
+		state.Notifications = state.Notifications[excess:]
+	}
+	im.dirty = true
+
+	if err != nil && !permanentDeliveryError(err) {
+		state.retryAt[name] = record.Time.Add(minAlertRetryInterval)
+		return
+	}
+	if err != nil {
+		klog.Warningf("Dropping %s notification of incident %s to %s after a permanent error", record.Status, state.ID, name)
+	}
+	delete(state.retryAt, name)
+	if record.Status == "resolved" {
+		state.resolveNotified[name] = true
+	} else {
+		state.notified[name] = true
+	}
+}
+
+// runActions 依次执行升级动作并记录结果，已确认或已解决的事件不再执行
+func (im *IncidentManager) runActions(ctx context.Context, requests []incidentActionRequest) {
+	im.mu.Lock()
+	run := im.runAction
+	im.mu.Unlock()
+
+	for _, request := range requests {
+		im.mu.Lock()
+		state, exists := im.incidents[request.incident]
+		open := exists && state.State == IncidentOpen
+		im.mu.Unlock()
+		if !open {
+			continue
+		}
+
+		record := IncidentActionRun{Level: request.level, Type: request.action.Type, Time: time.Now()}
+		switch {
+		case run == nil:
+			record.Error = "no action runner configured"
+			klog.Warningf("Incident %s reached level %d but no action runner is configured, skipping %s",
+				request.incident, request.level, request.action.Type)
+		default:
+			klog.Infof("Incident %s escalated to level %d, executing %s", request.incident, request.level, request.action.Type)
+			if err := run(ctx, request.action); err != nil {
+				record.Error = err.Error()
+				klog.Errorf("Escalation action %s of incident %s failed: %v", request.action.Type, request.incident, err)
+			}
+		}
+
+		im.mu.Lock()
+		if state, exists := im.incidents[request.incident]; exists {
+			state.Actions = append(state.Actions, record)
+			im.dirty = true
+		}
+		im.mu.Unlock()
+	}
+}
+
+// pruneResolved 已解决事件超过上限时删除最早解决的，调用方需持有im.mu
+func (im *IncidentManager) pruneResolved() {
+	var resolved []*incidentState
+	for _, state := range im.incidents {
+		if state.State == IncidentResolved && state.resolveSent {
+			resolved = append(resolved, state)
+		}
+	}
+	if len(resolved) <= maxResolvedIncidents {
+		return
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].ResolvedAt.Before(*resolved[j].ResolvedAt) })
+	for _, state := range resolved[:len(resolved)-maxResolvedIncidents] {
+		delete(im.incidents, state.ID)
+	}
+}
+
+// load 从存储加载事件；加载前已打开的同一来源事件合并到已保存的事件中
+func (im *IncidentManager) load(ctx context.Context) error {
+	records, err := im.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for i := range records {
+		state := &incidentState{Incident: records[i].Incident, healthySince: records[i].HealthySince, resolveSent: records[i].ResolveSent}
+		state.init()
+		for _, name := range records[i].Notified {
+			state.notified[name] = true
+		}
+		for _, name := range records[i].ResolveNotified {
+			state.resolveNotified[name] = true
+		}
+		if _, exists := im.incidents[state.ID]; exists {
+			continue
+		}
+
+		if state.State != IncidentResolved {
+			if id, exists := im.active[state.Key]; exists {
+				current := im.incidents[id]
+				if current.LastSeen.After(state.LastSeen) {
+					state.LastSeen = current.LastSeen
+					state.Summary = current.Summary
+					state.healthySince = current.healthySince
+				}
+				delete(im.incidents, id)
+			}
+			im.active[state.Key] = state.ID
+		}
+		im.incidents[state.ID] = state
+	}
+	klog.Infof("Loaded %d incidents", len(records))
+	return nil
+}
+
+// save 有未保存的变更时将全部事件写入存储，失败时下一轮重试
+func (im *IncidentManager) save(ctx context.Context) {
+	im.mu.Lock()
+	if im.store == nil || !im.dirty {
+		im.mu.Unlock()
+		return
+	}
+	records := make([]IncidentRecord, 0, len(im.incidents))
+	for _, state := range im.incidents {
+		records = append(records, state.record())
+	}
+	im.dirty = false
+	im.mu.Unlock()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].OpenedAt.Before(records[j].OpenedAt) })
+	if err := im.store.Save(ctx, records); err != nil {
+		klog.Errorf("Failed to save incidents: %v", err)
+		im.mu.Lock()
+		im.dirty = true
+		im.mu.Unlock()
+	}
+}
+
+// init 初始化通知状态
+func (s *incidentState) init() {
+	s.notified = make(map[string]bool)
+	s.resolveNotified = make(map[string]bool)
+	s.retryAt = make(map[string]time.Time)
+}
+
+// record 返回事件的持久化记录，调用方需持有im.mu
+func (s *incidentState) record() IncidentRecord {
+	record := IncidentRecord{
+		Incident:     s.snapshot(),
+		ResolveSent:  s.resolveSent,
+		HealthySince: s.healthySince,
+	}
+	for name := range s.notified {
+		record.Notified = append(record.Notified, name)
+	}
+	for name := range s.resolveNotified {
+		record.ResolveNotified = append(record.ResolveNotified, name)
+	}
+	sort.Strings(record.Notified)
+	sort.Strings(record.ResolveNotified)
+	return record
+}
+
+// notification 构造发送给接收器的通知，以事件ID作为分组和指纹
+func (s *incidentState) notification(receiver, status string) *AlertNotification {
+	labels := make(map[string]string, len(s.Labels)+2)
+	for key, value := range s.Labels {
+		labels[key] = value
+	}
+	labels["incident"] = s.ID
+	labels["escalation_level"] = strconv.Itoa(s.Level)
+
+	alert := NotifiedAlert{
+		Fingerprint: s.ID,
+		Status:      status,
+		Severity:    s.Severity,
+		Message:     s.Summary,
+		Labels:      labels,
+		StartsAt:    s.OpenedAt,
+	}
+	if s.ResolvedAt != nil {
+		alert.EndsAt = *s.ResolvedAt
+	}
+	return &AlertNotification{
+		Receiver:    receiver,
+		GroupKey:    s.ID,
+		GroupLabels: map[string]string{"incident": s.ID},
+		Status:      status,
+		Alerts:      []NotifiedAlert{alert},
+	}
+}
+
+// snapshot 返回事件的副本，调用方需持有im.mu
+func (s *incidentState) snapshot() Incident {
+	incident := s.Incident
+	incident.Notifications = append([]IncidentNotification(nil), s.Notifications...)
+	incident.Actions = append([]IncidentActionRun(nil), s.Actions...)
+	return incident
+}
+
+// Handler 返回事件HTTP API
+//
+//	GET  /incidents?state=open       列出事件
+//	GET  /incidents/{id}             获取事件
+//	POST /incidents/{id}/ack         确认事件，请求体可选{"by": "..."}
+//	POST /incidents/{id}/resolve     解决事件，请求体可选{"by": "..."}
+func (im *IncidentManager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /incidents", func(w http.ResponseWriter, r *http.Request) {
+		writeIncidentJSON(w, http.StatusOK, im.Incidents(r.URL.Query().Get("state")))
+	})
+	mux.HandleFunc("GET /incidents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		incident, exists := im.Get(r.PathValue("id"))
+		if !exists {
+			http.Error(w, errIncidentNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		writeIncidentJSON(w, http.StatusOK, incident)
+	})
+	mux.HandleFunc("POST /incidents/{id}/ack", func(w http.ResponseWriter, r *http.Request) {
+		im.serveTransition(w, r, im.Acknowledge)
+	})
+	mux.HandleFunc("POST /incidents/{id}/resolve", func(w http.ResponseWriter, r *http.Request) {
+		im.serveTransition(w, r, im.Resolve)
+	})
+	return mux
+}
+
+// serveTransition 处理确认和解决请求
+func (im *IncidentManager) serveTransition(w http.ResponseWriter, r *http.Request, transition func(id, by string) (Incident, error)) {
+	var body struct {
+		By string `json:"by"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if body.By == "" {
+		body.By = "api"
+	}
+
+	incident, err := transition(r.PathValue("id"), body.By)
+	switch {
+	case errors.Is(err, errIncidentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errIncidentResolved):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeIncidentJSON(w, http.StatusOK, incident)
+	}
+}
+
+func writeIncidentJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("Failed to encode incident response: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// incident-state.go
+// 事件状态持久化 - 将事件及其通知进度保存到ConfigMap，领导者切换后新的领导者继续升级和通知，不会重复通知
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// IncidentStateConfigMapName 保存事件状态的ConfigMap名称
+	IncidentStateConfigMapName = "scheduler-recovery-incidents"
+	incidentStateKey           = "incidents.json"
+)
+
+// IncidentRecord 持久化的事件及其通知进度
+type IncidentRecord struct {
+	Incident
+	Notified        []string  `json:"notified,omitempty"`        // 已发送firing通知的接收器
+	ResolveNotified []string  `json:"resolveNotified,omitempty"` // 已发送resolved通知的接收器
+	ResolveSent     bool      `json:"resolveSent,omitempty"`     // resolved通知已全部发送
+	HealthySince    time.Time `json:"healthySince,omitempty"`
+}
+
+// IncidentStore 事件状态存储
+type IncidentStore interface {
+	// Load 读取全部事件，不存在时返回空
+	Load(ctx context.Context) ([]IncidentRecord, error)
+	// Save 保存全部事件
+	Save(ctx context.Context, records []IncidentRecord) error
+}
+
+// ConfigMapIncidentStore 基于ConfigMap的事件状态存储
+type ConfigMapIncidentStore struct {
+	client    kubernetes.Interface
+	namespace string
+}
+
+// NewConfigMapIncidentStore 创建基于ConfigMap的事件状态存储，状态保存在namespace中的scheduler-recovery-incidents
+func NewConfigMapIncidentStore(client kubernetes.Interface, namespace string) *ConfigMapIncidentStore {
+	return &ConfigMapIncidentStore{client: client, namespace: namespace}
+}
+
+// Load 实现IncidentStore
+func (s *ConfigMapIncidentStore) Load(ctx context.Context) ([]IncidentRecord, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, IncidentStateConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, exists := cm.Data[incidentStateKey]
+	if !exists {
+		return nil, nil
+	}
+
+	var records []IncidentRecord
+	if err := json.Unmarshal([]byte(raw), &records); err != nil {
+		return nil, fmt.Errorf("invalid incident state in configmap %s/%s: %v", s.namespace, IncidentStateConfigMapName, err)
+	}
+	return records, nil
+}
+
+// Save 实现IncidentStore
+func (s *ConfigMapIncidentStore) Save(ctx context.Context, records []IncidentRecord) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal incident state: %v", err)
+	}
+
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+	cm, err := configMaps.Get(ctx, IncidentStateConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      IncidentStateConfigMapName,
+				Namespace: s.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "scheduler-recovery",
+				},
+			},
+			Data: map[string]string{incidentStateKey: string(data)},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[incidentStateKey] = string(data)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,197 @@
+// node-drain.go
+// 节点排空 - 通过补丁封锁节点，经Eviction API驱逐Pod并遵守PDB，限制自动封锁的节点比例
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// CordonedByRecoveryAnnotation 由恢复管理器封锁的节点注解，值为封锁时间，用于统计自动封锁的节点比例
+const CordonedByRecoveryAnnotation = "scheduler-recovery/cordoned-at"
+
+// defaultMaxCordonedNodePercent 自动封锁节点比例的默认上限
+const defaultMaxCordonedNodePercent = 20
+
+// evictionRetryInterval PDB暂不允许驱逐时的重试间隔
+const evictionRetryInterval = 5 * time.Second
+
+// NodeDrainResult 单个节点的排空结果
+type NodeDrainResult struct {
+	Node    string   `json:"node"`
+	Evicted []string `json:"evicted"` // 已驱逐的Pod
+	Skipped []string `json:"skipped"` // 跳过的Pod及原因
+}
+
+// nodeDrainer 节点排空
+type nodeDrainer struct {
+	client kubernetes.Interface
+}
+
+// cordonBudget 返回在比例上限内还可以自动封锁的节点数，以及按集群规模计算的封锁上限
+// 比例向下取整为0的小集群至少允许封锁1个节点；只有1个节点时上限为0，避免集群失去全部可调度节点
+func cordonBudget(nodes []v1.Node, maxPercent int) (budget, limit int) {
+	if maxPercent <= 0 {
+		maxPercent = defaultMaxCordonedNodePercent
+	}
+	limit = len(nodes) * maxPercent / 100
+	if limit == 0 && len(nodes) > 1 {
+		limit = 1
+	}
+	cordoned := 0
+	for _, node := range nodes {
+		if _, exists := node.Annotations[CordonedByRecoveryAnnotation]; exists && node.Spec.Unschedulable {
+			cordoned++
+		}
+	}
+	return limit - cordoned, limit
+}
+
+// cordon 通过补丁将节点标记为不可调度，并记录由恢复管理器封锁
+func (d *nodeDrainer) cordon(ctx context.Context, name string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},"spec":{"unschedulable":true}}`,
+		CordonedByRecoveryAnnotation, time.Now().Format(time.RFC3339))
+	if _, err := d.client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to cordon node %s: %v", name, err)
+	}
+	klog.Infof("Cordoned node %s", name)
+	return nil
+}
+
+// uncordon 恢复节点可调度并移除封锁注解
+func (d *nodeDrainer) uncordon(ctx context.Context, name string) error {
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:null}},"spec":{"unschedulable":false}}`, CordonedByRecoveryAnnotation)
+	if _, err := d.client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to uncordon node %s: %v", name, err)
+	}
+	klog.Infof("Uncordoned node %s", name)
+	return nil
+}
+
+// drain 驱逐节点上的Pod并等待它们删除，直到ctx超时
+// 跳过DaemonSet Pod、静态Pod、已结束的Pod和没有控制器的Pod；PDB不允许驱逐时重试。
+// 节点未就绪时kubelet无法确认删除，Pod进入Terminating即视为已驱逐
+func (d *nodeDrainer) drain(ctx context.Context, node *v1.Node) (*NodeDrainResult, error) {
+	result := &NodeDrainResult{Node: node.Name}
+
+	pods, err := d.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + node.Name,
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to list pods on node %s: %v", node.Name, err)
+	}
+
+	var evicting []v1.Pod
+	for _, pod := range pods.Items {
+		key := pod.Namespace + "/" + pod.Name
+		if reason := drainSkipReason(&pod); reason != "" {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s (%s)", key, reason))
+			continue
+		}
+		if err := d.evict(ctx, &pod); err != nil {
+			return result, err
+		}
+		evicting = append(evicting, pod)
+		result.Evicted = append(result.Evicted, key)
+	}
+
+	nodeReady := isNodeReady(node)
+	for _, pod := range evicting {
+		if err := d.waitForDeletion(ctx, &pod, nodeReady); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// drainSkipReason 返回排空时跳过Pod的原因，不跳过时返回空字符串
+func drainSkipReason(pod *v1.Pod) string {
+	if _, mirror := pod.Annotations[v1.MirrorPodAnnotationKey]; mirror {
+		return "static pod"
+	}
+	if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+		return "terminated"
+	}
+	if pod.DeletionTimestamp != nil {
+		return "already terminating"
+	}
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil {
+		return "not managed by a controller"
+	}
+	if controller.Kind == "DaemonSet" {
+		return "DaemonSet pod"
+	}
+	return ""
+}
+
+// evict 通过Eviction API驱逐Pod，PDB暂不允许时按间隔重试直到ctx超时
+func (d *nodeDrainer) evict(ctx context.Context, pod *v1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		DeleteOptions: &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &pod.UID},
+		},
+	}
+
+	var lastErr error
+	err := wait.PollUntilContextCancel(ctx, evictionRetryInterval, true, func(ctx context.Context) (bool, error) {
+		err := d.client.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+		switch {
+		case err == nil, errors.IsNotFound(err), errors.IsConflict(err):
+			// 已删除或已被替换为同名的新Pod
+			return true, nil
+		case errors.IsTooManyRequests(err):
+			// PodDisruptionBudget暂不允许驱逐
+			lastErr = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if err != nil {
+		if lastErr != nil {
+			return fmt.Errorf("eviction of pod %s/%s blocked by disruption budget: %v", pod.Namespace, pod.Name, lastErr)
+		}
+		return fmt.Errorf("failed to evict pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	klog.Infof("Evicted pod %s/%s from node %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+	return nil
+}
+
+// waitForDeletion 等待被驱逐的Pod删除
+func (d *nodeDrainer) waitForDeletion(ctx context.Context, pod *v1.Pod, nodeReady bool) error {
+	err := wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
+		current, err := d.client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, nil
+		}
+		if current.UID != pod.UID {
+			return true, nil
+		}
+		return !nodeReady && current.DeletionTimestamp != nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("pod %s/%s was not deleted after eviction: %v", pod.Namespace, pod.Name, err)
+	}
+	return nil
+}
+
+// sortNodesByName 按名称排序，使每次选择的节点稳定
+func sortNodesByName(nodes []v1.Node) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+}
@@ -3,12 +3,21 @@
 package scheduler
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/kubernetes-fundamentals/internal/utils"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
+	schedulerconfigv1 "k8s.io/kube-scheduler/config/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
 )
 
 // SchedulerPerformanceTuner 调度器性能调优器
@@ -16,6 +25,7 @@ import (
 type SchedulerPerformanceTuner struct {
 	client kubernetes.Interface // Kubernetes客户端，用于与API服务器通信
 	config *PerformanceConfig   // 性能配置参数
+	tuning autoTuneState        // 自动调优实验记录
 }
 
 // PerformanceConfig 性能配置结构体
@@ -23,7 +33,7 @@ type SchedulerPerformanceTuner struct {
 type PerformanceConfig struct {
 	// 调度队列配置 - 控制Pod排队和处理策略
 	QueueSortPlugin          string // 队列排序插件名称，如"PrioritySort"
-	MaxPendingPods           int    // 最大待调度Pod数量，防止内存溢出
+	MaxPendingPods           int    // 最大待调度Pod数量，kube-scheduler没有对应配置项，用作滚动重启后的健康检查阈值
 	PodInitialBackoffSeconds int    // Pod调度失败后的初始退避时间（秒）
 	PodMaxBackoffSeconds     int    // Pod调度失败后的最大退避时间（秒）
 
@@ -40,21 +50,19 @@ type PerformanceConfig struct {
 	BatchTimeout time.Duration // 批处理超时时间
 }
 
-// NewSchedulerPerformanceTuner 创建新的调度器性能调优器实例
-// 初始化Kubernetes客户端和默认性能配置
-func NewSchedulerPerformanceTuner() *SchedulerPerformanceTuner {
-	// 获取集群内配置，用于与API服务器通信
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		klog.Fatalf("Failed to create config: %v", err)
-	}
-
-	// 创建Kubernetes客户端
-	client, err := kubernetes.NewForConfig(config)
+// NewSchedulerPerformanceTunerFromKubeconfig 使用kubeconfig创建调度器性能调优器
+// kubeconfig为空时优先使用集群内配置，其次使用~/.kube/config，便于在集群外运行
+func NewSchedulerPerformanceTunerFromKubeconfig(kubeconfig string) (*SchedulerPerformanceTuner, error) {
+	client, err := utils.GetKubernetesClient(kubeconfig)
 	if err != nil {
-		klog.Fatalf("Failed to create client: %v", err)
+		return nil, err
 	}
+	return NewSchedulerPerformanceTuner(client), nil
+}
 
+// NewSchedulerPerformanceTuner 创建新的调度器性能调优器实例
+// 使用给定的Kubernetes客户端和默认性能配置
+func NewSchedulerPerformanceTuner(client kubernetes.Interface) *SchedulerPerformanceTuner {
 	return &SchedulerPerformanceTuner{
 		client: client,
 		config: &PerformanceConfig{
@@ -97,30 +105,358 @@ func (spt *SchedulerPerformanceTuner) OptimizeForClusterSize(nodeCount int) {
 	klog.Infof("Optimized scheduler for cluster size: %d nodes", nodeCount)
 }
 
+const (
+	// optimizedSchedulerName 基础配置中没有调度器配置档时生成的配置档名称
+	optimizedSchedulerName = "optimized-scheduler"
+	// defaultQueueSortPlugin kube-scheduler默认的队列排序插件
+	defaultQueueSortPlugin = "PrioritySort"
+)
+
+// Config 返回当前性能配置的副本
+func (spt *SchedulerPerformanceTuner) Config() PerformanceConfig {
+	return *spt.config
+}
+
+// DetectNodeCount 统计集群中可调度且就绪的节点数量
+func (spt *SchedulerPerformanceTuner) DetectNodeCount(ctx context.Context) (int, error) {
+	nodes, err := spt.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	count := 0
+	for i := range nodes.Items {
+		if !nodes.Items[i].Spec.Unschedulable && isNodeReady(&nodes.Items[i]) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// OptimizeForCluster 检测集群节点数量并据此优化调度器配置
+func (spt *SchedulerPerformanceTuner) OptimizeForCluster(ctx context.Context) (int, error) {
+	nodeCount, err := spt.DetectNodeCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	spt.OptimizeForClusterSize(nodeCount)
+	return nodeCount, nil
+}
+
+// DiffWithCurrentConfig 比较ConfigMap中当前的调度器配置和推荐配置
+// 推荐配置在当前配置基础上应用性能参数生成，返回两者的YAML和逐行差异
+func (spt *SchedulerPerformanceTuner) DiffWithCurrentConfig(ctx context.Context, target SchedulerConfigTarget) (current, recommended, diff string, err error) {
+	target = target.withDefaults()
+	current, err = spt.readSchedulerConfig(ctx, target)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var existing *schedulerconfigv1.KubeSchedulerConfiguration
+	if current != "" {
+		existing, err = parseSchedulerConfiguration(current)
+		if err != nil {
+			return "", "", "", fmt.Errorf("configmap %s/%s: %v", target.Namespace, target.ConfigMapName, err)
+		}
+	}
+	config := spt.BuildSchedulerConfiguration(existing)
+	if err := ValidateSchedulerConfiguration(config); err != nil {
+		return "", "", "", err
+	}
+	recommended, err = marshalSchedulerConfiguration(config, current)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return current, recommended, lineDiff(current, recommended), nil
+}
+
+// lineDiff 基于最长公共子序列生成逐行差异，删除行以"-"开头，新增行以"+"开头
+func lineDiff(from, to string) string {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	// lcs[i][j] 为a[i:]与b[j:]的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
 // GenerateOptimizedConfig 生成优化后的调度器配置文件
-// 返回YAML格式的KubeSchedulerConfiguration配置
-func (spt *SchedulerPerformanceTuner) GenerateOptimizedConfig() string {
-	return fmt.Sprintf(`
-apiVersion: kubescheduler.config.k8s.io/v1beta3
-kind: KubeSchedulerConfiguration
-profiles:
-- schedulerName: optimized-scheduler
-  plugins:
-    queueSort:
-      enabled:
-      - name: %s                    # 队列排序插件
-  pluginConfig:
-  - name: DefaultBinder
-    args:
-      bindTimeoutSeconds: 600       # 绑定超时时间10分钟
-  - name: PodTopologySpread
-    args:
-      defaultingType: List          # 使用列表模式应用拓扑约束
-percentageOfNodesToScore: %d        # 参与评分的节点百分比
-parallelism: %d                     # 并行评分线程数
-`,
-		spt.config.QueueSortPlugin,
-		spt.config.PercentageOfNodesToScore,
-		spt.config.NodeScoreParallelism,
-	)
+// 返回YAML格式的kubescheduler.config.k8s.io/v1 KubeSchedulerConfiguration配置
+func (spt *SchedulerPerformanceTuner) GenerateOptimizedConfig() (string, error) {
+	config := spt.BuildSchedulerConfiguration(nil)
+	if err := ValidateSchedulerConfiguration(config); err != nil {
+		return "", err
+	}
+	return marshalSchedulerConfiguration(config, "")
+}
+
+// BuildSchedulerConfiguration 在基础配置上应用性能参数，生成KubeSchedulerConfiguration
+// base为空时生成只包含optimized-scheduler配置档的新配置；base中的领导者选举、插件等其他设置保持不变
+func (spt *SchedulerPerformanceTuner) BuildSchedulerConfiguration(base *schedulerconfigv1.KubeSchedulerConfiguration) *schedulerconfigv1.KubeSchedulerConfiguration {
+	config := &schedulerconfigv1.KubeSchedulerConfiguration{}
+	if base != nil {
+		config = base.DeepCopy()
+	}
+	config.TypeMeta = metav1.TypeMeta{
+		APIVersion: schedulerconfigv1.SchemeGroupVersion.String(),
+		Kind:       "KubeSchedulerConfiguration",
+	}
+
+	// 全局性能参数
+	config.Parallelism = ptr.To(int32(spt.config.NodeScoreParallelism))
+	config.PercentageOfNodesToScore = ptr.To(int32(spt.config.PercentageOfNodesToScore))
+	config.PodInitialBackoffSeconds = ptr.To(int64(spt.config.PodInitialBackoffSeconds))
+	config.PodMaxBackoffSeconds = ptr.To(int64(spt.config.PodMaxBackoffSeconds))
+
+	if len(config.Profiles) == 0 {
+		config.Profiles = []schedulerconfigv1.KubeSchedulerProfile{newOptimizedProfile()}
+	}
+
+	// 所有配置档必须使用相同的队列排序插件
+	for i := range config.Profiles {
+		profile := &config.Profiles[i]
+		if profile.Plugins == nil {
+			profile.Plugins = &schedulerconfigv1.Plugins{}
+		}
+		profile.Plugins.QueueSort = schedulerconfigv1.PluginSet{}
+		if spt.config.QueueSortPlugin != "" && spt.config.QueueSortPlugin != defaultQueueSortPlugin {
+			profile.Plugins.QueueSort = schedulerconfigv1.PluginSet{
+				Enabled:  []schedulerconfigv1.Plugin{{Name: spt.config.QueueSortPlugin}},
+				Disabled: []schedulerconfigv1.Plugin{{Name: "*"}},
+			}
+		}
+	}
+
+	return config
+}
+
+// newOptimizedProfile 创建默认的optimized-scheduler配置档
+func newOptimizedProfile() schedulerconfigv1.KubeSchedulerProfile {
+	return schedulerconfigv1.KubeSchedulerProfile{
+		SchedulerName: ptr.To(optimizedSchedulerName),
+		PluginConfig: []schedulerconfigv1.PluginConfig{
+			{
+				Name: "VolumeBinding",
+				Args: pluginArgs(&schedulerconfigv1.VolumeBindingArgs{
+					BindTimeoutSeconds: ptr.To(int64(600)), // 卷绑定超时时间10分钟
+				}),
+			},
+			{
+				Name: "PodTopologySpread",
+				Args: pluginArgs(&schedulerconfigv1.PodTopologySpreadArgs{
+					DefaultingType: schedulerconfigv1.ListDefaulting, // 使用列表模式应用拓扑约束
+				}),
+			},
+		},
+	}
+}
+
+// pluginArgs 将插件参数序列化为PluginConfig.Args
+func pluginArgs(args interface{}) runtime.RawExtension {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		// 插件参数均为本包构造的固定结构，序列化不会失败
+		panic(fmt.Sprintf("failed to marshal plugin args: %v", err))
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+// ValidateSchedulerConfiguration 校验KubeSchedulerConfiguration中与性能调优相关的字段
+// 覆盖kube-scheduler启动时会拒绝的常见错误，避免写入一个让调度器无法启动的配置
+func ValidateSchedulerConfiguration(config *schedulerconfigv1.KubeSchedulerConfiguration) error {
+	var errs []string
+
+	if config.APIVersion != schedulerconfigv1.SchemeGroupVersion.String() || config.Kind != "KubeSchedulerConfiguration" {
+		errs = append(errs, fmt.Sprintf("unsupported config type %s/%s", config.APIVersion, config.Kind))
+	}
+	if config.Parallelism != nil && *config.Parallelism <= 0 {
+		errs = append(errs, fmt.Sprintf("parallelism must be positive, got %d", *config.Parallelism))
+	}
+	if config.PercentageOfNodesToScore != nil && (*config.PercentageOfNodesToScore < 0 || *config.PercentageOfNodesToScore > 100) {
+		errs = append(errs, fmt.Sprintf("percentageOfNodesToScore must be in [0, 100], got %d", *config.PercentageOfNodesToScore))
+	}
+	if config.PodInitialBackoffSeconds != nil && *config.PodInitialBackoffSeconds <= 0 {
+		errs = append(errs, fmt.Sprintf("podInitialBackoffSeconds must be positive, got %d", *config.PodInitialBackoffSeconds))
+	}
+	if config.PodInitialBackoffSeconds != nil && config.PodMaxBackoffSeconds != nil && *config.PodMaxBackoffSeconds < *config.PodInitialBackoffSeconds {
+		errs = append(errs, fmt.Sprintf("podMaxBackoffSeconds (%d) must not be less than podInitialBackoffSeconds (%d)",
+			*config.PodMaxBackoffSeconds, *config.PodInitialBackoffSeconds))
+	}
+
+	if len(config.Profiles) == 0 {
+		errs = append(errs, "at least one profile is required")
+	}
+	schedulerNames := make(map[string]bool)
+	for i, profile := range config.Profiles {
+		name := ptr.Deref(profile.SchedulerName, "")
+		if name == "" {
+			errs = append(errs, fmt.Sprintf("profiles[%d].schedulerName is required", i))
+		} else if schedulerNames[name] {
+			errs = append(errs, fmt.Sprintf("profiles[%d].schedulerName %q is duplicated", i, name))
+		}
+		schedulerNames[name] = true
+
+		if profile.PercentageOfNodesToScore != nil && (*profile.PercentageOfNodesToScore < 0 || *profile.PercentageOfNodesToScore > 100) {
+			errs = append(errs, fmt.Sprintf("profiles[%d].percentageOfNodesToScore must be in [0, 100]", i))
+		}
+
+		pluginConfigs := make(map[string]bool)
+		for _, pluginConfig := range profile.PluginConfig {
+			if pluginConfigs[pluginConfig.Name] {
+				errs = append(errs, fmt.Sprintf("profiles[%d].pluginConfig %q is duplicated", i, pluginConfig.Name))
+			}
+			pluginConfigs[pluginConfig.Name] = true
+		}
+
+		if i > 0 && !equality.Semantic.DeepEqual(queueSortOf(profile), queueSortOf(config.Profiles[0])) {
+			errs = append(errs, fmt.Sprintf("profiles[%d] must use the same queueSort plugins as profiles[0]", i))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid scheduler configuration: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func queueSortOf(profile schedulerconfigv1.KubeSchedulerProfile) schedulerconfigv1.PluginSet {
+	if profile.Plugins == nil {
+		return schedulerconfigv1.PluginSet{}
+	}
+	return profile.Plugins.QueueSort
+}
+
+// parseSchedulerConfiguration 解析ConfigMap中已有的调度器配置
+// 只接受kubescheduler.config.k8s.io/v1：v1beta3等旧版本的插件集合不同（如v1已移除SelectorSpread），
+// 直接改写版本号会生成kube-scheduler拒绝的配置，需要先按官方迁移说明升级到v1
+func parseSchedulerConfiguration(data string) (*schedulerconfigv1.KubeSchedulerConfiguration, error) {
+	var config schedulerconfigv1.KubeSchedulerConfiguration
+	if err := yaml.Unmarshal([]byte(data), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler configuration: %v", err)
+	}
+	if config.Kind != "" && config.Kind != "KubeSchedulerConfiguration" {
+		return nil, fmt.Errorf("unexpected kind %q in scheduler configuration", config.Kind)
+	}
+	if config.APIVersion != schedulerconfigv1.SchemeGroupVersion.String() {
+		return nil, fmt.Errorf("unsupported scheduler configuration version %q, migrate it to %s first",
+			config.APIVersion, schedulerconfigv1.SchemeGroupVersion.String())
+	}
+	return &config, nil
+}
+
+// marshalSchedulerConfiguration 将调度器配置序列化为YAML
+// 省略结构体序列化产生的零值字段（如未设置的clientConnection、leaderElection），kube-scheduler会对这些字段使用默认值；
+// original为配置的原始YAML，其中显式写出的字段即使是零值也保留
+func marshalSchedulerConfiguration(config *schedulerconfigv1.KubeSchedulerConfiguration, original string) (string, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal scheduler configuration: %v", err)
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(raw, &content); err != nil {
+		return "", fmt.Errorf("failed to marshal scheduler configuration: %v", err)
+	}
+
+	var explicit interface{}
+	if original != "" {
+		if err := yaml.Unmarshal([]byte(original), &explicit); err != nil {
+			return "", fmt.Errorf("failed to parse scheduler configuration: %v", err)
+		}
+	}
+
+	data, err := yaml.Marshal(pruneZeroValues(content, explicit))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal scheduler configuration: %v", err)
+	}
+	return string(data), nil
+}
+
+// pruneZeroValues 递归删除null、空字符串、0、"0s"以及清理后为空的对象和数组，布尔值保留
+// explicit是原始配置中对应位置的值，原始配置里存在的字段不删除
+func pruneZeroValues(value, explicit interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		explicitMap, _ := explicit.(map[string]interface{})
+		for key, item := range typed {
+			explicitItem, exists := explicitMap[key]
+			pruned := pruneZeroValues(item, explicitItem)
+			switch {
+			case pruned != nil:
+				typed[key] = pruned
+			case !exists:
+				delete(typed, key)
+			}
+		}
+		if len(typed) == 0 {
+			return nil
+		}
+		return typed
+	case []interface{}:
+		explicitItems, _ := explicit.([]interface{})
+		var items []interface{}
+		for i, item := range typed {
+			var explicitItem interface{}
+			if i < len(explicitItems) {
+				explicitItem = explicitItems[i]
+			}
+			if pruned := pruneZeroValues(item, explicitItem); pruned != nil {
+				items = append(items, pruned)
+			} else if explicitItem != nil {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return items
+	case string:
+		if typed == "" || typed == "0s" {
+			return nil
+		}
+	case float64:
+		if typed == 0 {
+			return nil
+		}
+	}
+	return value
 }
@@ -0,0 +1,211 @@
+// recovery-actions.go
+// 恢复动作注册表 - 恢复策略、健康检查驱动的恢复和升级级别共用同一组动作，
+// 新的动作通过RegisterRecoveryAction注册后即可在策略中引用
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// 内置恢复动作类型
+const (
+	ActionRestartPod          = "restart_pod"
+	ActionForceLeaderElection = "force_leader_election"
+	ActionReschedulePods      = "reschedule_pods"
+	ActionScaleScheduler      = "scale_scheduler"
+	ActionRestartScheduler    = "restart_scheduler"
+	ActionDrainUnhealthyNodes = "drain_unhealthy_nodes"
+	ActionEvictStuckPods      = "evict_stuck_pods"
+	ActionRebalanceResources  = "rebalance_resources"
+)
+
+// 恢复动作参数类型
+const (
+	ParameterString = "string"
+	ParameterNumber = "number" // 非负数，JSON解码后为float64
+)
+
+// ActionParameter 恢复动作的参数定义
+type ActionParameter struct {
+	Kind     string
+	Required bool
+}
+
+// RecoveryPlan 恢复动作执行后的后置条件和补偿动作
+type RecoveryPlan struct {
+	// Verify 后置条件，满足时返回true，message说明当前状态
+	Verify func(ctx context.Context) (ok bool, message string, err error)
+	// Rollback 后置条件未满足时的补偿动作，为空表示动作不可补偿
+	Rollback func(ctx context.Context) error
+}
+
+// ActionRequest 一次恢复动作的执行请求
+type ActionRequest struct {
+	Action *RecoveryAction // 执行记录，Parameters已按动作的参数定义校验，动作在Result中说明执行结果
+	Client kubernetes.Interface
+	DryRun bool
+
+	manager *SchedulerRecoveryManager
+}
+
+// RecoveryActionHandler 恢复动作
+type RecoveryActionHandler interface {
+	// Parameters 动作接受的参数，加载策略和执行前据此校验
+	Parameters() map[string]ActionParameter
+	// Execute 执行动作，返回的RecoveryPlan为空表示不需要校验后置条件
+	Execute(ctx context.Context, req *ActionRequest) (*RecoveryPlan, error)
+}
+
+// DryRunSupporter 可选接口，SupportsDryRun返回true的动作在DryRun模式下仍会执行，
+// 由动作根据ActionRequest.DryRun保证不修改集群；其余动作在DryRun模式下只记录计划
+type DryRunSupporter interface {
+	SupportsDryRun() bool
+}
+
+// RecoveryActionFunc 以函数实现的恢复动作
+type RecoveryActionFunc struct {
+	Params map[string]ActionParameter
+	Run    func(ctx context.Context, req *ActionRequest) (*RecoveryPlan, error)
+	DryRun bool // Run支持DryRun模式
+}
+
+// Parameters 实现RecoveryActionHandler
+func (f RecoveryActionFunc) Parameters() map[string]ActionParameter { return f.Params }
+
+// SupportsDryRun 实现DryRunSupporter
+func (f RecoveryActionFunc) SupportsDryRun() bool { return f.DryRun }
+
+// Execute 实现RecoveryActionHandler
+func (f RecoveryActionFunc) Execute(ctx context.Context, req *ActionRequest) (*RecoveryPlan, error) {
+	return f.Run(ctx, req)
+}
+
+// recoveryActions 已注册的恢复动作
+var recoveryActions = struct {
+	mu       sync.RWMutex
+	handlers map[string]RecoveryActionHandler
+}{handlers: make(map[string]RecoveryActionHandler)}
+
+// RegisterRecoveryAction 注册恢复动作，名称已存在时返回错误
+func RegisterRecoveryAction(name string, handler RecoveryActionHandler) error {
+	if name == "" || handler == nil {
+		return fmt.Errorf("recovery action name and handler are required")
+	}
+	recoveryActions.mu.Lock()
+	defer recoveryActions.mu.Unlock()
+	if _, exists := recoveryActions.handlers[name]; exists {
+		return fmt.Errorf("recovery action %q already registered", name)
+	}
+	recoveryActions.handlers[name] = handler
+	return nil
+}
+
+// RecoveryActionTypes 返回已注册的恢复动作类型
+func RecoveryActionTypes() []string {
+	recoveryActions.mu.RLock()
+	defer recoveryActions.mu.RUnlock()
+	names := make([]string, 0, len(recoveryActions.handlers))
+	for name := range recoveryActions.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupRecoveryAction 查找已注册的恢复动作
+func lookupRecoveryAction(name string) (RecoveryActionHandler, bool) {
+	recoveryActions.mu.RLock()
+	defer recoveryActions.mu.RUnlock()
+	handler, exists := recoveryActions.handlers[name]
+	return handler, exists
+}
+
+// validateActionParameters 按动作的参数定义校验参数类型和必填参数，拒绝未知参数以便及早发现拼写错误
+func validateActionParameters(actionType string, params map[string]interface{}) error {
+	handler, known := lookupRecoveryAction(actionType)
+	if !known {
+		return fmt.Errorf("unknown action type %q", actionType)
+	}
+
+	kinds := handler.Parameters()
+	for name, value := range params {
+		expected, known := kinds[name]
+		if !known {
+			return fmt.Errorf("unknown parameter %q for action %s", name, actionType)
+		}
+		switch expected.Kind {
+		case ParameterString:
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("parameter %s must be a string", name)
+			}
+		case ParameterNumber:
+			if number, ok := value.(float64); !ok || number < 0 {
+				return fmt.Errorf("parameter %s must be a non-negative number", name)
+			}
+		}
+	}
+	for name, expected := range kinds {
+		if _, exists := params[name]; expected.Required && !exists {
+			return fmt.Errorf("parameter %s is required for action %s", name, actionType)
+		}
+	}
+	return nil
+}
+
+// managerAction 将SchedulerRecoveryManager的方法适配为恢复动作
+func managerAction(params map[string]ActionParameter,
+	run func(srm *SchedulerRecoveryManager, ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error)) RecoveryActionFunc {
+	return RecoveryActionFunc{
+		Params: params,
+		Run: func(ctx context.Context, req *ActionRequest) (*RecoveryPlan, error) {
+			return run(req.manager, ctx, req.Action)
+		},
+	}
+}
+
+// supportsDryRun 动作是否支持DryRun模式
+func supportsDryRun(handler RecoveryActionHandler) bool {
+	supporter, ok := handler.(DryRunSupporter)
+	return ok && supporter.SupportsDryRun()
+}
+
+func init() {
+	// 卡住Pod的处置在DryRun模式下只诊断并生成报告
+	evictStuckPods := managerAction(nil, (*SchedulerRecoveryManager).evictStuckPods)
+	evictStuckPods.DryRun = true
+
+	builtin := map[string]RecoveryActionHandler{
+		ActionRestartScheduler:    managerAction(nil, (*SchedulerRecoveryManager).restartScheduler),
+		ActionDrainUnhealthyNodes: managerAction(nil, (*SchedulerRecoveryManager).drainUnhealthyNodes),
+		ActionEvictStuckPods:      evictStuckPods,
+		ActionRebalanceResources:  managerAction(nil, (*SchedulerRecoveryManager).rebalanceResources),
+		ActionRestartPod: managerAction(map[string]ActionParameter{
+			"namespace":     {Kind: ParameterString},
+			"labelSelector": {Kind: ParameterString, Required: true},
+		}, (*SchedulerRecoveryManager).restartPods),
+		ActionForceLeaderElection: managerAction(map[string]ActionParameter{
+			"namespace":     {Kind: ParameterString},
+			"resourceName":  {Kind: ParameterString},
+			"labelSelector": {Kind: ParameterString},
+		}, (*SchedulerRecoveryManager).forceLeaderElection),
+		ActionReschedulePods: managerAction(map[string]ActionParameter{
+			"maxPods":           {Kind: ParameterNumber},
+			"priorityThreshold": {Kind: ParameterNumber},
+		}, (*SchedulerRecoveryManager).reschedulePods),
+		ActionScaleScheduler: managerAction(map[string]ActionParameter{
+			"namespace":      {Kind: ParameterString},
+			"deploymentName": {Kind: ParameterString},
+			"replicas":       {Kind: ParameterNumber, Required: true},
+		}, (*SchedulerRecoveryManager).scaleScheduler),
+	}
+	for name, handler := range builtin {
+		if err := RegisterRecoveryAction(name, handler); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/kubernetes-fundamentals/internal/utils"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
@@ -26,7 +27,7 @@ type RecoveryPolicy struct {
 type Trigger struct {
 	Type      string        `json:"type"`
 	Condition string        `json:"condition"`
-	Threshold interface{}   `json:"threshold"`
+	Threshold float64       `json:"threshold"`
 	Duration  time.Duration `json:"duration"`
 }
 
@@ -42,10 +43,13 @@ type RecoveryManager struct {
 	client         kubernetes.Interface
 	policies       []RecoveryPolicy
 	lastExecution  map[string]time.Time
+	triggerWindows map[string]*triggerWindow
 	mu             sync.RWMutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 	healthChecker  *HealthChecker
+	incidents      *IncidentManager
+	executor       *SchedulerRecoveryManager // 执行恢复动作
 }
 
 // NewRecoveryManager 创建恢复管理器
@@ -55,9 +59,11 @@ func NewRecoveryManager(client kubernetes.Interface, policies []RecoveryPolicy,
 		client:        client,
 		policies:      policies,
 		lastExecution: make(map[string]time.Time),
+		triggerWindows: make(map[string]*triggerWindow),
 		ctx:           ctx,
 		cancel:        cancel,
 		healthChecker: healthChecker,
+		executor:      NewSchedulerRecoveryManager(client, nil),
 	}
 }
 
@@ -91,20 +97,40 @@ func (rm *RecoveryManager) recoveryLoop() {
 
 // checkAndRecover 检查并执行恢复
 func (rm *RecoveryManager) checkAndRecover() {
-	for _, policy := range rm.policies {
+	policies := rm.Policies()
+	rm.pruneTriggerWindows(policies)
+
+	for _, policy := range policies {
 		if rm.shouldTriggerRecovery(policy) {
-			if err := rm.executeRecovery(policy); err != nil {
+			err := rm.executeRecovery(policy)
+			// 失败的执行同样进入冷却期，避免每轮都重复执行已经失败的动作
+			rm.updateLastExecution(policy.Name)
+			if err != nil {
 				klog.Errorf("Failed to execute recovery policy %s: %v", policy.Name, err)
 			} else {
 				klog.Infof("Successfully executed recovery policy %s", policy.Name)
-				rm.updateLastExecution(policy.Name)
+				rm.resetTriggerWindows(policy.Name)
 			}
 		}
 	}
 }
 
 // shouldTriggerRecovery 检查是否应该触发恢复
+// 每轮都对所有触发条件采样，冷却期间也保持窗口连续；所有条件都持续超过阈值Duration时才触发
 func (rm *RecoveryManager) shouldTriggerRecovery(policy RecoveryPolicy) bool {
+	now := time.Now()
+	firing := true
+	for i, trigger := range policy.Triggers {
+		value, ok := rm.evaluateCondition(trigger)
+		if !rm.observeTrigger(policy.Name, i, trigger, now, value, ok) {
+			firing = false
+		}
+	}
+	rm.trackIncident(policy, firing)
+	if !firing {
+		return false
+	}
+
 	// 检查冷却时间
 	rm.mu.RLock()
 	lastExec, exists := rm.lastExecution[policy.Name]
@@ -114,85 +140,78 @@ func (rm *RecoveryManager) shouldTriggerRecovery(policy RecoveryPolicy) bool {
 		return false
 	}
 
-	// 检查所有触发条件
-	for _, trigger := range policy.Triggers {
-		if !rm.evaluateCondition(trigger) {
-			return false
-		}
-	}
-
 	return true
 }
 
-// evaluateCondition 评估触发条件
-func (rm *RecoveryManager) evaluateCondition(trigger Trigger) bool {
+// evaluateCondition 对触发条件采样，ok为false表示本次无法采样
+func (rm *RecoveryManager) evaluateCondition(trigger Trigger) (float64, bool) {
 	switch trigger.Type {
-	case "api_unavailable":
+	case TriggerAPIUnavailable:
 		return rm.checkAPIAvailability(trigger)
-	case "leader_election_failed":
+	case TriggerLeaderElectionFailed:
 		return rm.checkLeaderElection(trigger)
-	case "pending_pods_high":
+	case TriggerPendingPodsHigh:
 		return rm.checkPendingPods(trigger)
-	case "health_check_failed":
+	case TriggerHealthCheckFailed:
 		return rm.checkHealthStatus(trigger)
 	default:
 		klog.Warningf("Unknown trigger type: %s", trigger.Type)
-		return false
+		return 0, false
 	}
 }
 
-// checkAPIAvailability 检查 API 可用性
-func (rm *RecoveryManager) checkAPIAvailability(trigger Trigger) bool {
+// checkAPIAvailability 检查 API 可用性，不可用时为1
+func (rm *RecoveryManager) checkAPIAvailability(trigger Trigger) (float64, bool) {
 	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
 	defer cancel()
 
 	_, err := rm.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
-	return err != nil
+	return boolValue(err != nil), true
 }
 
-// checkLeaderElection 检查 Leader 选举
-func (rm *RecoveryManager) checkLeaderElection(trigger Trigger) bool {
-	// 这里应该检查调度器的 Leader 选举状态
-	// 简化实现，实际应该检查 Leader 选举的 ConfigMap 或 Lease
-	return false
+// checkLeaderElection 检查 Leader 选举，调度器租约有持有者但已停止续约时为1
+func (rm *RecoveryManager) checkLeaderElection(trigger Trigger) (float64, bool) {
+	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+	defer cancel()
+
+	state, err := utils.InspectLeaderLease(ctx, rm.client, utils.LeaderLeaseOptions{})
+	if err != nil {
+		klog.Errorf("Failed to inspect scheduler leader lease: %v", err)
+		return 0, false
+	}
+	return boolValue(state.Holder != "" && state.Stale), true
 }
 
 // checkPendingPods 检查待调度 Pod 数量
-func (rm *RecoveryManager) checkPendingPods(trigger Trigger) bool {
+func (rm *RecoveryManager) checkPendingPods(trigger Trigger) (float64, bool) {
 	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
 	defer cancel()
 
 	pods, err := rm.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "spec.nodeName=",
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
 	})
 	if err != nil {
 		klog.Errorf("Failed to list pending pods: %v", err)
-		return false
-	}
-
-	pendingCount := len(pods.Items)
-	threshold, ok := trigger.Threshold.(float64)
-	if !ok {
-		return false
+		return 0, false
 	}
 
-	return float64(pendingCount) > threshold
+	return float64(len(pods.Items)), true
 }
 
-// checkHealthStatus 检查健康状态
-func (rm *RecoveryManager) checkHealthStatus(trigger Trigger) bool {
+// checkHealthStatus 检查健康状态，Condition指定的健康检查失败时为1
+func (rm *RecoveryManager) checkHealthStatus(trigger Trigger) (float64, bool) {
 	if rm.healthChecker == nil {
-		return false
+		return 0, false
 	}
 
 	checkName := trigger.Condition
 
 	status, exists := rm.healthChecker.GetStatus(checkName)
 	if !exists {
-		return false
+		return 0, false
 	}
 
-	return !status.Healthy
+	return boolValue(!status.Healthy), true
 }
 
 // executeRecovery 执行恢复
@@ -208,129 +227,64 @@ func (rm *RecoveryManager) executeRecovery(policy RecoveryPolicy) error {
 	return nil
 }
 
-// executeAction 执行恢复动作
+// executeAction 通过恢复执行器执行动作，动作在返回前已校验后置条件
 func (rm *RecoveryManager) executeAction(action Action) error {
-	ctx, cancel := context.WithTimeout(rm.ctx, action.Timeout)
-	defer cancel()
-
-	switch action.Type {
-	case "restart_pod":
-		return rm.restartPod(ctx, action.Parameters)
-	case "force_leader_election":
-		return rm.forceLeaderElection(ctx, action.Parameters)
-	case "reschedule_pods":
-		return rm.reschedulePods(ctx, action.Parameters)
-	case "scale_scheduler":
-		return rm.scaleScheduler(ctx, action.Parameters)
-	default:
-		return fmt.Errorf("unknown action type: %s", action.Type)
-	}
-}
-
-// restartPod 重启 Pod
-func (rm *RecoveryManager) restartPod(ctx context.Context, params map[string]interface{}) error {
-	namespace, ok := params["namespace"].(string)
-	if !ok {
-		namespace = "kube-system"
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = defaultActionTimeout
 	}
 
-	labelSelector, ok := params["labelSelector"].(string)
-	if !ok {
-		return fmt.Errorf("labelSelector parameter is required")
-	}
-
-	pods, err := rm.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
+	rm.mu.RLock()
+	executor := rm.executor
+	rm.mu.RUnlock()
+	return executor.executeRecoveryAction(rm.ctx, &RecoveryAction{
+		Type:       action.Type,
+		Parameters: action.Parameters,
+		Timeout:    timeout,
+		Timestamp:  time.Now(),
+		Status:     RecoveryActionPending,
 	})
-	if err != nil {
-		return fmt.Errorf("failed to list pods: %v", err)
-	}
-
-	for _, pod := range pods.Items {
-		err := rm.client.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-		if err != nil {
-			klog.Errorf("Failed to delete pod %s/%s: %v", namespace, pod.Name, err)
-		} else {
-			klog.Infof("Deleted pod %s/%s for restart", namespace, pod.Name)
-		}
-	}
-
-	return nil
 }
 
-// forceLeaderElection 强制 Leader 选举
-func (rm *RecoveryManager) forceLeaderElection(ctx context.Context, params map[string]interface{}) error {
-	// 这里应该实现强制 Leader 选举的逻辑
-	// 例如删除 Leader 选举的 ConfigMap 或 Lease
-	klog.Info("Forcing leader election...")
-	return nil
+// SetRecoveryExecutor 设置执行恢复动作的SchedulerRecoveryManager，默认使用默认配置
+func (rm *RecoveryManager) SetRecoveryExecutor(executor *SchedulerRecoveryManager) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.executor = executor
 }
 
-// reschedulePods 重新调度 Pod
-func (rm *RecoveryManager) reschedulePods(ctx context.Context, params map[string]interface{}) error {
-	maxPods, ok := params["maxPods"].(float64)
-	if !ok {
-		maxPods = 10
-	}
-
-	// 获取待调度的 Pod
-	pods, err := rm.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "spec.nodeName=",
-		Limit:         int64(maxPods),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to list pending pods: %v", err)
-	}
-
-	for _, pod := range pods.Items {
-		// 添加重新调度注解
-		if pod.Annotations == nil {
-			pod.Annotations = make(map[string]string)
-		}
-		pod.Annotations["scheduler.alpha.kubernetes.io/force-reschedule"] = time.Now().Format(time.RFC3339)
-
-		_, err := rm.client.CoreV1().Pods(pod.Namespace).Update(ctx, &pod, metav1.UpdateOptions{})
-		if err != nil {
-			klog.Errorf("Failed to update pod %s/%s: %v", pod.Namespace, pod.Name, err)
-		} else {
-			klog.Infof("Marked pod %s/%s for rescheduling", pod.Namespace, pod.Name)
-		}
+// SetIncidentManager 设置事件管理器，策略触发时打开事件，触发条件全部恢复后自动解决；
+// 事件升级级别的动作通过本管理器的恢复执行器执行
+func (rm *RecoveryManager) SetIncidentManager(incidents *IncidentManager) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.incidents = incidents
+	if incidents != nil {
+		incidents.SetActionRunner(func(ctx context.Context, action Action) error {
+			return rm.executeAction(action)
+		})
 	}
-
-	return nil
 }
 
-// scaleScheduler 扩缩容调度器
-func (rm *RecoveryManager) scaleScheduler(ctx context.Context, params map[string]interface{}) error {
-	replicas, ok := params["replicas"].(float64)
-	if !ok {
-		return fmt.Errorf("replicas parameter is required")
-	}
-
-	namespace, ok := params["namespace"].(string)
-	if !ok {
-		namespace = "kube-system"
-	}
-
-	deploymentName, ok := params["deploymentName"].(string)
-	if !ok {
-		deploymentName = "kube-scheduler"
-	}
-
-	deployment, err := rm.client.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get deployment: %v", err)
+// trackIncident 策略触发时打开或刷新事件，所有触发条件恢复时报告恢复
+func (rm *RecoveryManager) trackIncident(policy RecoveryPolicy, firing bool) {
+	rm.mu.RLock()
+	incidents := rm.incidents
+	rm.mu.RUnlock()
+	if incidents == nil {
+		return
 	}
 
-	deployment.Spec.Replicas = int32Ptr(int32(replicas))
-
-	_, err = rm.client.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to update deployment: %v", err)
+	switch {
+	case firing:
+		summary := fmt.Sprintf("Recovery policy %s triggered", policy.Name)
+		if policy.Description != "" {
+			summary += ": " + policy.Description
+		}
+		incidents.Open(policy.Name, summary, AlertCritical, map[string]string{"policy": policy.Name})
+	case rm.policyRecovered(policy.Name):
+		incidents.Recovered(policy.Name)
 	}
-
-	klog.Infof("Scaled scheduler deployment %s/%s to %d replicas", namespace, deploymentName, int32(replicas))
-	return nil
 }
 
 // updateLastExecution 更新最后执行时间
@@ -345,9 +299,18 @@ func (rm *RecoveryManager) GetRecoveryStatus() map[string]interface{} {
 	rm.mu.RLock()
 	defer rm.mu.RUnlock()
 
+	lastExecution := make(map[string]time.Time, len(rm.lastExecution))
+	for name, t := range rm.lastExecution {
+		lastExecution[name] = t
+	}
+
 	status := map[string]interface{}{
-		"policies":       len(rm.policies),
-		"lastExecution": rm.lastExecution,
+		"policies":      len(rm.policies),
+		"lastExecution": lastExecution,
+		"triggers":      rm.triggerStatuses(time.Now()),
+	}
+	if rm.incidents != nil {
+		status["incidents"] = rm.incidents.Incidents("")
 	}
 
 	return status
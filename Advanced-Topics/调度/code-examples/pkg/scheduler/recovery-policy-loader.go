@@ -0,0 +1,335 @@
+// recovery-policy-loader.go
+// 恢复策略加载 - 解析并校验scheduler-recovery-config中的recovery-policies.json，监听ConfigMap变化后热更新策略
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// RecoveryPoliciesKey scheduler-recovery-config中策略文件的键
+const RecoveryPoliciesKey = "recovery-policies.json"
+
+// 触发条件类型
+const (
+	TriggerAPIUnavailable       = "api_unavailable"
+	TriggerLeaderElectionFailed = "leader_election_failed"
+	TriggerPendingPodsHigh      = "pending_pods_high"
+	TriggerHealthCheckFailed    = "health_check_failed"
+)
+
+// defaultActionTimeout 未配置timeout的恢复动作的超时时间
+const defaultActionTimeout = time.Minute
+
+// RecoveryPolicyConfig recovery-policies.json的内容
+type RecoveryPolicyConfig struct {
+	Policies   []RecoveryPolicy   `json:"policies"`
+	Escalation RecoveryEscalation `json:"escalation"`
+}
+
+// RecoveryEscalation 恢复升级配置
+type RecoveryEscalation struct {
+	Levels             []EscalationLevel     `json:"levels"`
+	EscalationInterval metav1.Duration       `json:"escalationInterval"`     // 未配置delay的级别之间的间隔，默认10分钟
+	Receivers          []AlertReceiverConfig `json:"receivers,omitempty"`    // 升级通知的接收器，内置名为log的日志接收器
+	ResolveAfter       metav1.Duration       `json:"resolveAfter,omitempty"` // 触发条件恢复并保持该时长后自动解决事件
+}
+
+// EscalationLevel 恢复升级级别
+type EscalationLevel struct {
+	Level       int             `json:"level"`
+	Description string          `json:"description"`
+	Actions     []Action        `json:"actions,omitempty"`   // 事件升级到该级别时依次执行的恢复动作，与策略动作格式相同
+	Delay       metav1.Duration `json:"delay,omitempty"`     // 事件打开后通知该级别的等待时间，未配置时为(level-1)×escalationInterval
+	Receivers   []string        `json:"receivers,omitempty"` // 该级别通知的接收器，为空时只写日志
+}
+
+// knownTriggerTypes 支持的触发条件类型
+var knownTriggerTypes = map[string]bool{
+	TriggerAPIUnavailable:       true,
+	TriggerLeaderElectionFailed: true,
+	TriggerPendingPodsHigh:      true,
+	TriggerHealthCheckFailed:    true,
+}
+
+// UnmarshalJSON 支持cooldown使用"300s"形式的时长字符串
+func (p *RecoveryPolicy) UnmarshalJSON(data []byte) error {
+	type plain RecoveryPolicy
+	var raw struct {
+		plain
+		Cooldown metav1.Duration `json:"cooldown"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = RecoveryPolicy(raw.plain)
+	p.Cooldown = raw.Cooldown.Duration
+	return nil
+}
+
+// UnmarshalJSON 支持duration使用"2m"形式的时长字符串
+func (t *Trigger) UnmarshalJSON(data []byte) error {
+	type plain Trigger
+	var raw struct {
+		plain
+		Duration metav1.Duration `json:"duration"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = Trigger(raw.plain)
+	t.Duration = raw.Duration.Duration
+	return nil
+}
+
+// UnmarshalJSON 支持timeout使用"60s"形式的时长字符串
+func (a *Action) UnmarshalJSON(data []byte) error {
+	type plain Action
+	var raw struct {
+		plain
+		Timeout metav1.Duration `json:"timeout"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*a = Action(raw.plain)
+	a.Timeout = raw.Timeout.Duration
+	return nil
+}
+
+// ParseRecoveryPolicies 解析recovery-policies.json，未知的触发条件或动作类型、缺少必填参数时返回错误
+func ParseRecoveryPolicies(data []byte) (*RecoveryPolicyConfig, error) {
+	var config RecoveryPolicyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse recovery policies: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range config.Policies {
+		policy := &config.Policies[i]
+		if policy.Name == "" {
+			return nil, fmt.Errorf("policies[%d]: name is required", i)
+		}
+		if names[policy.Name] {
+			return nil, fmt.Errorf("policies[%d]: duplicate name %q", i, policy.Name)
+		}
+		names[policy.Name] = true
+		if err := validateRecoveryPolicy(policy); err != nil {
+			return nil, fmt.Errorf("policy %s: %v", policy.Name, err)
+		}
+	}
+
+	if err := validateEscalation(&config.Escalation); err != nil {
+		return nil, fmt.Errorf("escalation: %v", err)
+	}
+	return &config, nil
+}
+
+// validateEscalation 校验升级级别、接收器及其引用
+func validateEscalation(escalation *RecoveryEscalation) error {
+	if escalation.EscalationInterval.Duration < 0 || escalation.ResolveAfter.Duration < 0 {
+		return fmt.Errorf("escalationInterval and resolveAfter must not be negative")
+	}
+
+	receivers := map[string]bool{incidentLogReceiver: true}
+	for i, receiverConfig := range escalation.Receivers {
+		receiver, err := NewAlertReceiver(receiverConfig, nil)
+		if err != nil {
+			return fmt.Errorf("receivers[%d]: %v", i, err)
+		}
+		if receivers[receiver.Name()] {
+			return fmt.Errorf("receivers[%d]: duplicate name %q", i, receiver.Name())
+		}
+		receivers[receiver.Name()] = true
+	}
+
+	levels := make(map[int]bool)
+	for _, level := range escalation.Levels {
+		if level.Level < 1 {
+			return fmt.Errorf("level must be at least 1, got %d", level.Level)
+		}
+		if levels[level.Level] {
+			return fmt.Errorf("duplicate level %d", level.Level)
+		}
+		levels[level.Level] = true
+		if level.Delay.Duration < 0 {
+			return fmt.Errorf("level %d: delay must not be negative", level.Level)
+		}
+		for i := range level.Actions {
+			if err := validateAction(&level.Actions[i]); err != nil {
+				return fmt.Errorf("level %d: actions[%d]: %v", level.Level, i, err)
+			}
+		}
+		for _, receiver := range level.Receivers {
+			if !receivers[receiver] {
+				return fmt.Errorf("level %d: unknown receiver %q", level.Level, receiver)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRecoveryPolicy 校验策略并补全动作的默认超时
+func validateRecoveryPolicy(policy *RecoveryPolicy) error {
+	if len(policy.Triggers) == 0 {
+		return fmt.Errorf("at least one trigger is required")
+	}
+	if len(policy.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	if policy.Cooldown < 0 {
+		return fmt.Errorf("cooldown must not be negative")
+	}
+
+	for i, trigger := range policy.Triggers {
+		if !knownTriggerTypes[trigger.Type] {
+			return fmt.Errorf("triggers[%d]: unknown trigger type %q", i, trigger.Type)
+		}
+		if trigger.Threshold < 0 || trigger.Duration < 0 {
+			return fmt.Errorf("triggers[%d]: threshold and duration must not be negative", i)
+		}
+		if trigger.Type == TriggerHealthCheckFailed && trigger.Condition == "" {
+			return fmt.Errorf("triggers[%d]: condition must name the health check", i)
+		}
+	}
+
+	for i := range policy.Actions {
+		if err := validateAction(&policy.Actions[i]); err != nil {
+			return fmt.Errorf("actions[%d]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// validateAction 校验动作参数并补全默认超时
+func validateAction(action *Action) error {
+	if err := validateActionParameters(action.Type, action.Parameters); err != nil {
+		return err
+	}
+	if action.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if action.Timeout == 0 {
+		action.Timeout = defaultActionTimeout
+	}
+	return nil
+}
+
+// LoadRecoveryPoliciesFile 从挂载的ConfigMap文件加载恢复策略
+func LoadRecoveryPoliciesFile(path string) (*RecoveryPolicyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recovery policies: %v", err)
+	}
+	return ParseRecoveryPolicies(data)
+}
+
+// LoadRecoveryPoliciesFromConfigMap 从scheduler-recovery-config ConfigMap加载恢复策略
+func LoadRecoveryPoliciesFromConfigMap(ctx context.Context, client kubernetes.Interface, namespace, name string) (*RecoveryPolicyConfig, error) {
+	cm, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configmap %s/%s: %v", namespace, name, err)
+	}
+	return recoveryPoliciesFromConfigMap(cm)
+}
+
+func recoveryPoliciesFromConfigMap(cm *v1.ConfigMap) (*RecoveryPolicyConfig, error) {
+	data, exists := cm.Data[RecoveryPoliciesKey]
+	if !exists {
+		return nil, fmt.Errorf("configmap %s/%s has no %s", cm.Namespace, cm.Name, RecoveryPoliciesKey)
+	}
+	return ParseRecoveryPolicies([]byte(data))
+}
+
+// SetPolicies 替换恢复策略，已有策略的冷却时间记录保留
+func (rm *RecoveryManager) SetPolicies(policies []RecoveryPolicy) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.policies = policies
+}
+
+// Policies 返回当前的恢复策略
+func (rm *RecoveryManager) Policies() []RecoveryPolicy {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	return append([]RecoveryPolicy(nil), rm.policies...)
+}
+
+// WatchPolicies 加载恢复策略ConfigMap并持续监听，内容变化且校验通过时替换策略，校验失败时保留当前策略
+// 首次加载失败时返回错误，不启动监听
+func (rm *RecoveryManager) WatchPolicies(namespace, name string) error {
+	config, err := LoadRecoveryPoliciesFromConfigMap(rm.ctx, rm.client, namespace, name)
+	if err != nil {
+		return err
+	}
+	rm.SetPolicies(config.Policies)
+	rm.setEscalation(config.Escalation)
+	klog.Infof("Loaded %d recovery policies from configmap %s/%s", len(config.Policies), namespace, name)
+
+	factory := informers.NewSharedInformerFactoryWithOptions(rm.client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	current := config
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			rm.reloadPolicies(obj.(*v1.ConfigMap), &current)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			rm.reloadPolicies(newObj.(*v1.ConfigMap), &current)
+		},
+		DeleteFunc: func(obj interface{}) {
+			klog.Warningf("Recovery policies configmap %s/%s deleted, keeping current policies", namespace, name)
+		},
+	})
+
+	factory.Start(rm.ctx.Done())
+	if !cache.WaitForCacheSync(rm.ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("failed to sync recovery policies configmap %s/%s", namespace, name)
+	}
+	return nil
+}
+
+// reloadPolicies 解析ConfigMap中的策略，与当前策略不同时替换；事件处理函数串行调用，current无需加锁
+func (rm *RecoveryManager) reloadPolicies(cm *v1.ConfigMap, current **RecoveryPolicyConfig) {
+	config, err := recoveryPoliciesFromConfigMap(cm)
+	if err != nil {
+		klog.Errorf("Rejected recovery policies from configmap %s/%s, keeping current policies: %v", cm.Namespace, cm.Name, err)
+		return
+	}
+	if equality.Semantic.DeepEqual(config, *current) {
+		return
+	}
+	*current = config
+	rm.SetPolicies(config.Policies)
+	rm.setEscalation(config.Escalation)
+	klog.Infof("Reloaded %d recovery policies from configmap %s/%s", len(config.Policies), cm.Namespace, cm.Name)
+}
+
+// setEscalation 将升级配置应用到事件管理器，未设置事件管理器时忽略
+func (rm *RecoveryManager) setEscalation(escalation RecoveryEscalation) {
+	rm.mu.RLock()
+	incidents := rm.incidents
+	rm.mu.RUnlock()
+	if incidents == nil {
+		return
+	}
+	if err := incidents.SetEscalation(escalation); err != nil {
+		klog.Errorf("Failed to apply escalation config: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+// recovery-triggers.go
+// 恢复触发窗口 - 记录触发条件的采样，条件持续超过阈值达到Duration时才触发恢复，避免瞬时抖动引起重启
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TriggerObservation 触发条件的一次采样
+type TriggerObservation struct {
+	Time     time.Time `json:"time"`
+	Value    float64   `json:"value"`
+	Breached bool      `json:"breached"`
+}
+
+// TriggerStatus 触发条件的窗口状态
+type TriggerStatus struct {
+	Policy        string               `json:"policy"`
+	Type          string               `json:"type"`
+	Condition     string               `json:"condition"`
+	Threshold     float64              `json:"threshold"`
+	Duration      time.Duration        `json:"duration"`
+	Observations  []TriggerObservation `json:"observations"`            // 窗口内的采样
+	BreachedSince *time.Time           `json:"breachedSince,omitempty"` // 本次连续超过阈值的开始时间
+	HeldFor       time.Duration        `json:"heldFor"`                 // 已连续超过阈值的时长
+	Firing        bool                 `json:"firing"`                  // 是否已满足触发条件
+}
+
+// triggerWindow 单个触发条件的滑动窗口
+type triggerWindow struct {
+	policy        string
+	trigger       Trigger
+	observations  []TriggerObservation
+	breachedSince time.Time // 零值表示当前未超过阈值
+	fresh         bool      // 最近一轮是否成功采样
+}
+
+// triggerBreached 判断采样值是否超过阈值
+// pending_pods_high的值为待调度Pod数量，超过阈值时成立；其他类型的值为0或1，
+// 阈值不足1时按1处理，即条件不成立时不会触发
+func triggerBreached(trigger Trigger, value float64) bool {
+	if trigger.Type == TriggerPendingPodsHigh {
+		return value > trigger.Threshold
+	}
+	threshold := trigger.Threshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	return value >= threshold
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func triggerWindowKey(policy string, index int, trigger Trigger) string {
+	return fmt.Sprintf("%s/%d/%s", policy, index, trigger.Type)
+}
+
+// observeTrigger 记录一次采样并返回触发条件是否已持续满足；ok为false表示本轮未能采样
+func (rm *RecoveryManager) observeTrigger(policy string, index int, trigger Trigger, now time.Time, value float64, ok bool) bool {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	key := triggerWindowKey(policy, index, trigger)
+	window, exists := rm.triggerWindows[key]
+	if !exists || window.trigger != trigger {
+		// 策略重新加载后触发条件变化时重新开始统计
+		window = &triggerWindow{policy: policy, trigger: trigger}
+		rm.triggerWindows[key] = window
+	}
+
+	window.fresh = ok
+	if ok {
+		window.observe(now, value)
+	}
+	return window.firing(now)
+}
+
+// observe 追加采样，更新连续超过阈值的开始时间并丢弃窗口外的采样
+func (w *triggerWindow) observe(now time.Time, value float64) {
+	breached := triggerBreached(w.trigger, value)
+	w.observations = append(w.observations, TriggerObservation{Time: now, Value: value, Breached: breached})
+
+	switch {
+	case !breached:
+		w.breachedSince = time.Time{}
+	case w.breachedSince.IsZero():
+		w.breachedSince = now
+	}
+
+	cutoff := now.Add(-w.trigger.Duration)
+	i := 0
+	for i < len(w.observations)-1 && w.observations[i].Time.Before(cutoff) {
+		i++
+	}
+	w.observations = w.observations[i:]
+}
+
+// firing 最近一轮成功采样、仍超过阈值且已持续Duration时返回true；Duration为0时单次超过阈值即触发
+func (w *triggerWindow) firing(now time.Time) bool {
+	return w.fresh && !w.breachedSince.IsZero() && now.Sub(w.breachedSince) >= w.trigger.Duration
+}
+
+// policyRecovered 策略的所有触发条件在最近一轮都成功采样且未超过阈值时返回true
+func (rm *RecoveryManager) policyRecovered(policy string) bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	found := false
+	for _, window := range rm.triggerWindows {
+		if window.policy != policy {
+			continue
+		}
+		if !window.fresh || !window.breachedSince.IsZero() {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// resetTriggerWindows 恢复执行后清空策略的触发窗口，需重新持续满足条件才会再次触发
+func (rm *RecoveryManager) resetTriggerWindows(policy string) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for _, window := range rm.triggerWindows {
+		if window.policy == policy {
+			window.observations = nil
+			window.breachedSince = time.Time{}
+			window.fresh = false
+		}
+	}
+}
+
+// pruneTriggerWindows 删除已不存在的策略的触发窗口
+func (rm *RecoveryManager) pruneTriggerWindows(policies []RecoveryPolicy) {
+	keys := make(map[string]bool)
+	for _, policy := range policies {
+		for i, trigger := range policy.Triggers {
+			keys[triggerWindowKey(policy.Name, i, trigger)] = true
+		}
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for key := range rm.triggerWindows {
+		if !keys[key] {
+			delete(rm.triggerWindows, key)
+		}
+	}
+}
+
+// triggerStatuses 返回所有触发窗口的状态，调用方需持有rm.mu
+func (rm *RecoveryManager) triggerStatuses(now time.Time) []TriggerStatus {
+	statuses := make([]TriggerStatus, 0, len(rm.triggerWindows))
+	for _, window := range rm.triggerWindows {
+		status := TriggerStatus{
+			Policy:       window.policy,
+			Type:         window.trigger.Type,
+			Condition:    window.trigger.Condition,
+			Threshold:    window.trigger.Threshold,
+			Duration:     window.trigger.Duration,
+			Observations: append([]TriggerObservation(nil), window.observations...),
+			Firing:       window.firing(now),
+		}
+		if !window.breachedSince.IsZero() {
+			since := window.breachedSince
+			status.BreachedSince = &since
+			status.HeldFor = now.Sub(since)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Policy != statuses[j].Policy {
+			return statuses[i].Policy < statuses[j].Policy
+		}
+		return statuses[i].Type < statuses[j].Type
+	})
+	return statuses
+}
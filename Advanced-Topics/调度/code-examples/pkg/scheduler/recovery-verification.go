@@ -0,0 +1,326 @@
+// recovery-verification.go
+// 恢复动作校验 - 每个恢复动作执行后轮询后置条件，超时未满足时执行补偿动作
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kubernetes-fundamentals/internal/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// 调度器Pod所在位置
+const (
+	schedulerPodNamespace = "kube-system"
+	schedulerPodSelector  = "component=kube-scheduler"
+)
+
+// 恢复动作状态
+const (
+	RecoveryActionPending        = "pending"
+	RecoveryActionExecuting      = "executing"
+	RecoveryActionCompleted      = "completed"
+	RecoveryActionFailed         = "failed"
+	RecoveryActionRolledBack     = "rolled_back"
+	RecoveryActionRollbackFailed = "rollback_failed"
+	RecoveryActionDryRun         = "dry_run" // DryRun模式下只记录了计划，未修改集群
+)
+
+// recoveryVerifyInterval 后置条件的轮询间隔
+const recoveryVerifyInterval = 5 * time.Second
+
+// recoveryTimeout 返回单个恢复动作（执行、校验）的超时时间
+func (srm *SchedulerRecoveryManager) recoveryTimeout() time.Duration {
+	if srm.config.RecoveryTimeout > 0 {
+		return srm.config.RecoveryTimeout
+	}
+	return 5 * time.Minute
+}
+
+// runRecoveryAction 在动作的Timeout（默认RecoveryTimeout）内执行动作并等待后置条件满足，失败时执行补偿动作
+func (srm *SchedulerRecoveryManager) runRecoveryAction(ctx context.Context, action *RecoveryAction, handler RecoveryActionHandler) error {
+	timeout := action.Timeout
+	if timeout <= 0 {
+		timeout = srm.recoveryTimeout()
+	}
+	if srm.config.DryRun && !supportsDryRun(handler) {
+		action.Status = RecoveryActionDryRun
+		action.Result = fmt.Sprintf("dry run: would execute %s on %s with parameters %v", action.Type, action.Target, action.Parameters)
+		klog.Infof("Dry run: would execute recovery action %s (target=%s, parameters=%v)", action.Type, action.Target, action.Parameters)
+		return nil
+	}
+
+	actionCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	plan, err := handler.Execute(actionCtx, &ActionRequest{
+		Action:  action,
+		Client:  srm.client,
+		DryRun:  srm.config.DryRun,
+		manager: srm,
+	})
+	if srm.config.DryRun {
+		// 动作未修改集群，没有需要校验的后置条件
+		if err != nil {
+			action.Status = RecoveryActionFailed
+			action.Result = err.Error()
+			return err
+		}
+		action.Status = RecoveryActionDryRun
+		return nil
+	}
+	if err == nil && plan != nil && plan.Verify != nil {
+		err = verifyRecovery(actionCtx, plan)
+	}
+	if err == nil {
+		action.Status = RecoveryActionCompleted
+		return nil
+	}
+
+	action.Status = RecoveryActionFailed
+	action.Result = err.Error()
+	if plan == nil || plan.Rollback == nil {
+		return err
+	}
+
+	// 动作的超时已用尽，补偿动作使用新的超时
+	klog.Warningf("Recovery action %s failed verification, rolling back: %v", action.Type, err)
+	rollbackCtx, rollbackCancel := context.WithTimeout(ctx, srm.recoveryTimeout())
+	defer rollbackCancel()
+	if rollbackErr := plan.Rollback(rollbackCtx); rollbackErr != nil {
+		action.Status = RecoveryActionRollbackFailed
+		action.Result = fmt.Sprintf("%v; rollback failed: %v", err, rollbackErr)
+		return fmt.Errorf("%v; rollback failed: %v", err, rollbackErr)
+	}
+	action.Status = RecoveryActionRolledBack
+	action.Result = fmt.Sprintf("%v; rolled back", err)
+	return fmt.Errorf("%v; rolled back", err)
+}
+
+// verifyRecovery 轮询后置条件直到满足或ctx超时
+func verifyRecovery(ctx context.Context, plan *RecoveryPlan) error {
+	var lastMessage string
+	err := wait.PollUntilContextCancel(ctx, recoveryVerifyInterval, true, func(ctx context.Context) (bool, error) {
+		ok, message, err := plan.Verify(ctx)
+		if err != nil {
+			// 临时错误不终止校验，超时后一并报告
+			lastMessage = err.Error()
+			return false, nil
+		}
+		lastMessage = message
+		return ok, nil
+	})
+	if err != nil {
+		return fmt.Errorf("post-condition not met (%s): %v", lastMessage, err)
+	}
+	return nil
+}
+
+// listSchedulerPods 列出调度器Pod，按名称排序
+func (srm *SchedulerRecoveryManager) listSchedulerPods(ctx context.Context) ([]v1.Pod, error) {
+	pods, err := srm.client.CoreV1().Pods(schedulerPodNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: schedulerPodSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduler pods: %v", err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+	return pods.Items, nil
+}
+
+// isSchedulerPod Pod是否为调度器Pod
+func isSchedulerPod(pod *v1.Pod) bool {
+	if pod.Namespace != schedulerPodNamespace {
+		return false
+	}
+	selector, err := labels.Parse(schedulerPodSelector)
+	return err == nil && selector.Matches(labels.Set(pod.Labels))
+}
+
+// servingSchedulerPods 统计就绪的调度器Pod数量，不计入excluded中的Pod
+func (srm *SchedulerRecoveryManager) servingSchedulerPods(ctx context.Context, excluded map[types.UID]bool) (int, error) {
+	pods, err := srm.listSchedulerPods(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return countServingPods(pods, excluded), nil
+}
+
+// countServingPods 统计就绪的Pod数量，不计入excluded中的Pod
+func countServingPods(pods []v1.Pod, excluded map[types.UID]bool) int {
+	serving := 0
+	for i := range pods {
+		if !excluded[pods[i].UID] && utils.IsPodServing(&pods[i]) {
+			serving++
+		}
+	}
+	return serving
+}
+
+// verifyPodsReplaced 后置条件：匹配labelSelector的就绪Pod（不含已删除的Pod）不少于删除前
+func (srm *SchedulerRecoveryManager) verifyPodsReplaced(namespace, labelSelector string, want int, deleted map[types.UID]bool) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		pods, err := srm.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return false, "", fmt.Errorf("failed to list pods: %v", err)
+		}
+		if serving := countServingPods(pods.Items, deleted); serving < want {
+			return false, fmt.Sprintf("%d/%d replacement pods matching %s ready", serving, want, labelSelector), nil
+		}
+		return true, "", nil
+	}
+}
+
+// verifyLeaderElected 后置条件：租约被清除后有副本重新获取并续约了租约
+func (srm *SchedulerRecoveryManager) verifyLeaderElected(opts utils.LeaderLeaseOptions) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		state, err := utils.InspectLeaderLease(ctx, srm.client, opts)
+		if err != nil {
+			return false, "", err
+		}
+		if state.Holder == "" || state.Stale {
+			return false, fmt.Sprintf("no replica has taken over the lease (holder %q)", state.Holder), nil
+		}
+		return true, "", nil
+	}
+}
+
+// verifyDeploymentScaled 后置条件：Deployment已观察到最新的规格，且期望副本全部更新并就绪
+func (srm *SchedulerRecoveryManager) verifyDeploymentScaled(namespace, name string, replicas int32) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		deployment, err := srm.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, "", fmt.Errorf("failed to get deployment: %v", err)
+		}
+		status := deployment.Status
+		if status.ObservedGeneration < deployment.Generation {
+			return false, fmt.Sprintf("deployment %s/%s not yet observed by its controller", namespace, name), nil
+		}
+		if status.UpdatedReplicas < replicas || status.ReadyReplicas < replicas {
+			return false, fmt.Sprintf("%d/%d replicas of %s/%s updated and ready", min(status.UpdatedReplicas, status.ReadyReplicas), replicas, namespace, name), nil
+		}
+		return true, "", nil
+	}
+}
+
+// restoreDeploymentReplicas 补偿：恢复Deployment扩缩容前的副本数
+func (srm *SchedulerRecoveryManager) restoreDeploymentReplicas(namespace, name string, replicas *int32) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			deployment, err := srm.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			deployment.Spec.Replicas = replicas
+			_, err = srm.client.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+			return err
+		})
+	}
+}
+
+// verifySchedulerRestart 后置条件：就绪调度器Pod不少于重启前，且领导者在重启开始后续约过租约
+func (srm *SchedulerRecoveryManager) verifySchedulerRestart(want int, restarted map[types.UID]bool, start time.Time) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		serving, err := srm.servingSchedulerPods(ctx, restarted)
+		if err != nil {
+			return false, "", err
+		}
+		if serving < want {
+			return false, fmt.Sprintf("%d/%d scheduler pods ready", serving, want), nil
+		}
+
+		state, err := utils.InspectLeaderLease(ctx, srm.client, utils.LeaderLeaseOptions{
+			Namespace:         schedulerPodNamespace,
+			CandidateSelector: schedulerPodSelector,
+		})
+		if err != nil {
+			return false, "", err
+		}
+		if state.Holder == "" || state.Stale || state.SinceRenew > time.Since(start) {
+			return false, fmt.Sprintf("leader lease not renewed since restart (holder %q)", state.Holder), nil
+		}
+		return true, "", nil
+	}
+}
+
+// rollbackSchedulerRestart 补偿：重启后领导者租约未恢复时，将租约交给就绪的副本
+func (srm *SchedulerRecoveryManager) rollbackSchedulerRestart(ctx context.Context) error {
+	handedOver, message, err := utils.ForceLeaderHandover(ctx, srm.client, utils.LeaderLeaseOptions{
+		Namespace:         schedulerPodNamespace,
+		CandidateSelector: schedulerPodSelector,
+	})
+	if err != nil {
+		return err
+	}
+	if !handedOver {
+		// 补偿没有生效，不能报告为已回滚
+		return fmt.Errorf("leader lease not handed over: %s", message)
+	}
+	return nil
+}
+
+// countUnscheduledPods 统计尚未调度的Pending Pod数量
+func (srm *SchedulerRecoveryManager) countUnscheduledPods(ctx context.Context) (int, error) {
+	pods, err := srm.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pending pods: %v", err)
+	}
+	return len(pods.Items), nil
+}
+
+// verifyQueueShrinking 后置条件：待调度Pod数量低于动作执行前，或已清空
+func (srm *SchedulerRecoveryManager) verifyQueueShrinking(before int) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		pending, err := srm.countUnscheduledPods(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		if pending == 0 || pending < before {
+			return true, "", nil
+		}
+		return false, fmt.Sprintf("%d pods still pending, %d before recovery", pending, before), nil
+	}
+}
+
+// verifyQueueNotGrowing 后置条件：待调度Pod数量不高于动作执行前
+func (srm *SchedulerRecoveryManager) verifyQueueNotGrowing(before int) func(ctx context.Context) (bool, string, error) {
+	return func(ctx context.Context) (bool, string, error) {
+		pending, err := srm.countUnscheduledPods(ctx)
+		if err != nil {
+			return false, "", err
+		}
+		if pending > before {
+			return false, fmt.Sprintf("%d pods pending, up from %d before recovery", pending, before), nil
+		}
+		return true, "", nil
+	}
+}
+
+// uncordonNodes 补偿：恢复本次封锁的节点
+func (srm *SchedulerRecoveryManager) uncordonNodes(nodes []string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		drainer := &nodeDrainer{client: srm.client}
+		var failed []string
+		for _, name := range nodes {
+			if err := drainer.uncordon(ctx, name); err != nil {
+				klog.Error(err)
+				failed = append(failed, name)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to uncordon nodes %v", failed)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,256 @@
+// scheduler-auto-tuning.go
+// 调度器自动调优 - 逐个调整评分节点比例和并行度，实测P95调度延迟改善时才保留改动
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// AutoTuneConfig 自动调优配置
+type AutoTuneConfig struct {
+	Target       SchedulerConfigTarget       // 调度器配置所在位置
+	ApplyOptions SchedulerConfigApplyOptions // 每次调整的发布选项，通常需要Restart使配置生效
+
+	MeasurementWindow time.Duration // 每次测量的时长，默认10分钟
+	MinSamples        uint64        // 测量窗口内至少需要的调度次数，不足时实验无结论，默认100
+	MinImprovement    float64       // P95至少改善的比例才保留改动，默认0.05
+	MaxExperiments    int           // 最多进行的实验次数，0表示直到ctx取消
+
+	MinPercentageOfNodesToScore int // 评分节点比例下限，默认10
+	MaxPercentageOfNodesToScore int // 评分节点比例上限，默认100
+	PercentageStep              int // 评分节点比例每次调整的步长，默认10
+	MinParallelism              int // 并行度下限，默认4
+	MaxParallelism              int // 并行度上限，默认64
+}
+
+// TuningExperiment 一次调优实验记录
+type TuningExperiment struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Parameter  string // "percentageOfNodesToScore" 或 "parallelism"
+	From       int
+	To         int
+	Baseline   LatencyWindow
+	Candidate  LatencyWindow
+	Kept       bool   // 是否保留改动
+	Reason     string // 保留或回退的原因
+}
+
+// tuningCandidate 一个候选调整
+type tuningCandidate struct {
+	parameter string
+	from, to  int
+	apply     func(*PerformanceConfig, int)
+}
+
+// autoTuneState 自动调优的实验记录
+type autoTuneState struct {
+	mu          sync.Mutex
+	experiments []TuningExperiment
+}
+
+// withDefaults 补全自动调优配置的默认值
+func (c AutoTuneConfig) withDefaults() AutoTuneConfig {
+	if c.MeasurementWindow == 0 {
+		c.MeasurementWindow = 10 * time.Minute
+	}
+	if c.MinSamples == 0 {
+		c.MinSamples = 100
+	}
+	if c.MinImprovement == 0 {
+		c.MinImprovement = 0.05
+	}
+	if c.MinPercentageOfNodesToScore == 0 {
+		c.MinPercentageOfNodesToScore = 10
+	}
+	if c.MaxPercentageOfNodesToScore == 0 {
+		c.MaxPercentageOfNodesToScore = 100
+	}
+	if c.PercentageStep == 0 {
+		c.PercentageStep = 10
+	}
+	if c.MinParallelism == 0 {
+		c.MinParallelism = 4
+	}
+	if c.MaxParallelism == 0 {
+		c.MaxParallelism = 64
+	}
+	return c
+}
+
+// Experiments 返回已完成的调优实验记录
+func (spt *SchedulerPerformanceTuner) Experiments() []TuningExperiment {
+	spt.tuning.mu.Lock()
+	defer spt.tuning.mu.Unlock()
+	return append([]TuningExperiment(nil), spt.tuning.experiments...)
+}
+
+// RunAutoTuning 运行测量驱动的调优循环，直到ctx取消或达到MaxExperiments
+// 每轮先在当前配置下测量基线，再应用一个候选调整并测量；
+// 候选配置的P95延迟比基线改善超过MinImprovement时保留，否则恢复原配置
+func (spt *SchedulerPerformanceTuner) RunAutoTuning(ctx context.Context, source SchedulingLatencySource, config AutoTuneConfig) error {
+	config = config.withDefaults()
+
+	// 确保调度器运行的是调优器当前的配置，作为实验起点
+	if _, err := spt.ApplyOptimizedConfig(ctx, config.Target, config.ApplyOptions); err != nil {
+		return fmt.Errorf("failed to apply initial scheduler configuration: %v", err)
+	}
+
+	for round := 0; config.MaxExperiments == 0 || round < config.MaxExperiments; round++ {
+		candidate, ok := spt.nextCandidate(round, config)
+		if !ok {
+			klog.Info("No tuning candidates within bounds, stopping auto-tuning")
+			return nil
+		}
+
+		experiment, err := spt.runExperiment(ctx, source, candidate, config)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		spt.tuning.mu.Lock()
+		spt.tuning.experiments = append(spt.tuning.experiments, experiment)
+		spt.tuning.mu.Unlock()
+
+		klog.Infof("Tuning experiment %s %d -> %d: baseline P95 %v (%d samples), candidate P95 %v (%d samples), kept=%v: %s",
+			experiment.Parameter, experiment.From, experiment.To,
+			experiment.Baseline.P95, experiment.Baseline.Samples,
+			experiment.Candidate.P95, experiment.Candidate.Samples,
+			experiment.Kept, experiment.Reason)
+	}
+	return nil
+}
+
+// nextCandidate 轮流尝试降低/提高评分节点比例和并行度，跳过超出范围的调整
+func (spt *SchedulerPerformanceTuner) nextCandidate(round int, config AutoTuneConfig) (tuningCandidate, bool) {
+	percentage := spt.config.PercentageOfNodesToScore
+	parallelism := spt.config.NodeScoreParallelism
+
+	candidates := []tuningCandidate{
+		{
+			parameter: "percentageOfNodesToScore",
+			from:      percentage,
+			to:        clampInt(percentage-config.PercentageStep, config.MinPercentageOfNodesToScore, config.MaxPercentageOfNodesToScore),
+			apply:     func(c *PerformanceConfig, value int) { c.PercentageOfNodesToScore = value },
+		},
+		{
+			parameter: "parallelism",
+			from:      parallelism,
+			to:        clampInt(parallelism*2, config.MinParallelism, config.MaxParallelism),
+			apply:     func(c *PerformanceConfig, value int) { c.NodeScoreParallelism = value },
+		},
+		{
+			parameter: "percentageOfNodesToScore",
+			from:      percentage,
+			to:        clampInt(percentage+config.PercentageStep, config.MinPercentageOfNodesToScore, config.MaxPercentageOfNodesToScore),
+			apply:     func(c *PerformanceConfig, value int) { c.PercentageOfNodesToScore = value },
+		},
+		{
+			parameter: "parallelism",
+			from:      parallelism,
+			to:        clampInt(parallelism/2, config.MinParallelism, config.MaxParallelism),
+			apply:     func(c *PerformanceConfig, value int) { c.NodeScoreParallelism = value },
+		},
+	}
+
+	for i := range candidates {
+		candidate := candidates[(round+i)%len(candidates)]
+		if candidate.to != candidate.from {
+			return candidate, true
+		}
+	}
+	return tuningCandidate{}, false
+}
+
+// runExperiment 测量基线，应用候选调整后再次测量，未改善时回退
+func (spt *SchedulerPerformanceTuner) runExperiment(ctx context.Context, source SchedulingLatencySource, candidate tuningCandidate, config AutoTuneConfig) (TuningExperiment, error) {
+	experiment := TuningExperiment{
+		StartedAt: time.Now(),
+		Parameter: candidate.parameter,
+		From:      candidate.from,
+		To:        candidate.to,
+	}
+
+	baseline, err := measureLatency(ctx, source, config.MeasurementWindow)
+	if err != nil {
+		return experiment, fmt.Errorf("failed to measure baseline: %v", err)
+	}
+	experiment.Baseline = baseline
+
+	previous := *spt.config
+	candidate.apply(spt.config, candidate.to)
+	result, err := spt.ApplyOptimizedConfig(ctx, config.Target, config.ApplyOptions)
+	if err != nil {
+		*spt.config = previous
+		experiment.FinishedAt = time.Now()
+		if result != nil && result.RolledBack {
+			experiment.Reason = fmt.Sprintf("rollout failed and was rolled back: %v", err)
+			return experiment, nil
+		}
+		return experiment, fmt.Errorf("failed to apply candidate configuration: %v", err)
+	}
+
+	measured, measureErr := measureLatency(ctx, source, config.MeasurementWindow)
+	experiment.Candidate = measured
+
+	switch {
+	case measureErr != nil:
+		experiment.Reason = fmt.Sprintf("failed to measure candidate: %v", measureErr)
+	case baseline.Samples < config.MinSamples || measured.Samples < config.MinSamples:
+		experiment.Reason = fmt.Sprintf("inconclusive: fewer than %d scheduling attempts in a window", config.MinSamples)
+	case float64(measured.P95) < float64(baseline.P95)*(1-config.MinImprovement):
+		experiment.Kept = true
+		experiment.Reason = fmt.Sprintf("P95 improved by %.1f%%", 100*(1-float64(measured.P95)/float64(baseline.P95)))
+	default:
+		experiment.Reason = "P95 did not improve enough"
+	}
+
+	if !experiment.Kept {
+		*spt.config = previous
+		if _, err := spt.ApplyOptimizedConfig(ctx, config.Target, config.ApplyOptions); err != nil {
+			experiment.FinishedAt = time.Now()
+			return experiment, fmt.Errorf("failed to revert candidate configuration: %v", err)
+		}
+	}
+
+	experiment.FinishedAt = time.Now()
+	return experiment, nil
+}
+
+// measureLatency 在窗口开始和结束时各取一次快照，计算窗口内的调度延迟
+func measureLatency(ctx context.Context, source SchedulingLatencySource, window time.Duration) (LatencyWindow, error) {
+	start, err := source.Snapshot(ctx)
+	if err != nil {
+		return LatencyWindow{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return LatencyWindow{}, ctx.Err()
+	case <-time.After(window):
+	}
+
+	end, err := source.Snapshot(ctx)
+	if err != nil {
+		return LatencyWindow{}, err
+	}
+	return end.Since(start), nil
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
@@ -0,0 +1,237 @@
+// scheduler-config-rollout.go
+// 调度器配置发布 - 将优化后的KubeSchedulerConfiguration写入ConfigMap，滚动重启调度器并在健康检查失败时回滚
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	schedulerconfigv1 "k8s.io/kube-scheduler/config/v1"
+	"k8s.io/utils/ptr"
+)
+
+// SchedulerConfigTarget 调度器配置所在的ConfigMap和使用它的调度器Deployment
+type SchedulerConfigTarget struct {
+	Namespace      string // 默认kube-system
+	ConfigMapName  string // 默认scheduler-config
+	ConfigKey      string // ConfigMap中配置文件的键，默认scheduler-config.yaml
+	DeploymentName string // 调度器Deployment，如kube-scheduler-ha；为空时不重启
+}
+
+// SchedulerConfigApplyOptions 配置发布选项
+type SchedulerConfigApplyOptions struct {
+	Restart        bool          // 写入配置后滚动重启调度器Deployment
+	RolloutTimeout time.Duration // 等待滚动重启完成并通过健康检查的时间，默认5分钟
+}
+
+// SchedulerConfigApplyResult 配置发布结果
+type SchedulerConfigApplyResult struct {
+	Changed    bool   // 配置是否发生变化
+	Restarted  bool   // 是否执行了滚动重启
+	RolledBack bool   // 健康检查失败后是否已回滚
+	Previous   string // 发布前的配置
+	Current    string // 发布后生效的配置
+}
+
+// withDefaults 补全目标的默认值
+func (t SchedulerConfigTarget) withDefaults() SchedulerConfigTarget {
+	if t.Namespace == "" {
+		t.Namespace = metav1.NamespaceSystem
+	}
+	if t.ConfigMapName == "" {
+		t.ConfigMapName = "scheduler-config"
+	}
+	if t.ConfigKey == "" {
+		t.ConfigKey = "scheduler-config.yaml"
+	}
+	return t
+}
+
+// ApplyOptimizedConfig 在ConfigMap中现有配置的基础上应用性能参数并写回
+// 配置未变化时不做任何操作；Restart为true时滚动重启调度器，
+// 重启未在RolloutTimeout内完成或健康检查失败时恢复原配置并再次重启
+func (spt *SchedulerPerformanceTuner) ApplyOptimizedConfig(ctx context.Context, target SchedulerConfigTarget, opts SchedulerConfigApplyOptions) (*SchedulerConfigApplyResult, error) {
+	target = target.withDefaults()
+	if opts.RolloutTimeout == 0 {
+		opts.RolloutTimeout = 5 * time.Minute
+	}
+
+	previous, err := spt.readSchedulerConfig(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing *schedulerconfigv1.KubeSchedulerConfiguration
+	if previous != "" {
+		existing, err = parseSchedulerConfiguration(previous)
+		if err != nil {
+			return nil, fmt.Errorf("configmap %s/%s: %v", target.Namespace, target.ConfigMapName, err)
+		}
+	}
+	config := spt.BuildSchedulerConfiguration(existing)
+	if err := ValidateSchedulerConfiguration(config); err != nil {
+		return nil, err
+	}
+	desired, err := marshalSchedulerConfiguration(config, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SchedulerConfigApplyResult{Previous: previous, Current: previous}
+	if desired == previous {
+		klog.Infof("Scheduler configuration in %s/%s is already up to date", target.Namespace, target.ConfigMapName)
+		return result, nil
+	}
+
+	if err := spt.writeSchedulerConfig(ctx, target, desired); err != nil {
+		return nil, err
+	}
+	result.Changed = true
+	result.Current = desired
+	klog.Infof("Wrote optimized scheduler configuration to %s/%s", target.Namespace, target.ConfigMapName)
+
+	if !opts.Restart || target.DeploymentName == "" {
+		return result, nil
+	}
+
+	result.Restarted = true
+	rolloutErr := spt.restartScheduler(ctx, target, config.Profiles, opts.RolloutTimeout)
+	if rolloutErr == nil {
+		return result, nil
+	}
+
+	// 健康检查失败，恢复原配置并再次重启
+	klog.Errorf("Scheduler rollout failed, rolling back configuration: %v", rolloutErr)
+	if err := spt.writeSchedulerConfig(ctx, target, previous); err != nil {
+		return result, fmt.Errorf("scheduler rollout failed (%v) and restoring the previous configuration failed: %v", rolloutErr, err)
+	}
+	result.RolledBack = true
+	result.Current = previous
+	if err := spt.restartScheduler(ctx, target, nil, opts.RolloutTimeout); err != nil {
+		return result, fmt.Errorf("scheduler rollout failed (%v) and the rollback rollout failed: %v", rolloutErr, err)
+	}
+	return result, fmt.Errorf("scheduler rollout failed, previous configuration restored: %v", rolloutErr)
+}
+
+// readSchedulerConfig 读取ConfigMap中的调度器配置，ConfigMap不存在时返回空字符串
+func (spt *SchedulerPerformanceTuner) readSchedulerConfig(ctx context.Context, target SchedulerConfigTarget) (string, error) {
+	cm, err := spt.client.CoreV1().ConfigMaps(target.Namespace).Get(ctx, target.ConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get configmap %s/%s: %v", target.Namespace, target.ConfigMapName, err)
+	}
+	return cm.Data[target.ConfigKey], nil
+}
+
+// writeSchedulerConfig 写入调度器配置，data为空时删除该键；并发修改时重新读取后重试
+func (spt *SchedulerPerformanceTuner) writeSchedulerConfig(ctx context.Context, target SchedulerConfigTarget, data string) error {
+	configMaps := spt.client.CoreV1().ConfigMaps(target.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, target.ConfigMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			if data == "" {
+				return nil
+			}
+			_, err = configMaps.Create(ctx, &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: target.ConfigMapName, Namespace: target.Namespace},
+				Data:       map[string]string{target.ConfigKey: data},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		if data == "" {
+			delete(cm.Data, target.ConfigKey)
+		} else {
+			cm.Data[target.ConfigKey] = data
+		}
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write configmap %s/%s: %v", target.Namespace, target.ConfigMapName, err)
+	}
+	return nil
+}
+
+// restartScheduler 通过更新Pod模板注解滚动重启调度器，并等待重启完成后执行健康检查
+// profiles非空时额外检查这些调度器的待调度Pod数量不超过MaxPendingPods
+func (spt *SchedulerPerformanceTuner) restartScheduler(ctx context.Context, target SchedulerConfigTarget, profiles []schedulerconfigv1.KubeSchedulerProfile, timeout time.Duration) error {
+	deployments := spt.client.AppsV1().Deployments(target.Namespace)
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`,
+		time.Now().Format(time.RFC3339))
+	deployment, err := deployments.Patch(ctx, target.DeploymentName, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment %s/%s: %v", target.Namespace, target.DeploymentName, err)
+	}
+	generation := deployment.Generation
+
+	klog.Infof("Restarting scheduler deployment %s/%s", target.Namespace, target.DeploymentName)
+	var lastState string
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := deployments.Get(ctx, target.DeploymentName, metav1.GetOptions{})
+		if err != nil {
+			// 临时错误不终止等待，超时后一并报告
+			lastState = err.Error()
+			return false, nil
+		}
+		replicas := ptr.Deref(deployment.Spec.Replicas, 1)
+		status := deployment.Status
+		lastState = fmt.Sprintf("observedGeneration=%d/%d updated=%d available=%d total=%d desired=%d",
+			status.ObservedGeneration, generation, status.UpdatedReplicas, status.AvailableReplicas, status.Replicas, replicas)
+		return status.ObservedGeneration >= generation &&
+			status.UpdatedReplicas == replicas &&
+			status.AvailableReplicas == replicas &&
+			status.Replicas == replicas, nil
+	})
+	if err != nil {
+		return fmt.Errorf("deployment %s/%s did not become ready within %v (%s): %v", target.Namespace, target.DeploymentName, timeout, lastState, err)
+	}
+
+	return spt.checkPendingPods(ctx, profiles)
+}
+
+// checkPendingPods 检查由这些配置档负责的待调度Pod数量不超过MaxPendingPods
+func (spt *SchedulerPerformanceTuner) checkPendingPods(ctx context.Context, profiles []schedulerconfigv1.KubeSchedulerProfile) error {
+	if len(profiles) == 0 || spt.config.MaxPendingPods <= 0 {
+		return nil
+	}
+
+	schedulerNames := make(map[string]bool)
+	for _, profile := range profiles {
+		schedulerNames[ptr.Deref(profile.SchedulerName, v1.DefaultSchedulerName)] = true
+	}
+
+	pods, err := spt.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list pending pods: %v", err)
+	}
+
+	pending := 0
+	for _, pod := range pods.Items {
+		if schedulerNames[pod.Spec.SchedulerName] {
+			pending++
+		}
+	}
+	if pending > spt.config.MaxPendingPods {
+		return fmt.Errorf("%d pods pending for reconfigured schedulers, exceeding MaxPendingPods=%d", pending, spt.config.MaxPendingPods)
+	}
+	return nil
+}
@@ -3,27 +3,47 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/kubernetes-fundamentals/internal/utils"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 )
 
 // SchedulerRecoveryManager 调度器恢复管理器
+// 恢复循环、恢复策略和事件升级共用同一个管理器时，恢复动作逐个执行
 type SchedulerRecoveryManager struct {
-	client kubernetes.Interface
-	config *RecoveryConfig
+	client   kubernetes.Interface
+	config   *RecoveryConfig
+	recorder record.EventRecorder // 卡住Pod的事件记录器，为空时只写日志
+
+	actionMu             sync.Mutex // 串行执行恢复动作
+	lastSchedulerRestart time.Time  // 最近一次开始重启调度器Pod的时间，由actionMu保护
 }
 
+// schedulerRestartCooldown 两次重启调度器Pod的最小间隔，不同来源（恢复循环、恢复策略）在此期间不会重复重启
+const schedulerRestartCooldown = 5 * time.Minute
+
 // RecoveryConfig 恢复配置
 type RecoveryConfig struct {
 	HealthCheckInterval time.Duration      `json:"health_check_interval"`
 	RecoveryTimeout     time.Duration      `json:"recovery_timeout"`
 	MaxRetries          int                `json:"max_retries"`
 	Strategies          RecoveryStrategies `json:"strategies"`
+	// MaxCordonedNodePercent 同时由恢复管理器封锁的节点占集群节点的最大百分比，默认20；按比例向下取整为0时至少允许封锁1个节点
+	MaxCordonedNodePercent int `json:"max_cordoned_node_percent"`
+	// DryRun 只记录计划执行的恢复动作，不修改集群；卡住Pod的处置仍会诊断并生成报告
+	DryRun bool `json:"dry_run"`
 }
 
 // RecoveryStrategies 恢复策略
@@ -36,31 +56,39 @@ type RecoveryStrategies struct {
 
 // RecoveryAction 恢复动作
 type RecoveryAction struct {
-	Type      string        `json:"type"`
-	Target    string        `json:"target"`
-	Timestamp time.Time     `json:"timestamp"`
-	Status    string        `json:"status"`
-	Result    string        `json:"result"`
-	Duration  time.Duration `json:"duration"`
-	Attempts  int           `json:"attempts"`
+	Type       string                 `json:"type"`
+	Target     string                 `json:"target"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Timeout    time.Duration          `json:"timeout,omitempty"` // 执行和校验的超时，为0时使用RecoveryTimeout
+	Timestamp  time.Time              `json:"timestamp"`
+	Status     string                 `json:"status"`
+	Result     string                 `json:"result"`
+	Duration   time.Duration          `json:"duration"`
+	Attempts   int                    `json:"attempts"`
 }
 
 // 注意：HealthStatus 已在 health-checker.go 中定义
 
-// NewSchedulerRecoveryManager 创建新的恢复管理器
+// DefaultRecoveryConfig 返回默认的恢复配置
+func DefaultRecoveryConfig() *RecoveryConfig {
+	return &RecoveryConfig{
+		HealthCheckInterval: 30 * time.Second,
+		RecoveryTimeout:     5 * time.Minute,
+		MaxRetries:          3,
+		Strategies: RecoveryStrategies{
+			SchedulerRestart:    true,
+			NodeDraining:        true,
+			PodEviction:         true,
+			ResourceRebalancing: false,
+		},
+		MaxCordonedNodePercent: defaultMaxCordonedNodePercent,
+	}
+}
+
+// NewSchedulerRecoveryManager 创建新的恢复管理器，config为空时使用DefaultRecoveryConfig
 func NewSchedulerRecoveryManager(client kubernetes.Interface, config *RecoveryConfig) *SchedulerRecoveryManager {
 	if config == nil {
-		config = &RecoveryConfig{
-			HealthCheckInterval: 30 * time.Second,
-			RecoveryTimeout:     5 * time.Minute,
-			MaxRetries:          3,
-			Strategies: RecoveryStrategies{
-				SchedulerRestart:    true,
-				NodeDraining:        true,
-				PodEviction:         true,
-				ResourceRebalancing: false,
-			},
-		}
+		config = DefaultRecoveryConfig()
 	}
 
 	return &SchedulerRecoveryManager{
@@ -71,10 +99,15 @@ func NewSchedulerRecoveryManager(client kubernetes.Interface, config *RecoveryCo
 
 // StartRecoveryLoop 启动恢复循环
 func (srm *SchedulerRecoveryManager) StartRecoveryLoop(ctx context.Context) {
-	ticker := time.NewTicker(srm.config.HealthCheckInterval)
+	interval := srm.config.HealthCheckInterval
+	if interval <= 0 {
+		// time.NewTicker对非正数间隔会panic
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	klog.Infof("Starting scheduler recovery loop with interval: %v", srm.config.HealthCheckInterval)
+	klog.Infof("Starting scheduler recovery loop with interval: %v", interval)
 
 	for {
 		select {
@@ -82,9 +115,9 @@ func (srm *SchedulerRecoveryManager) StartRecoveryLoop(ctx context.Context) {
 			klog.Info("Recovery loop stopped")
 			return
 		case <-ticker.C:
-			if err := srm.performHealthCheck(ctx); err != nil {
-				klog.Errorf("Health check failed: %v", err)
-				if recoveryErr := srm.initiateRecovery(ctx, err); recoveryErr != nil {
+			if findings := srm.performHealthCheck(ctx); len(findings) > 0 {
+				klog.Errorf("Health check failed: %s", formatFindings(findings))
+				if err := srm.initiateRecovery(ctx, findings); err != nil {
 					klog.Errorf("Recovery failed: %v", err)
 				}
 			}
@@ -92,28 +125,25 @@ func (srm *SchedulerRecoveryManager) StartRecoveryLoop(ctx context.Context) {
 	}
 }
 
-// performHealthCheck 执行健康检查
-func (srm *SchedulerRecoveryManager) performHealthCheck(ctx context.Context) error {
-	// 检查调度器健康状态
-	if _, err := srm.checkSchedulerHealth(ctx); err != nil {
-		return fmt.Errorf("scheduler health check failed: %v", err)
-	}
-
-	// 检查节点健康状态
-	if _, err := srm.checkNodeHealth(ctx); err != nil {
-		return fmt.Errorf("node health check failed: %v", err)
+// performHealthCheck 执行全部健康检查，返回发现的问题，全部健康时为空
+func (srm *SchedulerRecoveryManager) performHealthCheck(ctx context.Context) []HealthFinding {
+	checks := []func(context.Context) (*HealthStatus, *HealthFinding){
+		srm.checkSchedulerHealth,
+		srm.checkNodeHealth,
+		srm.checkQueueHealth,
 	}
 
-	// 检查调度队列健康状态
-	if _, err := srm.checkQueueHealth(ctx); err != nil {
-		return fmt.Errorf("queue health check failed: %v", err)
+	var findings []HealthFinding
+	for _, check := range checks {
+		if _, finding := check(ctx); finding != nil {
+			findings = append(findings, *finding)
+		}
 	}
-
-	return nil
+	return findings
 }
 
 // checkSchedulerHealth 检查调度器健康状态
-func (srm *SchedulerRecoveryManager) checkSchedulerHealth(ctx context.Context) (*HealthStatus, error) {
+func (srm *SchedulerRecoveryManager) checkSchedulerHealth(ctx context.Context) (*HealthStatus, *HealthFinding) {
 	health := &HealthStatus{
 		Name:      "scheduler",
 		Timestamp: time.Now(),
@@ -126,35 +156,52 @@ func (srm *SchedulerRecoveryManager) checkSchedulerHealth(ctx context.Context) (
 		LabelSelector: "component=kube-scheduler",
 	})
 	if err != nil {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Failed to list scheduler pods: %v", err)
-		return health, err
+		finding := &HealthFinding{
+			Component: ComponentScheduler,
+			Condition: ConditionCheckFailed,
+			Severity:  AlertWarning,
+			Message:   fmt.Sprintf("Failed to list scheduler pods: %v", err),
+			Evidence:  map[string]string{"error": err.Error()},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	if len(pods.Items) == 0 {
-		health.Healthy = false
-		health.Message = "No scheduler pods found"
-		return health, fmt.Errorf("no scheduler pods found")
+		finding := &HealthFinding{
+			Component: ComponentScheduler,
+			Condition: ConditionSchedulerMissing,
+			Severity:  AlertCritical,
+			Message:   "No scheduler pods found",
+			Evidence:  map[string]string{"namespace": "kube-system", "labelSelector": "component=kube-scheduler"},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	runningPods := 0
+	phases := make([]string, 0, len(pods.Items))
 	for _, pod := range pods.Items {
 		if pod.Status.Phase == v1.PodRunning {
 			runningPods++
 		}
+		phases = append(phases, fmt.Sprintf("%s=%s", pod.Name, pod.Status.Phase))
 	}
 
 	if runningPods == 0 {
-		health.Healthy = false
-		health.Message = "No running scheduler pods"
-		return health, fmt.Errorf("no running scheduler pods")
+		finding := &HealthFinding{
+			Component: ComponentScheduler,
+			Condition: ConditionSchedulerNotRunning,
+			Severity:  AlertCritical,
+			Message:   "No running scheduler pods",
+			Evidence:  map[string]string{"pods": strings.Join(phases, ",")},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	return health, nil
 }
 
 // checkNodeHealth 检查节点健康状态
-func (srm *SchedulerRecoveryManager) checkNodeHealth(ctx context.Context) (*HealthStatus, error) {
+func (srm *SchedulerRecoveryManager) checkNodeHealth(ctx context.Context) (*HealthStatus, *HealthFinding) {
 	health := &HealthStatus{
 		Name:      "nodes",
 		Timestamp: time.Now(),
@@ -164,39 +211,61 @@ func (srm *SchedulerRecoveryManager) checkNodeHealth(ctx context.Context) (*Heal
 
 	nodes, err := srm.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Failed to list nodes: %v", err)
-		return health, err
+		finding := &HealthFinding{
+			Component: ComponentNodes,
+			Condition: ConditionCheckFailed,
+			Severity:  AlertWarning,
+			Message:   fmt.Sprintf("Failed to list nodes: %v", err),
+			Evidence:  map[string]string{"error": err.Error()},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	if len(nodes.Items) == 0 {
-		health.Healthy = false
-		health.Message = "No nodes found"
-		return health, fmt.Errorf("no nodes found")
+		finding := &HealthFinding{
+			Component: ComponentNodes,
+			Condition: ConditionNoNodes,
+			Severity:  AlertCritical,
+			Message:   "No nodes found",
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	readyNodes := 0
+	var notReady []string
 	for _, node := range nodes.Items {
-		for _, condition := range node.Status.Conditions {
-			if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
-				readyNodes++
-				break
-			}
+		if isNodeReady(&node) {
+			readyNodes++
+		} else {
+			notReady = append(notReady, node.Name)
 		}
 	}
 
 	// 如果少于50%的节点就绪，认为不健康
 	if float64(readyNodes)/float64(len(nodes.Items)) < 0.5 {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Only %d/%d nodes are ready", readyNodes, len(nodes.Items))
-		return health, fmt.Errorf("insufficient ready nodes")
+		sort.Strings(notReady)
+		if len(notReady) > 10 {
+			notReady = append(notReady[:10], "...")
+		}
+		finding := &HealthFinding{
+			Component: ComponentNodes,
+			Condition: ConditionNodesNotReady,
+			Severity:  AlertCritical,
+			Message:   fmt.Sprintf("Only %d/%d nodes are ready", readyNodes, len(nodes.Items)),
+			Evidence: map[string]string{
+				"ready":    strconv.Itoa(readyNodes),
+				"total":    strconv.Itoa(len(nodes.Items)),
+				"notReady": strings.Join(notReady, ","),
+			},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	return health, nil
 }
 
 // checkQueueHealth 检查队列健康状态
-func (srm *SchedulerRecoveryManager) checkQueueHealth(ctx context.Context) (*HealthStatus, error) {
+func (srm *SchedulerRecoveryManager) checkQueueHealth(ctx context.Context) (*HealthStatus, *HealthFinding) {
 	health := &HealthStatus{
 		Name:      "queue",
 		Timestamp: time.Now(),
@@ -209,16 +278,26 @@ func (srm *SchedulerRecoveryManager) checkQueueHealth(ctx context.Context) (*Hea
 		FieldSelector: "status.phase=Pending",
 	})
 	if err != nil {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Failed to list pending pods: %v", err)
-		return health, err
+		finding := &HealthFinding{
+			Component: ComponentQueue,
+			Condition: ConditionCheckFailed,
+			Severity:  AlertWarning,
+			Message:   fmt.Sprintf("Failed to list pending pods: %v", err),
+			Evidence:  map[string]string{"error": err.Error()},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	// 如果Pending Pod数量过多，认为队列不健康
 	if len(pendingPods.Items) > 100 {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Too many pending pods: %d", len(pendingPods.Items))
-		return health, fmt.Errorf("queue overloaded")
+		finding := &HealthFinding{
+			Component: ComponentQueue,
+			Condition: ConditionQueueOverloaded,
+			Severity:  AlertWarning,
+			Message:   fmt.Sprintf("Too many pending pods: %d", len(pendingPods.Items)),
+			Evidence:  map[string]string{"pending": strconv.Itoa(len(pendingPods.Items)), "threshold": "100"},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	// 检查长时间Pending的Pod
@@ -230,232 +309,435 @@ func (srm *SchedulerRecoveryManager) checkQueueHealth(ctx context.Context) (*Hea
 	}
 
 	if longPendingPods > 10 {
-		health.Healthy = false
-		health.Message = fmt.Sprintf("Too many long-pending pods: %d", longPendingPods)
-		return health, fmt.Errorf("scheduling stalled")
+		finding := &HealthFinding{
+			Component: ComponentQueue,
+			Condition: ConditionSchedulingStalled,
+			Severity:  AlertWarning,
+			Message:   fmt.Sprintf("Too many long-pending pods: %d", longPendingPods),
+			Evidence: map[string]string{
+				"longPending": strconv.Itoa(longPendingPods),
+				"pendingFor":  (10 * time.Minute).String(),
+				"threshold":   "10",
+			},
+		}
+		return finding.markUnhealthy(health)
 	}
 
 	return health, nil
 }
 
-// initiateRecovery 启动恢复流程
-func (srm *SchedulerRecoveryManager) initiateRecovery(ctx context.Context, healthErr error) error {
-	klog.Infof("Initiating recovery due to health check failure: %v", healthErr)
+// initiateRecovery 按恢复规则表为健康发现执行恢复动作，某个动作后健康检查通过即停止
+func (srm *SchedulerRecoveryManager) initiateRecovery(ctx context.Context, findings []HealthFinding) error {
+	klog.Infof("Initiating recovery for health findings: %s", formatFindings(findings))
 
 	// 确定恢复策略
-	actions := srm.determineRecoveryActions(healthErr)
+	actions := planRecoveryActions(findings, srm.config.Strategies)
+	if len(actions) == 0 {
+		return fmt.Errorf("no enabled recovery action for: %s", formatFindings(findings))
+	}
 
-	// 执行恢复动作
-	for _, action := range actions {
+	// 执行恢复动作，每个动作在返回前已校验后置条件
+	for i := range actions {
+		action := &actions[i]
 		if err := srm.executeRecoveryAction(ctx, action); err != nil {
-			klog.Errorf("Recovery action %s failed: %v", action.Type, err)
+			klog.Errorf("Recovery action %s %s: %v", action.Type, action.Status, err)
+			continue
+		}
+		if srm.config.DryRun {
+			// 集群未被修改，重新检查没有意义，继续记录后续动作
 			continue
 		}
-
-		// 等待恢复生效
-		time.Sleep(30 * time.Second)
 
 		// 重新检查健康状态
-		if err := srm.performHealthCheck(ctx); err == nil {
+		if remaining := srm.performHealthCheck(ctx); len(remaining) == 0 {
 			klog.Infof("Recovery successful after action: %s", action.Type)
 			return nil
 		}
 	}
 
+	if srm.config.DryRun {
+		klog.Infof("Dry run: planned %d recovery actions", len(actions))
+		return nil
+	}
 	return fmt.Errorf("all recovery actions failed")
 }
 
-// determineRecoveryActions 确定恢复动作
-func (srm *SchedulerRecoveryManager) determineRecoveryActions(healthErr error) []RecoveryAction {
-	var actions []RecoveryAction
-
-	errorMsg := healthErr.Error()
-
-	// 基于错误类型确定恢复策略
-	if strings.Contains(errorMsg, "scheduler") {
-		if srm.config.Strategies.SchedulerRestart {
-			actions = append(actions, RecoveryAction{
-				Type:      "restart_scheduler",
-				Target:    "kube-scheduler",
-				Timestamp: time.Now(),
-				Status:    "pending",
-			})
-		}
-	}
-
-	if strings.Contains(errorMsg, "nodes") || strings.Contains(errorMsg, "ready") {
-		if srm.config.Strategies.NodeDraining {
-			actions = append(actions, RecoveryAction{
-				Type:      "drain_unhealthy_nodes",
-				Target:    "cluster",
-				Timestamp: time.Now(),
-				Status:    "pending",
-			})
-		}
-	}
-
-	if strings.Contains(errorMsg, "queue") || strings.Contains(errorMsg, "pending") {
-		if srm.config.Strategies.PodEviction {
-			actions = append(actions, RecoveryAction{
-				Type:      "evict_stuck_pods",
-				Target:    "cluster",
-				Timestamp: time.Now(),
-				Status:    "pending",
-			})
-		}
+// executeRecoveryAction 执行恢复动作并校验后置条件，校验失败时执行补偿动作
+func (srm *SchedulerRecoveryManager) executeRecoveryAction(ctx context.Context, action *RecoveryAction) error {
+	srm.actionMu.Lock()
+	defer srm.actionMu.Unlock()
 
-		if srm.config.Strategies.ResourceRebalancing {
-			actions = append(actions, RecoveryAction{
-				Type:      "rebalance_resources",
-				Target:    "cluster",
-				Timestamp: time.Now(),
-				Status:    "pending",
-			})
-		}
-	}
-
-	return actions
-}
-
-// executeRecoveryAction 执行恢复动作
-func (srm *SchedulerRecoveryManager) executeRecoveryAction(ctx context.Context, action RecoveryAction) error {
 	start := time.Now()
-	action.Status = "executing"
+	action.Status = RecoveryActionExecuting
 	action.Attempts++
 
 	defer func() {
 		action.Duration = time.Since(start)
 	}()
 
-	switch action.Type {
-	case "restart_scheduler":
-		return srm.restartScheduler(ctx, action)
-	case "drain_unhealthy_nodes":
-		return srm.drainUnhealthyNodes(ctx, action)
-	case "evict_stuck_pods":
-		return srm.evictStuckPods(ctx, action)
-	case "rebalance_resources":
-		return srm.rebalanceResources(ctx, action)
-	default:
-		action.Status = "failed"
+	handler, exists := lookupRecoveryAction(action.Type)
+	if !exists {
+		action.Status = RecoveryActionFailed
 		action.Result = fmt.Sprintf("Unknown recovery action: %s", action.Type)
 		return fmt.Errorf("unknown recovery action: %s", action.Type)
 	}
+	if err := validateActionParameters(action.Type, action.Parameters); err != nil {
+		action.Status = RecoveryActionFailed
+		action.Result = err.Error()
+		return err
+	}
+	return srm.runRecoveryAction(ctx, action, handler)
 }
 
-// restartScheduler 重启调度器
-func (srm *SchedulerRecoveryManager) restartScheduler(ctx context.Context, action RecoveryAction) error {
-	klog.Infof("Restarting scheduler pods")
+// restartScheduler 逐个重启调度器Pod，每次等待替代Pod就绪后再重启下一个，距上次重启不足schedulerRestartCooldown时跳过
+// 后置条件：就绪Pod数量恢复且领导者续约了租约；不满足时将租约交给就绪副本
+func (srm *SchedulerRecoveryManager) restartScheduler(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	if since := time.Since(srm.lastSchedulerRestart); since < schedulerRestartCooldown {
+		action.Result = fmt.Sprintf("Scheduler pods were restarted %v ago, skipping restart", since.Round(time.Second))
+		klog.Info(action.Result)
+		return nil, nil
+	}
+	klog.Infof("Restarting scheduler pods one at a time")
+	start := time.Now()
 
-	// 获取调度器Pod
-	pods, err := srm.client.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
-		LabelSelector: "component=kube-scheduler",
-	})
+	pods, err := srm.listSchedulerPods(ctx)
 	if err != nil {
-		action.Status = "failed"
-		action.Result = fmt.Sprintf("Failed to list scheduler pods: %v", err)
-		return err
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no scheduler pods found")
 	}
 
-	// 删除调度器Pod以触发重启
-	for _, pod := range pods.Items {
-		err := srm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-		if err != nil {
-			klog.Errorf("Failed to delete scheduler pod %s: %v", pod.Name, err)
-			continue
+	// 至少需要一个就绪Pod，全部未就绪时替代Pod就绪一个即可继续
+	want := 0
+	for i := range pods {
+		if utils.IsPodServing(&pods[i]) {
+			want++
 		}
-		klog.Infof("Deleted scheduler pod: %s", pod.Name)
+	}
+	if want == 0 {
+		want = 1
 	}
 
-	// 等待新Pod启动
-	time.Sleep(60 * time.Second)
+	srm.lastSchedulerRestart = start
+	plan := &RecoveryPlan{Rollback: srm.rollbackSchedulerRestart}
+	restarted := make(map[types.UID]bool)
+	for _, pod := range pods {
+		uid := pod.UID
+		err := srm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid},
+		})
+		if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+			return plan, fmt.Errorf("failed to delete scheduler pod %s: %v", pod.Name, err)
+		}
+		restarted[uid] = true
+		klog.Infof("Deleted scheduler pod %s, waiting for its replacement", pod.Name)
+
+		// 替代Pod未就绪时停止重启剩余Pod
+		var serving int
+		err = wait.PollUntilContextCancel(ctx, 2*time.Second, false, func(ctx context.Context) (bool, error) {
+			serving, err = srm.servingSchedulerPods(ctx, restarted)
+			return err == nil && serving >= want, nil
+		})
+		if err != nil {
+			return plan, fmt.Errorf("replacement for scheduler pod %s not ready (%d/%d ready), stopped rolling restart: %v",
+				pod.Name, serving, want, err)
+		}
+		action.Result = fmt.Sprintf("Restarted %d/%d scheduler pods", len(restarted), len(pods))
+	}
 
-	action.Status = "completed"
-	action.Result = fmt.Sprintf("Restarted %d scheduler pods", len(pods.Items))
-	return nil
+	plan.Verify = srm.verifySchedulerRestart(want, restarted, start)
+	return plan, nil
 }
 
 // drainUnhealthyNodes 排空不健康节点
-func (srm *SchedulerRecoveryManager) drainUnhealthyNodes(ctx context.Context, action RecoveryAction) error {
+// 逐个封锁未就绪节点并驱逐其上的Pod，自动封锁的节点总数不超过MaxCordonedNodePercent；
+// 后置条件：待调度Pod数量不增加；补偿：恢复本次封锁的节点
+func (srm *SchedulerRecoveryManager) drainUnhealthyNodes(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
 	klog.Infof("Draining unhealthy nodes")
 
+	before, err := srm.countUnscheduledPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	nodes, err := srm.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		action.Status = "failed"
-		action.Result = fmt.Sprintf("Failed to list nodes: %v", err)
-		return err
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
 	}
+	sortNodesByName(nodes.Items)
 
-	unhealthyNodes := []string{}
+	var candidates []v1.Node
 	for _, node := range nodes.Items {
-		isReady := false
-		for _, condition := range node.Status.Conditions {
-			if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
-				isReady = true
-				break
-			}
+		if node.Spec.Unschedulable || isNodeReady(&node) {
+			continue
 		}
+		candidates = append(candidates, node)
+	}
+	if len(candidates) == 0 {
+		action.Result = "No unhealthy nodes to drain"
+		return nil, nil
+	}
 
-		if !isReady {
-			unhealthyNodes = append(unhealthyNodes, node.Name)
+	budget, limit := cordonBudget(nodes.Items, srm.config.MaxCordonedNodePercent)
+	if limit == 0 {
+		return nil, fmt.Errorf("%d unhealthy nodes not drained: cluster of %d nodes is too small to cordon any node", len(candidates), len(nodes.Items))
+	}
+	if budget <= 0 {
+		return nil, fmt.Errorf("%d unhealthy nodes not drained: limit of %d nodes cordoned by recovery reached", len(candidates), limit)
+	}
+	if len(candidates) > budget {
+		klog.Warningf("%d unhealthy nodes found, draining only %d within the cordon limit", len(candidates), budget)
+		candidates = candidates[:budget]
+	}
 
-			// 标记节点为不可调度
-			node.Spec.Unschedulable = true
-			_, err := srm.client.CoreV1().Nodes().Update(ctx, &node, metav1.UpdateOptions{})
-			if err != nil {
-				klog.Errorf("Failed to mark node %s as unschedulable: %v", node.Name, err)
-			} else {
-				klog.Infof("Marked node %s as unschedulable", node.Name)
-			}
+	drainer := &nodeDrainer{client: srm.client}
+	var cordoned []string
+	var results []*NodeDrainResult
+	plan := func() *RecoveryPlan {
+		return &RecoveryPlan{
+			Verify:   srm.verifyQueueNotGrowing(before),
+			Rollback: srm.uncordonNodes(cordoned),
 		}
 	}
 
-	action.Status = "completed"
-	action.Result = fmt.Sprintf("Drained %d unhealthy nodes: %v", len(unhealthyNodes), unhealthyNodes)
-	return nil
+	for i := range candidates {
+		node := &candidates[i]
+		if err := drainer.cordon(ctx, node.Name); err != nil {
+			return plan(), err
+		}
+		cordoned = append(cordoned, node.Name)
+
+		result, err := drainer.drain(ctx, node)
+		results = append(results, result)
+		if err != nil {
+			action.Result = formatDrainResults(results)
+			return plan(), fmt.Errorf("failed to drain node %s: %v", node.Name, err)
+		}
+	}
+
+	action.Result = formatDrainResults(results)
+	return plan(), nil
 }
 
-// evictStuckPods 驱逐卡住的Pod
-func (srm *SchedulerRecoveryManager) evictStuckPods(ctx context.Context, action RecoveryAction) error {
-	klog.Infof("Evicting stuck pods")
+// formatDrainResults 汇总排空结果
+func formatDrainResults(results []*NodeDrainResult) string {
+	parts := make([]string, 0, len(results))
+	for _, result := range results {
+		parts = append(parts, fmt.Sprintf("%s: evicted %d pods, skipped %d", result.Node, len(result.Evicted), len(result.Skipped)))
+	}
+	return fmt.Sprintf("Drained %d unhealthy nodes (%s)", len(results), strings.Join(parts, "; "))
+}
 
-	// 获取长时间Pending的Pod
-	pendingPods, err := srm.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
-		FieldSelector: "status.phase=Pending",
-	})
+// evictStuckPods 诊断卡住的Pod并按原因处置
+// 只删除由控制器管理且原因已消失的Pod；后置条件：待调度Pod数量下降；删除的Pod无法恢复，没有补偿动作
+func (srm *SchedulerRecoveryManager) evictStuckPods(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	klog.Infof("Diagnosing stuck pods")
+
+	report, err := srm.DiagnoseStuckPods(ctx)
 	if err != nil {
-		action.Status = "failed"
-		action.Result = fmt.Sprintf("Failed to list pending pods: %v", err)
-		return err
+		return nil, err
 	}
+	srm.remediateStuckPods(ctx, report)
+	action.Result = report.Summary()
 
-	evictedPods := []string{}
-	for _, pod := range pendingPods.Items {
-		// 只驱逐超过10分钟的Pending Pod
-		if time.Since(pod.CreationTimestamp.Time) > 10*time.Minute {
-			err := srm.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-			if err != nil {
-				klog.Errorf("Failed to evict pod %s/%s: %v", pod.Namespace, pod.Name, err)
-				continue
-			}
-			evictedPods = append(evictedPods, fmt.Sprintf("%s/%s", pod.Namespace, pod.Name))
-			klog.Infof("Evicted stuck pod: %s/%s", pod.Namespace, pod.Name)
+	for _, pod := range report.Pods {
+		if pod.Action == StuckPodDelete && pod.Executed {
+			return &RecoveryPlan{Verify: srm.verifyQueueShrinking(report.Pending)}, nil
 		}
 	}
-
-	action.Status = "completed"
-	action.Result = fmt.Sprintf("Evicted %d stuck pods", len(evictedPods))
-	return nil
+	return nil, nil
 }
 
 // rebalanceResources 重平衡资源
-func (srm *SchedulerRecoveryManager) rebalanceResources(ctx context.Context, action RecoveryAction) error {
+func (srm *SchedulerRecoveryManager) rebalanceResources(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
 	klog.Infof("Rebalancing cluster resources")
 
 	// 这里可以实现资源重平衡逻辑
 	// 例如：识别资源使用不均衡的节点，建议Pod迁移等
 
-	action.Status = "completed"
 	action.Result = "Resource rebalancing analysis completed"
-	return nil
+	return nil, nil
+}
+
+// restartPods 删除匹配labelSelector的Pod，由控制器重建
+// 选中调度器Pod时按restart_scheduler逐个重启，避免同时删除所有副本；
+// 其他Pod一次删除，后置条件：就绪的替代Pod数量恢复到删除前
+func (srm *SchedulerRecoveryManager) restartPods(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	params := action.Parameters
+	namespace, ok := params["namespace"].(string)
+	if !ok {
+		namespace = "kube-system"
+	}
+
+	labelSelector, ok := params["labelSelector"].(string)
+	if !ok {
+		return nil, fmt.Errorf("labelSelector parameter is required")
+	}
+
+	pods, err := srm.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+	for i := range pods.Items {
+		if isSchedulerPod(&pods.Items[i]) {
+			klog.Infof("Pods matching %s include scheduler pods, restarting schedulers one at a time", labelSelector)
+			return srm.restartScheduler(ctx, action)
+		}
+	}
+	if len(pods.Items) == 0 {
+		action.Result = fmt.Sprintf("No pods matching %s", labelSelector)
+		return nil, nil
+	}
+
+	want := 0
+	for i := range pods.Items {
+		if utils.IsPodServing(&pods.Items[i]) {
+			want++
+		}
+	}
+
+	deleted := make(map[types.UID]bool)
+	for _, pod := range pods.Items {
+		uid := pod.UID
+		err := srm.client.CoreV1().Pods(namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &uid},
+		})
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("Failed to delete pod %s/%s: %v", namespace, pod.Name, err)
+		} else {
+			deleted[uid] = true
+			klog.Infof("Deleted pod %s/%s for restart", namespace, pod.Name)
+		}
+	}
+
+	action.Result = fmt.Sprintf("Deleted %d/%d pods matching %s", len(deleted), len(pods.Items), labelSelector)
+	if len(deleted) == 0 {
+		return nil, fmt.Errorf("failed to delete any pod matching %s", labelSelector)
+	}
+	return &RecoveryPlan{Verify: srm.verifyPodsReplaced(namespace, labelSelector, want, deleted)}, nil
+}
+
+// forceLeaderElection 强制 Leader 选举
+// 仅当租约已停止续约、持有者 Pod 不存在或未就绪且有其他就绪副本时清除持有者，由就绪副本接管；
+// 后置条件：有副本重新获取并续约了租约。没有可交接的租约时不修改集群，也没有后置条件
+func (srm *SchedulerRecoveryManager) forceLeaderElection(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	params := action.Parameters
+	opts := utils.LeaderLeaseOptions{}
+	opts.Namespace, _ = params["namespace"].(string)
+	opts.LeaseName, _ = params["resourceName"].(string)
+	opts.CandidateSelector, _ = params["labelSelector"].(string)
+
+	handedOver, message, err := utils.ForceLeaderHandover(ctx, srm.client, opts)
+	if err != nil {
+		return nil, err
+	}
+	action.Result = message
+	if !handedOver {
+		return nil, nil
+	}
+	return &RecoveryPlan{Verify: srm.verifyLeaderElected(opts)}, nil
+}
+
+// reschedulePods 重新调度 Pod
+// 为待调度Pod添加注解触发更新事件，使调度器将其重新放回活动队列；后置条件：待调度Pod数量下降。
+// 只处理优先级不低于priorityThreshold的Pod（未设置优先级按0计算），最多maxPods个
+func (srm *SchedulerRecoveryManager) reschedulePods(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	params := action.Parameters
+	maxPods, ok := params["maxPods"].(float64)
+	if !ok {
+		maxPods = 10
+	}
+	priorityThreshold, _ := params["priorityThreshold"].(float64)
+
+	// 获取待调度的 Pod，优先级过滤无法通过字段选择器完成，因此不限制列举数量
+	pods, err := srm.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending pods: %v", err)
+	}
+	before := len(pods.Items)
+
+	var selected []v1.Pod
+	for _, pod := range pods.Items {
+		if len(selected) >= int(maxPods) {
+			break
+		}
+		var priority int32
+		if pod.Spec.Priority != nil {
+			priority = *pod.Spec.Priority
+		}
+		if float64(priority) >= priorityThreshold {
+			selected = append(selected, pod)
+		}
+	}
+
+	// 以合并补丁添加重新调度注解，不会与其他控制器的更新冲突
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{"scheduler.alpha.kubernetes.io/force-reschedule":%q}}}`,
+		time.Now().Format(time.RFC3339))
+	marked := 0
+	for _, pod := range selected {
+		_, err := srm.client.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		if err != nil {
+			klog.Errorf("Failed to update pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		} else {
+			marked++
+			klog.Infof("Marked pod %s/%s for rescheduling", pod.Namespace, pod.Name)
+		}
+	}
+
+	action.Result = fmt.Sprintf("Marked %d pods for rescheduling", marked)
+	if marked == 0 {
+		if len(selected) > 0 {
+			return nil, fmt.Errorf("failed to mark any of %d pending pods for rescheduling", len(selected))
+		}
+		return nil, nil
+	}
+	return &RecoveryPlan{Verify: srm.verifyQueueShrinking(before)}, nil
+}
+
+// scaleScheduler 扩缩容调度器
+// 后置条件：Deployment的新副本数全部更新并就绪；补偿：恢复原副本数
+func (srm *SchedulerRecoveryManager) scaleScheduler(ctx context.Context, action *RecoveryAction) (*RecoveryPlan, error) {
+	params := action.Parameters
+	replicas, ok := params["replicas"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("replicas parameter is required")
+	}
+
+	namespace, ok := params["namespace"].(string)
+	if !ok {
+		namespace = "kube-system"
+	}
+
+	deploymentName, ok := params["deploymentName"].(string)
+	if !ok {
+		deploymentName = "kube-scheduler"
+	}
+
+	deployment, err := srm.client.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %v", err)
+	}
+
+	var previous *int32
+	if deployment.Spec.Replicas != nil {
+		previous = int32Ptr(*deployment.Spec.Replicas)
+	}
+	deployment.Spec.Replicas = int32Ptr(int32(replicas))
+
+	_, err = srm.client.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update deployment: %v", err)
+	}
+
+	klog.Infof("Scaled scheduler deployment %s/%s to %d replicas", namespace, deploymentName, int32(replicas))
+	action.Result = fmt.Sprintf("Scaled %s/%s to %d replicas", namespace, deploymentName, int32(replicas))
+	return &RecoveryPlan{
+		Verify:   srm.verifyDeploymentScaled(namespace, deploymentName, int32(replicas)),
+		Rollback: srm.restoreDeploymentReplicas(namespace, deploymentName, previous),
+	}, nil
 }
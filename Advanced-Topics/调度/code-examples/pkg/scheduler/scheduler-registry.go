@@ -0,0 +1,146 @@
+// scheduler-registry.go
+// 调度器注册表 - 通过领导者租约或健康检查端点判断各调度器是否在线
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SchedulerRegistry 在线调度器注册表
+type SchedulerRegistry interface {
+	// IsAvailable 判断调度器是否在线，不在线时返回原因
+	IsAvailable(ctx context.Context, schedulerName string) (bool, string)
+}
+
+// SchedulerEndpoint 调度器的存活检测方式
+// 配置了HealthURL时使用健康检查端点，否则检查领导者租约
+type SchedulerEndpoint struct {
+	LeaseNamespace string // 领导者租约所在命名空间，默认kube-system
+	LeaseName      string // 领导者租约名称，默认与调度器同名
+	HealthURL      string // 健康检查端点，如http://gpu-scheduler.kube-system:10259/healthz
+}
+
+// LiveSchedulerRegistry 基于租约和健康端点的调度器注册表
+// 检测结果按CacheTTL缓存，避免每个Pod都访问API Server；不在线的结果只缓存failureCacheTTL，
+// 调用方ctx超时或取消导致的失败不缓存；default-scheduler始终视为在线
+type LiveSchedulerRegistry struct {
+	client          kubernetes.Interface
+	httpClient      *http.Client
+	endpoints       map[string]SchedulerEndpoint
+	cacheTTL        time.Duration
+	failureCacheTTL time.Duration // 不在线结果的缓存时间，调度器恢复后尽快重新选中
+	leaseGrace      time.Duration // 租约过期后的宽限时间，容忍续约抖动
+
+	mu    sync.Mutex
+	cache map[string]schedulerAvailability
+}
+
+type schedulerAvailability struct {
+	available bool
+	reason    string
+	checkedAt time.Time
+}
+
+// NewLiveSchedulerRegistry 创建调度器注册表，未在endpoints中配置的调度器使用默认租约位置
+func NewLiveSchedulerRegistry(client kubernetes.Interface, endpoints map[string]SchedulerEndpoint) *LiveSchedulerRegistry {
+	if endpoints == nil {
+		endpoints = make(map[string]SchedulerEndpoint)
+	}
+	return &LiveSchedulerRegistry{
+		client:          client,
+		httpClient:      &http.Client{Timeout: 5 * time.Second},
+		endpoints:       endpoints,
+		cacheTTL:        30 * time.Second,
+		failureCacheTTL: 5 * time.Second,
+		leaseGrace:      5 * time.Second,
+		cache:           make(map[string]schedulerAvailability),
+	}
+}
+
+// IsAvailable 实现SchedulerRegistry
+func (r *LiveSchedulerRegistry) IsAvailable(ctx context.Context, schedulerName string) (bool, string) {
+	if schedulerName == "" || schedulerName == v1.DefaultSchedulerName {
+		return true, ""
+	}
+
+	r.mu.Lock()
+	cached, exists := r.cache[schedulerName]
+	r.mu.Unlock()
+	ttl := r.cacheTTL
+	if !cached.available {
+		ttl = r.failureCacheTTL
+	}
+	if exists && time.Since(cached.checkedAt) < ttl {
+		return cached.available, cached.reason
+	}
+
+	available, reason := r.check(ctx, schedulerName)
+	if !available && ctx.Err() != nil {
+		// 失败来自调用方的超时或取消，不代表调度器不在线，不缓存
+		return available, reason
+	}
+
+	r.mu.Lock()
+	r.cache[schedulerName] = schedulerAvailability{available: available, reason: reason, checkedAt: time.Now()}
+	r.mu.Unlock()
+
+	return available, reason
+}
+
+func (r *LiveSchedulerRegistry) check(ctx context.Context, schedulerName string) (bool, string) {
+	endpoint := r.endpoints[schedulerName]
+	if endpoint.HealthURL != "" {
+		return r.checkHealthURL(ctx, endpoint.HealthURL)
+	}
+
+	namespace := endpoint.LeaseNamespace
+	if namespace == "" {
+		namespace = metav1.NamespaceSystem
+	}
+	name := endpoint.LeaseName
+	if name == "" {
+		name = schedulerName
+	}
+	return r.checkLease(ctx, namespace, name)
+}
+
+// checkLease 租约存在持有者且renewTime+leaseDuration未过期时视为在线
+func (r *LiveSchedulerRegistry) checkLease(ctx context.Context, namespace, name string) (bool, string) {
+	lease, err := r.client.CoordinationV1().Leases(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Sprintf("leader lease %s/%s not readable: %v", namespace, name, err)
+	}
+
+	if err := leaseFreshness(lease, r.leaseGrace, time.Now()); err != nil {
+		return false, err.Error()
+	}
+
+	return true, ""
+}
+
+// checkHealthURL 健康检查端点返回200时视为在线
+func (r *LiveSchedulerRegistry) checkHealthURL(ctx context.Context, url string) (bool, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Sprintf("invalid health endpoint %s: %v", url, err)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Sprintf("health endpoint %s unreachable: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Sprintf("health endpoint %s returned %d", url, resp.StatusCode)
+	}
+	return true, ""
+}
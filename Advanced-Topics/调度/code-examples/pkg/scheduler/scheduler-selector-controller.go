@@ -0,0 +1,202 @@
+// scheduler-selector-controller.go
+// 调度器选择控制器 - 在工作负载的Pod模板上设置调度器，使新建的Pod直接由目标调度器调度
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// SelectedSchedulerAnnotation 记录选择器写入Pod模板的调度器，用于区分用户设置和选择器设置
+	SelectedSchedulerAnnotation = "scheduler.kubernetes.io/selected-scheduler"
+	// SchedulerSelectionDisabledAnnotation 值为"true"时选择器不修改该工作负载或Pod
+	SchedulerSelectionDisabledAnnotation = "scheduler.kubernetes.io/selector-disabled"
+)
+
+// SchedulerSelectorController 调度器选择控制器
+// spec.schedulerName在Pod创建后不可修改，因此控制器修改Deployment、StatefulSet和CronJob的Pod模板，
+// 由工作负载控制器创建的新Pod直接使用选中的调度器；Job的模板同样不可修改，需配合准入Webhook使用
+type SchedulerSelectorController struct {
+	client   kubernetes.Interface
+	selector *SchedulerSelector
+	interval time.Duration
+}
+
+// NewSchedulerSelectorController 创建调度器选择控制器
+func NewSchedulerSelectorController(client kubernetes.Interface, selector *SchedulerSelector, interval time.Duration) *SchedulerSelectorController {
+	return &SchedulerSelectorController{
+		client:   client,
+		selector: selector,
+		interval: interval,
+	}
+}
+
+// Run 按间隔持续协调所有命名空间的工作负载，直到ctx取消
+func (c *SchedulerSelectorController) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.ReconcileOnce(ctx, metav1.NamespaceAll); err != nil {
+			klog.Errorf("Scheduler selector reconcile failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcileOnce 协调命名空间内的Deployment、StatefulSet和CronJob
+func (c *SchedulerSelectorController) ReconcileOnce(ctx context.Context, namespace string) error {
+	deployments, err := c.client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %v", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if err := c.reconcileDeployment(ctx, d); err != nil {
+			klog.Errorf("Failed to reconcile scheduler for deployment %s/%s: %v", d.Namespace, d.Name, err)
+		}
+	}
+
+	statefulSets, err := c.client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list statefulsets: %v", err)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		if err := c.reconcileStatefulSet(ctx, s); err != nil {
+			klog.Errorf("Failed to reconcile scheduler for statefulset %s/%s: %v", s.Namespace, s.Name, err)
+		}
+	}
+
+	cronJobs, err := c.client.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list cronjobs: %v", err)
+	}
+	for i := range cronJobs.Items {
+		cj := &cronJobs.Items[i]
+		if err := c.reconcileCronJob(ctx, cj); err != nil {
+			klog.Errorf("Failed to reconcile scheduler for cronjob %s/%s: %v", cj.Namespace, cj.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *SchedulerSelectorController) reconcileDeployment(ctx context.Context, d *appsv1.Deployment) error {
+	return c.reconcileTemplate(ctx, "Deployment", &d.ObjectMeta, &d.Spec.Template, "", func(patch []byte) error {
+		_, err := c.client.AppsV1().Deployments(d.Namespace).Patch(ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
+func (c *SchedulerSelectorController) reconcileStatefulSet(ctx context.Context, s *appsv1.StatefulSet) error {
+	return c.reconcileTemplate(ctx, "StatefulSet", &s.ObjectMeta, &s.Spec.Template, "", func(patch []byte) error {
+		_, err := c.client.AppsV1().StatefulSets(s.Namespace).Patch(ctx, s.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
+// reconcileCronJob CronJob创建的Pod归属于Job，模拟该所有者关系以命中批处理规则
+func (c *SchedulerSelectorController) reconcileCronJob(ctx context.Context, cj *batchv1.CronJob) error {
+	return c.reconcileTemplate(ctx, "CronJob", &cj.ObjectMeta, &cj.Spec.JobTemplate.Spec.Template, "Job", func(patch []byte) error {
+		_, err := c.client.BatchV1().CronJobs(cj.Namespace).Patch(ctx, cj.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+}
+
+// reconcileTemplate 为单个工作负载的Pod模板选择调度器，需要变更时通过patch写回
+func (c *SchedulerSelectorController) reconcileTemplate(ctx context.Context, kind string, meta *metav1.ObjectMeta,
+	template *v1.PodTemplateSpec, podOwnerKind string, patch func([]byte) error) error {
+	if meta.Annotations[SchedulerSelectionDisabledAnnotation] == "true" {
+		return nil
+	}
+
+	current := template.Spec.SchedulerName
+	if !c.selectorOwnsScheduler(current, meta.Annotations[SelectedSchedulerAnnotation]) {
+		// 用户显式指定了调度器
+		return nil
+	}
+
+	selected := c.selector.SelectTemplateScheduler(meta.Namespace, template, podOwnerKind)
+	effective := current
+	if effective == "" {
+		effective = v1.DefaultSchedulerName
+	}
+	// 非默认调度器还需要在模板上记录选择结果，准入Webhook据此判断Pod的调度器可以回退
+	if selected == effective && (selected == v1.DefaultSchedulerName || template.Annotations[SelectedSchedulerAnnotation] == selected) {
+		return nil
+	}
+
+	data, err := templateSchedulerPatch(kind, selected)
+	if err != nil {
+		return err
+	}
+	if err := patch(data); err != nil {
+		return err
+	}
+
+	klog.Infof("Set scheduler of %s %s/%s pod template to %s (was %q)", kind, meta.Namespace, meta.Name, selected, current)
+	return nil
+}
+
+// selectorOwnsScheduler 判断模板上的调度器是否可由选择器管理：
+// 未设置、默认调度器，或者仍是选择器上一次写入的值
+func (c *SchedulerSelectorController) selectorOwnsScheduler(current, recorded string) bool {
+	return current == "" || current == v1.DefaultSchedulerName || (recorded != "" && current == recorded)
+}
+
+// templateSchedulerPatch 生成修改Pod模板调度器的merge patch
+// 选择结果同时写入工作负载和Pod模板的注解，模板注解随Pod进入准入Webhook
+func templateSchedulerPatch(kind, scheduler string) ([]byte, error) {
+	templateSpec := map[string]interface{}{
+		"template": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					SelectedSchedulerAnnotation: scheduler,
+				},
+			},
+			"spec": map[string]interface{}{
+				"schedulerName": scheduler,
+			},
+		},
+	}
+
+	var spec map[string]interface{}
+	switch kind {
+	case "Deployment", "StatefulSet":
+		spec = templateSpec
+	case "CronJob":
+		spec = map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": templateSpec,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported workload kind for scheduler selection: %s", kind)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				SelectedSchedulerAnnotation: scheduler,
+			},
+		},
+		"spec": spec,
+	})
+}
@@ -0,0 +1,92 @@
+// scheduler-selector-webhook.go
+// 调度器选择准入Webhook - 在Pod创建时设置spec.schedulerName，覆盖Job和裸Pod等无法修改模板的场景
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// SchedulerSelectorWebhook Pod创建的变更准入处理器
+// 只处理CREATE请求；用户显式指定了非默认调度器或带有禁用注解的Pod保持不变。
+// 调度器由选择器控制器写入模板（Pod带有相同的选择注解）时视为选择器设置，首选调度器不在线时在此回退
+type SchedulerSelectorWebhook struct {
+	selector *SchedulerSelector
+}
+
+// NewSchedulerSelectorWebhook 创建调度器选择准入Webhook
+func NewSchedulerSelectorWebhook(selector *SchedulerSelector) *SchedulerSelectorWebhook {
+	return &SchedulerSelectorWebhook{selector: selector}
+}
+
+// ServeHTTP 处理AdmissionReview请求
+func (wh *SchedulerSelectorWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("invalid admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = wh.admit(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		klog.Errorf("Failed to write admission response: %v", err)
+	}
+}
+
+// admit 为新建Pod计算调度器，需要变更时返回JSONPatch；任何错误都放行请求，避免阻塞Pod创建
+func (wh *SchedulerSelectorWebhook) admit(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Operation != admissionv1.Create || req.Kind.Kind != "Pod" {
+		return response
+	}
+
+	var pod v1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		klog.Errorf("Failed to decode pod from admission request %s: %v", req.UID, err)
+		return response
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+	if pod.Annotations[SchedulerSelectionDisabledAnnotation] == "true" {
+		return response
+	}
+	current := pod.Spec.SchedulerName
+	if current != "" && current != v1.DefaultSchedulerName && current != pod.Annotations[SelectedSchedulerAnnotation] {
+		return response
+	}
+
+	candidate := pod.DeepCopy()
+	candidate.Spec.SchedulerName = ""
+	selected := wh.selector.SelectSchedulerContext(ctx, candidate)
+	if selected == current || (current == "" && selected == v1.DefaultSchedulerName) {
+		return response
+	}
+
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "add", "path": "/spec/schedulerName", "value": selected},
+	})
+	if err != nil {
+		klog.Errorf("Failed to build scheduler patch for pod %s/%s: %v", pod.Namespace, pod.GenerateName, err)
+		return response
+	}
+
+	patchType := admissionv1.PatchTypeJSONPatch
+	response.Patch = patch
+	response.PatchType = &patchType
+	klog.V(2).Infof("Admission set scheduler of pod %s/%s%s to %s", pod.Namespace, pod.Name, pod.GenerateName, selected)
+	return response
+}
@@ -4,133 +4,198 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
 )
 
+// availabilityCheckTimeout 单次选择中调度器在线检查的总时限，超时的调度器视为不在线
+const availabilityCheckTimeout = 2 * time.Second
+
 // SchedulerSelector 调度器选择器
-// 负责根据Pod的特征和标签自动选择最适合的调度器
+// 调度器由策略引擎的分类结果推导，与WorkloadClassifier使用同一套规则
 type SchedulerSelector struct {
-	client kubernetes.Interface // Kubernetes客户端
-	rules  []SchedulerRule      // 调度器选择规则列表
-}
+	client   kubernetes.Interface    // Kubernetes客户端
+	engine   *SchedulingPolicyEngine // 分类规则所在的策略引擎
+	registry SchedulerRegistry       // 在线调度器注册表，为空时不做可用性检查
+	recorder record.EventRecorder    // 回退事件记录器，为空时只写日志
 
-// SchedulerRule 调度器选择规则
-// 定义了工作负载识别条件和对应的调度器
-type SchedulerRule struct {
-	Name      string             // 规则名称，用于日志和调试
-	Condition func(*v1.Pod) bool // 条件判断函数，返回true表示匹配
-	Scheduler string             // 匹配时使用的调度器名称
-	Priority  int                // 规则优先级，数值越高优先级越高
+	controller *SchedulerSelectorController // UpdatePodScheduler复用的模板协调器
 }
 
 // NewSchedulerSelector 创建新的调度器选择器实例
-// 初始化预定义的调度器选择规则
+// 使用预定义规则的策略引擎
 func NewSchedulerSelector(client kubernetes.Interface) *SchedulerSelector {
-	return &SchedulerSelector{
+	return NewSchedulerSelectorWithEngine(client, NewSchedulingPolicyEngine())
+}
+
+// NewSchedulerSelectorWithEngine 使用指定策略引擎创建调度器选择器
+func NewSchedulerSelectorWithEngine(client kubernetes.Interface, engine *SchedulingPolicyEngine) *SchedulerSelector {
+	ss := &SchedulerSelector{
 		client: client,
-		rules: []SchedulerRule{
-			{
-				Name: "GPU Workload", // GPU工作负载识别规则
-				Condition: func(pod *v1.Pod) bool {
-					// 检查所有容器是否有GPU资源请求
-					for _, container := range pod.Spec.Containers {
-						if _, hasGPU := container.Resources.Requests["nvidia.com/gpu"]; hasGPU {
-							return true // 发现GPU请求，匹配GPU工作负载
-						}
-					}
-					return false
-				},
-				Scheduler: "gpu-scheduler", // 使用GPU专用调度器
-				Priority:  100,             // 最高优先级，GPU资源稀缺需优先处理
-			},
-			{
-				Name: "Batch Workload", // 批处理工作负载识别规则
-				Condition: func(pod *v1.Pod) bool {
-					// 通过标签识别批处理工作负载
-					if workloadType, exists := pod.Labels["workload.kubernetes.io/type"]; exists {
-						return workloadType == "batch"
-					}
-					return false
-				},
-				Scheduler: "batch-scheduler", // 使用批处理专用调度器
-				Priority:  80,                // 较高优先级
-			},
-			{
-				Name: "Realtime Workload", // 实时工作负载识别规则
-				Condition: func(pod *v1.Pod) bool {
-					// 通过优先级标签识别实时工作负载
-					if priority, exists := pod.Labels["workload.kubernetes.io/priority"]; exists {
-						return priority == "realtime"
-					}
-					return false
-				},
-				Scheduler: "realtime-scheduler", // 使用实时专用调度器
-				Priority:  90,                   // 高优先级，实时性要求高
-			},
-			{
-				Name: "High Memory Workload", // 高内存工作负载识别规则
-				Condition: func(pod *v1.Pod) bool {
-					// 检查是否有高内存需求（>8Gi）
-					for _, container := range pod.Spec.Containers {
-						if memory := container.Resources.Requests.Memory(); memory != nil {
-							if memory.Value() > 8*1024*1024*1024 { // 大于8Gi内存
-								return true
-							}
-						}
-					}
-					return false
-				},
-				Scheduler: "memory-optimized-scheduler", // 使用内存优化调度器
-				Priority:  70,                           // 中等优先级
-			},
-		},
+		engine: engine,
 	}
+	ss.controller = NewSchedulerSelectorController(client, ss, 0)
+	return ss
+}
+
+// WithAvailability 启用调度器可用性检查
+// 选中的调度器不在线时依次回退到下一个命中规则的调度器，最终回退到default-scheduler；回退只作用于单个Pod的选择
+func (ss *SchedulerSelector) WithAvailability(registry SchedulerRegistry, recorder record.EventRecorder) *SchedulerSelector {
+	ss.registry = registry
+	ss.recorder = recorder
+	return ss
+}
+
+// NewSchedulerEventRecorder 创建写入API Server的事件记录器
+func NewSchedulerEventRecorder(client kubernetes.Interface, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
 }
 
 // SelectScheduler 为Pod选择最适合的调度器
-// 根据规则优先级和匹配条件返回调度器名称
+// 先由策略引擎确定工作负载类型，再取该类型配置文件的首选调度器
 func (ss *SchedulerSelector) SelectScheduler(pod *v1.Pod) string {
+	return ss.SelectSchedulerContext(context.Background(), pod)
+}
+
+// SelectSchedulerContext 与SelectScheduler相同，在线检查受ctx约束
+// 准入Webhook传入请求的ctx，API Server放弃请求时检查随之取消
+func (ss *SchedulerSelector) SelectSchedulerContext(ctx context.Context, pod *v1.Pod) string {
 	// 如果Pod已经指定了调度器，直接返回（尊重用户选择）
 	if pod.Spec.SchedulerName != "" {
 		return pod.Spec.SchedulerName
 	}
+	return ss.selectFor(ctx, pod, podEventTarget(pod), true)
+}
 
-	var selectedScheduler string
-	var highestPriority int
-
-	// 遍历所有规则，找到优先级最高的匹配规则
-	for _, rule := range ss.rules {
-		if rule.Condition(pod) && rule.Priority > highestPriority {
-			selectedScheduler = rule.Scheduler
-			highestPriority = rule.Priority
+// podEventTarget 回退事件的记录对象
+// 准入阶段的Pod通常只有generateName，此时记录在所属控制器上
+func podEventTarget(pod *v1.Pod) runtime.Object {
+	if pod.Name != "" {
+		return pod
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return &v1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Namespace:  pod.Namespace,
+			UID:        owner.UID,
 		}
 	}
+	return nil
+}
 
-	// 如果没有匹配的规则，使用默认调度器
-	if selectedScheduler == "" {
-		return "default-scheduler"
+// SelectTemplateScheduler 为Pod模板选择调度器
+// 模板上已有的调度器不参与判断；ownerKind非空时模拟由该类型控制器创建的Pod。
+// 模板只使用首选调度器、不做可用性回退：修改模板会触发滚动更新，租约短暂过期时不应来回切换，
+// 首选调度器不在线时由准入Webhook对新建的Pod逐个回退
+func (ss *SchedulerSelector) SelectTemplateScheduler(namespace string, template *v1.PodTemplateSpec, ownerKind string) string {
+	pod := &v1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	pod.Namespace = namespace
+	pod.Spec.SchedulerName = ""
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Controller: &controller}}
 	}
 
-	return selectedScheduler
+	return ss.selectFor(context.Background(), pod, nil, false)
 }
 
-// UpdatePodScheduler 更新Pod的调度器配置
-// 根据选择结果更新Pod的schedulerName字段
-func (ss *SchedulerSelector) UpdatePodScheduler(ctx context.Context, pod *v1.Pod) error {
-	selectedScheduler := ss.SelectScheduler(pod)
+// selectFor 按策略引擎给出的候选顺序选择第一个在线的调度器；fallback为false时直接返回首选调度器
+func (ss *SchedulerSelector) selectFor(ctx context.Context, pod *v1.Pod, target runtime.Object, fallback bool) string {
+	decisions, err := ss.engine.EvaluateAll(pod)
+	if err != nil {
+		// 规则配置错误时使用默认调度器
+		klog.Errorf("Failed to evaluate scheduling policy for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		return v1.DefaultSchedulerName
+	}
 
-	// 如果调度器没有变化，无需更新
-	if pod.Spec.SchedulerName == selectedScheduler {
-		return nil // 无需更新，避免不必要的API调用
+	preferred := decisions[0].Scheduler
+	if ss.registry == nil || !fallback {
+		return preferred
 	}
 
-	// 更新Pod的调度器名称
-	pod.Spec.SchedulerName = selectedScheduler
+	ctx, cancel := context.WithTimeout(ctx, availabilityCheckTimeout)
+	defer cancel()
 
-	// 调用API更新Pod配置
-	_, err := ss.client.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
-	return err
+	var skipped []string
+	checked := make(map[string]bool)
+	for _, decision := range decisions {
+		if checked[decision.Scheduler] {
+			continue
+		}
+		checked[decision.Scheduler] = true
+
+		available, reason := ss.registry.IsAvailable(ctx, decision.Scheduler)
+		if available {
+			if len(skipped) > 0 {
+				ss.recordFallback(pod, target, decision.Scheduler, skipped)
+			}
+			return decision.Scheduler
+		}
+		skipped = append(skipped, fmt.Sprintf("%s (%s)", decision.Scheduler, reason))
+	}
+
+	ss.recordFallback(pod, target, v1.DefaultSchedulerName, skipped)
+	return v1.DefaultSchedulerName
+}
+
+// recordFallback 记录调度器回退事件
+func (ss *SchedulerSelector) recordFallback(pod *v1.Pod, target runtime.Object, selected string, skipped []string) {
+	message := fmt.Sprintf("Falling back to scheduler %s: %s unavailable", selected, strings.Join(skipped, ", "))
+	klog.Warningf("Pod %s/%s%s: %s", pod.Namespace, pod.Name, pod.GenerateName, message)
+
+	if ss.recorder != nil && target != nil {
+		ss.recorder.Event(target, v1.EventTypeWarning, "SchedulerFallback", message)
+	}
+}
+
+// UpdatePodScheduler 更新Pod所属工作负载的调度器配置
+// 已创建Pod的spec.schedulerName不可修改，因此改为更新所属Deployment、StatefulSet或CronJob的Pod模板，
+// 新调度器对之后创建的Pod生效；没有可修改模板的Pod（裸Pod、Job）返回错误，需通过准入Webhook处理
+func (ss *SchedulerSelector) UpdatePodScheduler(ctx context.Context, pod *v1.Pod) error {
+	ref, err := ResolveWorkloadRef(ctx, ss.client, pod)
+	if err != nil {
+		return err
+	}
+
+	controller := ss.controller
+	switch ref.Kind {
+	case "Deployment":
+		d, err := ss.client.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return controller.reconcileDeployment(ctx, d)
+	case "StatefulSet":
+		s, err := ss.client.AppsV1().StatefulSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return controller.reconcileStatefulSet(ctx, s)
+	case "CronJob":
+		cj, err := ss.client.BatchV1().CronJobs(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return controller.reconcileCronJob(ctx, cj)
+	default:
+		return fmt.Errorf("cannot change scheduler of pod %s/%s owned by %s: schedulerName is immutable, use the admission webhook",
+			pod.Namespace, pod.Name, ref)
+	}
 }
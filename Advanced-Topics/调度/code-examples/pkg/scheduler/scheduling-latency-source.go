@@ -0,0 +1,228 @@
+// scheduling-latency-source.go
+// 调度延迟观测来源 - 从本进程的Prometheus注册表或调度器的/metrics端点读取调度延迟直方图
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// KubeSchedulerAttemptDurationMetric kube-scheduler暴露的调度尝试耗时直方图
+const KubeSchedulerAttemptDurationMetric = "scheduler_scheduling_attempt_duration_seconds"
+
+// SchedulingLatencySource 调度延迟观测来源
+type SchedulingLatencySource interface {
+	// Snapshot 返回调度延迟直方图的累计快照
+	Snapshot(ctx context.Context) (*LatencySnapshot, error)
+}
+
+// LatencySnapshot 累计直方图快照
+type LatencySnapshot struct {
+	Timestamp time.Time
+	Count     uint64
+	Sum       float64
+	Buckets   []LatencyBucket // 按上界升序排列的累计桶
+}
+
+// LatencyBucket 直方图桶
+type LatencyBucket struct {
+	UpperBound float64 // 秒
+	Count      uint64  // 累计计数
+}
+
+// LatencyWindow 两个快照之间的调度延迟统计
+type LatencyWindow struct {
+	Samples    uint64
+	P95        time.Duration
+	Mean       time.Duration
+	Throughput float64 // 每秒调度次数
+}
+
+// Since 计算from到当前快照之间的统计；调度器重启导致计数器归零时以当前快照作为窗口内的全部数据
+func (s *LatencySnapshot) Since(from *LatencySnapshot) LatencyWindow {
+	delta := s
+	if from != nil && s.Count >= from.Count {
+		delta = &LatencySnapshot{Count: s.Count - from.Count, Sum: s.Sum - from.Sum}
+		previous := make(map[float64]uint64, len(from.Buckets))
+		for _, bucket := range from.Buckets {
+			previous[bucket.UpperBound] = bucket.Count
+		}
+		for _, bucket := range s.Buckets {
+			count := bucket.Count
+			if prev := previous[bucket.UpperBound]; prev <= count {
+				count -= prev
+			}
+			delta.Buckets = append(delta.Buckets, LatencyBucket{UpperBound: bucket.UpperBound, Count: count})
+		}
+	}
+
+	window := LatencyWindow{Samples: delta.Count}
+	if delta.Count == 0 {
+		return window
+	}
+	window.P95 = secondsToDuration(histogramQuantile(0.95, delta.Buckets, delta.Count))
+	window.Mean = secondsToDuration(delta.Sum / float64(delta.Count))
+	if from != nil {
+		if elapsed := s.Timestamp.Sub(from.Timestamp).Seconds(); elapsed > 0 {
+			window.Throughput = float64(delta.Count) / elapsed
+		}
+	}
+	return window
+}
+
+// histogramQuantile 与PromQL histogram_quantile相同，在目标桶内线性插值
+func histogramQuantile(q float64, buckets []LatencyBucket, total uint64) float64 {
+	rank := q * float64(total)
+	lowerBound, lowerCount := 0.0, uint64(0)
+	for _, bucket := range buckets {
+		if float64(bucket.Count) >= rank {
+			if math.IsInf(bucket.UpperBound, 1) {
+				// 落在+Inf桶时只能返回最后一个有限上界
+				return lowerBound
+			}
+			inBucket := float64(bucket.Count - lowerCount)
+			if inBucket == 0 {
+				return bucket.UpperBound
+			}
+			return lowerBound + (bucket.UpperBound-lowerBound)*(rank-float64(lowerCount))/inBucket
+		}
+		lowerBound, lowerCount = bucket.UpperBound, bucket.Count
+	}
+	return lowerBound
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// snapshotFromFamilies 汇总指标族中标签匹配的所有直方图序列
+func snapshotFromFamilies(families map[string]*dto.MetricFamily, name string, labels map[string]string) (*LatencySnapshot, error) {
+	family, exists := families[name]
+	if !exists {
+		return nil, fmt.Errorf("metric %s not found", name)
+	}
+	if family.GetType() != dto.MetricType_HISTOGRAM {
+		return nil, fmt.Errorf("metric %s is %s, not a histogram", name, family.GetType())
+	}
+
+	snapshot := &LatencySnapshot{Timestamp: time.Now()}
+	buckets := make(map[float64]uint64)
+	for _, metric := range family.GetMetric() {
+		if !metricMatches(metric, labels) {
+			continue
+		}
+		histogram := metric.GetHistogram()
+		snapshot.Count += histogram.GetSampleCount()
+		snapshot.Sum += histogram.GetSampleSum()
+		for _, bucket := range histogram.GetBucket() {
+			buckets[bucket.GetUpperBound()] += bucket.GetCumulativeCount()
+		}
+	}
+
+	for upperBound, count := range buckets {
+		snapshot.Buckets = append(snapshot.Buckets, LatencyBucket{UpperBound: upperBound, Count: count})
+	}
+	sort.Slice(snapshot.Buckets, func(i, j int) bool {
+		return snapshot.Buckets[i].UpperBound < snapshot.Buckets[j].UpperBound
+	})
+	return snapshot, nil
+}
+
+func metricMatches(metric *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, pair := range metric.GetLabel() {
+		if value, exists := labels[pair.GetName()]; exists {
+			if value != pair.GetValue() {
+				return false
+			}
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+// GathererLatencySource 从Prometheus注册表读取调度延迟
+type GathererLatencySource struct {
+	Gatherer   prometheus.Gatherer
+	MetricName string
+	Labels     map[string]string // 只统计标签匹配的序列
+}
+
+// Snapshot 实现SchedulingLatencySource
+func (s *GathererLatencySource) Snapshot(ctx context.Context) (*LatencySnapshot, error) {
+	gathered, err := s.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	families := make(map[string]*dto.MetricFamily, len(gathered))
+	for _, family := range gathered {
+		families[family.GetName()] = family
+	}
+	return snapshotFromFamilies(families, s.MetricName, s.Labels)
+}
+
+// LatencySource 返回指定调度器在SchedulerMetrics中记录的调度延迟来源
+func (sm *SchedulerMetrics) LatencySource(schedulerName string) SchedulingLatencySource {
+	return &GathererLatencySource{
+		Gatherer:   prometheus.DefaultGatherer,
+		MetricName: "scheduler_scheduling_latency_seconds",
+		Labels:     map[string]string{"scheduler": schedulerName},
+	}
+}
+
+// EndpointLatencySource 从调度器的/metrics端点读取调度延迟
+// 默认读取kube-scheduler的scheduler_scheduling_attempt_duration_seconds中result="scheduled"的序列
+type EndpointLatencySource struct {
+	URL        string // 如https://kube-scheduler-metrics.kube-system:10259/metrics
+	Client     *http.Client
+	MetricName string
+	Labels     map[string]string
+}
+
+// NewEndpointLatencySource 创建读取kube-scheduler /metrics端点的延迟来源，profile为空时统计所有配置档
+func NewEndpointLatencySource(url, profile string, client *http.Client) *EndpointLatencySource {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	labels := map[string]string{"result": "scheduled"}
+	if profile != "" {
+		labels["profile"] = profile
+	}
+	return &EndpointLatencySource{
+		URL:        url,
+		Client:     client,
+		MetricName: KubeSchedulerAttemptDurationMetric,
+		Labels:     labels,
+	}
+}
+
+// Snapshot 实现SchedulingLatencySource
+func (s *EndpointLatencySource) Snapshot(ctx context.Context) (*LatencySnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scrape %s: %v", s.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to scrape %s: status %d", s.URL, resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics from %s: %v", s.URL, err)
+	}
+	return snapshotFromFamilies(families, s.MetricName, s.Labels)
+}
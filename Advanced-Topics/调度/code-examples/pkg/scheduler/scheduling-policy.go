@@ -0,0 +1,420 @@
+// scheduling-policy.go
+// 调度策略引擎 - 统一的工作负载分类规则，调度器选择由分类结果推导
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 工作负载类型，WorkloadTypeLabel标签的取值
+const (
+	WorkloadTypeWebFrontend     = "web-frontend"
+	WorkloadTypeBatch           = "batch-processing"
+	WorkloadTypeMLTraining      = "ml-training"
+	WorkloadTypeDatabase        = "database"
+	WorkloadTypeMemoryIntensive = "memory-intensive"
+	WorkloadTypeGeneral         = "general"
+)
+
+// 工作负载优先级，WorkloadPriorityLabel标签的取值
+const (
+	WorkloadPriorityLabel    = "workload.kubernetes.io/priority"
+	WorkloadPriorityRealtime = "realtime"
+	WorkloadPriorityHigh     = "high"
+	WorkloadPriorityLow      = "low"
+)
+
+// legacyWorkloadTypes 旧版标签取值到统一取值的映射
+var legacyWorkloadTypes = map[string]string{
+	"batch": WorkloadTypeBatch,
+}
+
+// highMemoryThreshold 单个容器内存请求超过该值视为内存密集型
+var highMemoryThreshold = resource.MustParse("8Gi")
+
+// SchedulingPolicyEngine 调度策略引擎
+// WorkloadClassifier和SchedulerSelector共用的唯一规则集：先确定工作负载类型，
+// 再由类型对应配置文件的首选调度器得出调度器
+type SchedulingPolicyEngine struct {
+	rules    []ClassificationRule        // 分类规则列表，按优先级从高到低排序
+	profiles map[string]*WorkloadProfile // 工作负载配置文件映射
+}
+
+// PolicyDecision 策略评估结果
+type PolicyDecision struct {
+	WorkloadType string           // 工作负载类型
+	Priority     string           // 工作负载优先级
+	Scheduler    string           // 由工作负载类型推导的调度器
+	Rule         string           // 命中的规则名称，使用默认配置文件时为空
+	Profile      *WorkloadProfile // 工作负载配置文件
+}
+
+// NewSchedulingPolicyEngine 创建带有预定义配置文件和规则的策略引擎
+func NewSchedulingPolicyEngine() *SchedulingPolicyEngine {
+	e := &SchedulingPolicyEngine{
+		profiles: make(map[string]*WorkloadProfile),
+	}
+
+	e.initializeProfiles()
+	e.initializeRules()
+
+	return e
+}
+
+// Evaluate 评估Pod的工作负载类型和调度器
+// 这是分类和调度器选择的唯一入口：Pod已声明的工作负载类型标签优先，其次按规则优先级匹配，
+// 都未命中时使用通用配置文件
+func (e *SchedulingPolicyEngine) Evaluate(pod *v1.Pod) (*PolicyDecision, error) {
+	decisions, err := e.EvaluateAll(pod)
+	if err != nil {
+		return nil, err
+	}
+	return decisions[0], nil
+}
+
+// EvaluateAll 按优先级返回Pod命中的所有决策，最后一项总是通用配置文件
+// 首选调度器不可用时，调用方可以依次回退到后续决策
+func (e *SchedulingPolicyEngine) EvaluateAll(pod *v1.Pod) ([]*PolicyDecision, error) {
+	var decisions []*PolicyDecision
+
+	if declared, exists := pod.Labels[WorkloadTypeLabel]; exists {
+		workloadType := NormalizeWorkloadType(declared)
+		if profile, known := e.profiles[workloadType]; known {
+			decisions = append(decisions, e.decide(profile, "Declared Workload Type"))
+		}
+	}
+
+	for _, rule := range e.rules {
+		if rule.Condition(pod) {
+			profile, exists := e.profiles[rule.WorkloadType]
+			if !exists {
+				return nil, fmt.Errorf("workload profile not found: %s", rule.WorkloadType)
+			}
+			decisions = append(decisions, e.decide(profile, rule.Name))
+		}
+	}
+
+	return append(decisions, e.decide(e.profiles[WorkloadTypeGeneral], "")), nil
+}
+
+func (e *SchedulingPolicyEngine) decide(profile *WorkloadProfile, rule string) *PolicyDecision {
+	scheduler := profile.SchedulingHints.PreferredScheduler
+	if scheduler == "" {
+		scheduler = v1.DefaultSchedulerName
+	}
+
+	return &PolicyDecision{
+		WorkloadType: profile.Type,
+		Priority:     profile.Priority,
+		Scheduler:    scheduler,
+		Rule:         rule,
+		Profile:      profile,
+	}
+}
+
+// GetProfile 按工作负载类型获取配置文件
+func (e *SchedulingPolicyEngine) GetProfile(workloadType string) (*WorkloadProfile, bool) {
+	profile, exists := e.profiles[NormalizeWorkloadType(workloadType)]
+	return profile, exists
+}
+
+// ProfileTypes 返回所有工作负载类型，按名称排序
+func (e *SchedulingPolicyEngine) ProfileTypes() []string {
+	types := make([]string, 0, len(e.profiles))
+	for workloadType := range e.profiles {
+		types = append(types, workloadType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NormalizeWorkloadType 将旧版工作负载类型取值转换为统一取值
+func NormalizeWorkloadType(workloadType string) string {
+	if normalized, exists := legacyWorkloadTypes[workloadType]; exists {
+		return normalized
+	}
+	return workloadType
+}
+
+// initializeProfiles 初始化预定义的工作负载配置文件
+// 定义常见工作负载类型的资源模式和调度要求
+func (e *SchedulingPolicyEngine) initializeProfiles() {
+	// Web前端应用配置文件
+	// 特点：网络密集型，低延迟要求，需要高可用性
+	e.profiles[WorkloadTypeWebFrontend] = &WorkloadProfile{
+		Type:        WorkloadTypeWebFrontend,
+		Priority:    WorkloadPriorityRealtime,
+		Description: "Web frontend applications",
+		ResourcePattern: ResourcePattern{
+			CPUIntensive:     false,         // 非CPU密集型
+			MemoryIntensive:  false,         // 非内存密集型
+			IOIntensive:      false,         // 非IO密集型
+			NetworkIntensive: true,          // 网络密集型，需要处理大量HTTP请求
+			GPURequired:      false,         // 不需要GPU
+			StoragePattern:   "low-latency", // 需要低延迟存储访问
+		},
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: "realtime-scheduler", // 使用实时调度器
+			// 拓扑分布约束：确保Pod在不同节点上均匀分布
+			TopologySpread: []v1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,                        // 最大偏差为1
+					TopologyKey:       "kubernetes.io/hostname", // 按主机名分布
+					WhenUnsatisfiable: v1.DoNotSchedule,         // 不满足时拒绝调度
+				},
+			},
+		},
+		SLA: SLARequirements{
+			MaxLatency:        100 * time.Millisecond, // 最大延迟100ms
+			Availability:      99.9,                   // 99.9%可用性
+			Throughput:        1000,                   // 1000 RPS
+			ResourceGuarantee: true,                   // 需要资源保证
+		},
+	}
+
+	// 批处理作业配置文件
+	// 特点：CPU和内存密集型，对延迟不敏感，可容忍较低可用性
+	e.profiles[WorkloadTypeBatch] = &WorkloadProfile{
+		Type:        WorkloadTypeBatch,
+		Priority:    WorkloadPriorityLow,
+		Description: "Batch processing jobs",
+		ResourcePattern: ResourcePattern{
+			CPUIntensive:     true,              // CPU密集型，需要大量计算资源
+			MemoryIntensive:  true,              // 内存密集型，处理大数据集
+			IOIntensive:      false,             // 非IO密集型
+			NetworkIntensive: false,             // 非网络密集型
+			GPURequired:      false,             // 通常不需要GPU
+			StoragePattern:   "high-throughput", // 需要高吞吐量存储
+		},
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: "batch-scheduler", // 使用批处理调度器
+			// 容忍度：可以调度到专用的批处理节点
+			Tolerations: []v1.Toleration{
+				{
+					Key:      "node.kubernetes.io/batch", // 批处理节点污点键
+					Operator: v1.TolerationOpEqual,       // 等值匹配
+					Value:    "true",                     // 污点值
+					Effect:   v1.TaintEffectNoSchedule,   // NoSchedule效果
+				},
+			},
+		},
+		SLA: SLARequirements{
+			MaxLatency:        0,     // 不关心延迟
+			Availability:      95.0,  // 较低的可用性要求
+			Throughput:        0,     // 不关心实时吞吐量
+			ResourceGuarantee: false, // 不需要严格的资源保证
+		},
+	}
+
+	// 机器学习训练配置文件
+	// 特点：需要GPU，CPU/内存/IO密集型，需要资源保证
+	e.profiles[WorkloadTypeMLTraining] = &WorkloadProfile{
+		Type:        WorkloadTypeMLTraining,
+		Priority:    WorkloadPriorityHigh,
+		Description: "Machine learning training jobs",
+		ResourcePattern: ResourcePattern{
+			CPUIntensive:     true,        // CPU密集型，模型训练需要大量计算
+			MemoryIntensive:  true,        // 内存密集型，加载大型数据集和模型
+			IOIntensive:      true,        // IO密集型，频繁读取训练数据
+			NetworkIntensive: false,       // 非网络密集型（单机训练）
+			GPURequired:      true,        // 需要GPU加速
+			StoragePattern:   "high-iops", // 需要高IOPS存储
+		},
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: "gpu-scheduler", // 使用GPU调度器
+			// 节点亲和性：必须调度到有GPU的节点
+			NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{
+									Key:      "accelerator",                                // GPU类型标签
+									Operator: v1.NodeSelectorOpIn,                          // 包含操作符
+									Values:   []string{"nvidia-tesla-v100", "nvidia-a100"}, // 支持的GPU型号
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		SLA: SLARequirements{
+			MaxLatency:        0,    // 不关心延迟（批处理任务）
+			Availability:      99.0, // 高可用性要求
+			Throughput:        0,    // 不关心实时吞吐量
+			ResourceGuarantee: true, // 需要资源保证，避免训练中断
+		},
+	}
+
+	e.profiles[WorkloadTypeDatabase] = &WorkloadProfile{
+		Type:        WorkloadTypeDatabase,
+		Priority:    WorkloadPriorityHigh,
+		Description: "Database workloads",
+		ResourcePattern: ResourcePattern{
+			CPUIntensive:     false,
+			MemoryIntensive:  true,
+			IOIntensive:      true,
+			NetworkIntensive: true,
+			GPURequired:      false,
+			StoragePattern:   "high-iops",
+		},
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: "default-scheduler",
+			PodAntiAffinity: &v1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []v1.PodAffinityTerm{
+					{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"app.kubernetes.io/component": "database",
+							},
+						},
+						TopologyKey: "kubernetes.io/hostname",
+					},
+				},
+			},
+		},
+		SLA: SLARequirements{
+			MaxLatency:        50 * time.Millisecond,
+			Availability:      99.99,
+			Throughput:        5000,
+			ResourceGuarantee: true,
+		},
+	}
+
+	// 内存密集型配置文件
+	// 特点：单个Pod内存请求很大，交给内存优化调度器做装箱
+	e.profiles[WorkloadTypeMemoryIntensive] = &WorkloadProfile{
+		Type:        WorkloadTypeMemoryIntensive,
+		Priority:    WorkloadPriorityHigh,
+		Description: "Memory intensive workloads",
+		ResourcePattern: ResourcePattern{
+			MemoryIntensive: true,
+		},
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: "memory-optimized-scheduler",
+		},
+	}
+
+	// 通用工作负载配置文件
+	// 未命中任何规则时使用，交给默认调度器且不添加额外约束
+	e.profiles[WorkloadTypeGeneral] = &WorkloadProfile{
+		Type:        WorkloadTypeGeneral,
+		Description: "General workloads without specific scheduling needs",
+		SchedulingHints: SchedulingHints{
+			PreferredScheduler: v1.DefaultSchedulerName,
+		},
+	}
+}
+
+// initializeRules 初始化分类规则并按优先级排序
+func (e *SchedulingPolicyEngine) initializeRules() {
+	e.rules = []ClassificationRule{
+		{
+			Name:         "GPU Workload Detection",
+			Priority:     100,
+			Condition:    podRequestsGPU,
+			WorkloadType: WorkloadTypeMLTraining,
+		},
+		{
+			Name:     "Web Frontend Detection",
+			Priority: 90,
+			Condition: func(pod *v1.Pod) bool {
+				// 实时优先级标签
+				if pod.Labels[WorkloadPriorityLabel] == WorkloadPriorityRealtime {
+					return true
+				}
+
+				// 检查标签和注解
+				if appType, exists := pod.Labels["app.kubernetes.io/component"]; exists {
+					return strings.Contains(strings.ToLower(appType), "frontend") ||
+						strings.Contains(strings.ToLower(appType), "web")
+				}
+
+				// 检查容器端口
+				for _, container := range pod.Spec.Containers {
+					for _, port := range container.Ports {
+						if port.ContainerPort == 80 || port.ContainerPort == 443 || port.ContainerPort == 8080 {
+							return true
+						}
+					}
+				}
+				return false
+			},
+			WorkloadType: WorkloadTypeWebFrontend,
+		},
+		{
+			Name:     "Database Detection",
+			Priority: 85,
+			Condition: func(pod *v1.Pod) bool {
+				// 检查镜像名称
+				imagePatterns := []string{
+					"mysql", "postgres", "mongodb", "redis", "elasticsearch",
+					"cassandra", "mariadb", "oracle", "mssql",
+				}
+
+				for _, container := range pod.Spec.Containers {
+					imageName := strings.ToLower(container.Image)
+					for _, pattern := range imagePatterns {
+						if strings.Contains(imageName, pattern) {
+							return true
+						}
+					}
+				}
+
+				// 检查标签
+				if component, exists := pod.Labels["app.kubernetes.io/component"]; exists {
+					return strings.Contains(strings.ToLower(component), "database") ||
+						strings.Contains(strings.ToLower(component), "db")
+				}
+				return false
+			},
+			WorkloadType: WorkloadTypeDatabase,
+		},
+		{
+			Name:     "Batch Job Detection",
+			Priority: 80,
+			Condition: func(pod *v1.Pod) bool {
+				// 检查Job或CronJob
+				for _, owner := range pod.OwnerReferences {
+					if owner.Kind == "Job" || owner.Kind == "CronJob" {
+						return true
+					}
+				}
+
+				// 检查标签
+				if jobType, exists := pod.Labels["app.kubernetes.io/component"]; exists {
+					return strings.Contains(strings.ToLower(jobType), "batch") ||
+						strings.Contains(strings.ToLower(jobType), "job")
+				}
+				return false
+			},
+			WorkloadType: WorkloadTypeBatch,
+		},
+		{
+			Name:     "High Memory Detection",
+			Priority: 70,
+			Condition: func(pod *v1.Pod) bool {
+				// 检查是否有高内存需求（>8Gi）
+				for _, container := range pod.Spec.Containers {
+					if memory, exists := container.Resources.Requests[v1.ResourceMemory]; exists && memory.Cmp(highMemoryThreshold) > 0 {
+						return true
+					}
+				}
+				return false
+			},
+			WorkloadType: WorkloadTypeMemoryIntensive,
+		},
+	}
+
+	sort.SliceStable(e.rules, func(i, j int) bool {
+		return e.rules[i].Priority > e.rules[j].Priority
+	})
+}
@@ -0,0 +1,424 @@
+// stuck-pod-remediation.go
+// 卡住Pod的处置 - 先用故障排除器诊断长时间Pending的Pod，按原因处置，
+// 只删除由控制器管理、调度器尝试过且按节点剩余容量已能放下的Pod，其余Pod记录事件和建议
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// 卡住Pod的原因
+const (
+	StuckCauseQuota                 = "quota"                  // 命名空间资源配额已满
+	StuckCauseTaint                 = "taint"                  // 没有可容忍污点的节点
+	StuckCauseInsufficientResources = "insufficient_resources" // 没有节点满足资源请求
+	StuckCauseAffinity              = "affinity"               // 亲和性约束无法满足
+	StuckCauseStaleFailure          = "stale_failure"          // 调度器记录的失败原因已不存在
+	StuckCauseNotAttempted          = "not_attempted"          // 调度器未尝试调度（调度门控未移除或schedulerName对应的调度器未运行）
+	StuckCauseUnknown               = "unknown"
+)
+
+// 卡住Pod的处置方式
+const (
+	StuckPodDelete = "delete" // 删除Pod，由控制器重建后重新调度
+	StuckPodReport = "report" // 只记录事件和建议，需人工处理
+)
+
+// stuckPodAge Pending超过该时长的Pod视为卡住
+const stuckPodAge = 10 * time.Minute
+
+// maxStuckPodsPerRun 每次诊断的最大Pod数量，每个Pod的诊断需要多次列出节点和配额
+const maxStuckPodsPerRun = 50
+
+// StuckPodRemediation 单个卡住Pod的诊断和处置
+type StuckPodRemediation struct {
+	Pod             string        `json:"pod"`
+	Age             time.Duration `json:"age"`
+	Cause           string        `json:"cause"`
+	Transient       bool          `json:"transient"` // 原因是否已消失，重建Pod即可重新调度
+	Controller      string        `json:"controller,omitempty"`
+	Action          string        `json:"action"`
+	Reason          string        `json:"reason"`
+	Recommendations []string      `json:"recommendations,omitempty"`
+	Executed        bool          `json:"executed"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// StuckPodRemediationReport 一次卡住Pod处置的报告，DryRun时只有诊断没有执行
+type StuckPodRemediationReport struct {
+	Timestamp time.Time             `json:"timestamp"`
+	DryRun    bool                  `json:"dryRun"`
+	Pending   int                   `json:"pending"` // 未调度的Pending Pod总数
+	Pods      []StuckPodRemediation `json:"pods"`
+}
+
+// Summary 按处置方式和原因汇总报告
+func (r *StuckPodRemediationReport) Summary() string {
+	deleted, reported, failed := 0, 0, 0
+	causes := make(map[string]int)
+	for _, pod := range r.Pods {
+		causes[pod.Cause]++
+		switch {
+		case pod.Error != "":
+			failed++
+		case pod.Action == StuckPodDelete:
+			deleted++
+		default:
+			reported++
+		}
+	}
+	names := make([]string, 0, len(causes))
+	for cause, count := range causes {
+		names = append(names, fmt.Sprintf("%s=%d", cause, count))
+	}
+	sort.Strings(names)
+
+	verb := "deleted"
+	if r.DryRun {
+		verb = "would delete"
+	}
+	return fmt.Sprintf("%d stuck pods (%s): %s %d, reported %d, failed %d",
+		len(r.Pods), strings.Join(names, ", "), verb, deleted, reported, failed)
+}
+
+// WithEventRecorder 为无法自动处置的卡住Pod记录事件，为空时只写日志
+func (srm *SchedulerRecoveryManager) WithEventRecorder(recorder record.EventRecorder) *SchedulerRecoveryManager {
+	srm.recorder = recorder
+	return srm
+}
+
+// DiagnoseStuckPods 诊断卡住的Pod并给出处置方式，不做任何修改
+func (srm *SchedulerRecoveryManager) DiagnoseStuckPods(ctx context.Context) (*StuckPodRemediationReport, error) {
+	pendingPods, err := srm.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase=Pending,spec.nodeName=",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending pods: %v", err)
+	}
+
+	report := &StuckPodRemediationReport{
+		Timestamp: time.Now(),
+		DryRun:    true,
+		Pending:   len(pendingPods.Items),
+	}
+
+	var stuck []*v1.Pod
+	for i := range pendingPods.Items {
+		pod := &pendingPods.Items[i]
+		if pod.DeletionTimestamp == nil && time.Since(pod.CreationTimestamp.Time) > stuckPodAge {
+			stuck = append(stuck, pod)
+		}
+	}
+	// 最久的Pod优先
+	sort.Slice(stuck, func(i, j int) bool {
+		return stuck[i].CreationTimestamp.Before(&stuck[j].CreationTimestamp)
+	})
+	if len(stuck) > maxStuckPodsPerRun {
+		klog.Warningf("%d stuck pods found, diagnosing the oldest %d", len(stuck), maxStuckPodsPerRun)
+		stuck = stuck[:maxStuckPodsPerRun]
+	}
+
+	capacity, err := srm.snapshotFreeCapacity(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	troubleshooter := NewSchedulerTroubleshooter(srm.client)
+	for _, pod := range stuck {
+		diagnosis, err := troubleshooter.DiagnosePendingPod(ctx, pod.Namespace, pod.Name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to diagnose pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+		nodeName, fits := capacity.fits(pod)
+		remediation := planStuckPodRemediation(pod, diagnosis, fits)
+		if remediation.Action == StuckPodDelete {
+			// 重建的Pod会占用匹配节点的容量，后续Pod不能再按同一份剩余容量判断
+			capacity.reserve(nodeName, pod)
+		}
+		report.Pods = append(report.Pods, remediation)
+	}
+	return report, nil
+}
+
+// planStuckPodRemediation 根据诊断结果和节点剩余容量确定原因和处置方式，fits表示当前有节点能放下该Pod
+func planStuckPodRemediation(pod *v1.Pod, diagnosis *TroubleshootingReport, fits bool) StuckPodRemediation {
+	remediation := StuckPodRemediation{
+		Pod: pod.Namespace + "/" + pod.Name,
+		Age: time.Since(pod.CreationTimestamp.Time).Round(time.Second),
+	}
+	remediation.Cause, remediation.Transient = stuckPodCause(pod, diagnosis, fits)
+	if remediation.Cause == StuckCauseNotAttempted {
+		if len(pod.Spec.SchedulingGates) > 0 {
+			remediation.Recommendations = append(remediation.Recommendations,
+				fmt.Sprintf("Pod仍有 %d 个调度门控，等待其控制器移除", len(pod.Spec.SchedulingGates)))
+		} else {
+			remediation.Recommendations = append(remediation.Recommendations,
+				fmt.Sprintf("确认调度器 %q 正在运行", pod.Spec.SchedulerName))
+		}
+	}
+	for _, recommendation := range diagnosis.Recommendations {
+		remediation.Recommendations = append(remediation.Recommendations, recommendation.Description)
+	}
+
+	controller := metav1.GetControllerOf(pod)
+	if controller != nil {
+		remediation.Controller = controller.Kind + "/" + controller.Name
+	}
+
+	switch {
+	case remediation.Cause == StuckCauseNotAttempted:
+		remediation.Action = StuckPodReport
+		remediation.Reason = "scheduler never attempted the pod, recreating it would not change that"
+	case !remediation.Transient:
+		remediation.Action = StuckPodReport
+		remediation.Reason = "cause persists, recreating the pod would not help"
+	case controller == nil:
+		remediation.Action = StuckPodReport
+		remediation.Reason = "pod has no controller, deleting it would lose it"
+	default:
+		remediation.Action = StuckPodDelete
+		remediation.Reason = "cause no longer present, recreating the pod triggers a fresh scheduling attempt"
+	}
+	return remediation
+}
+
+// stuckPodCause 结合调度器记录的失败信息和当前诊断判断原因，transient表示该原因在集群中已不存在
+// 资源不足和污点只有在按节点剩余容量重新检查能放下（fits）时才视为已消失：
+// 故障排除器的节点资源检查只比较可分配总量，不能据此判断
+func stuckPodCause(pod *v1.Pod, diagnosis *TroubleshootingReport, fits bool) (cause string, transient bool) {
+	failed := make(map[string]bool)
+	for _, step := range diagnosis.Diagnosis {
+		if step.Status == "failed" {
+			failed[step.Step] = true
+		}
+	}
+
+	var message string
+	attempted := false
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionFalse {
+			message = condition.Message
+			attempted = true
+			break
+		}
+	}
+
+	switch {
+	case failed["resource_quotas"]:
+		return StuckCauseQuota, false
+	case strings.Contains(message, "didn't match Pod's node affinity"),
+		strings.Contains(message, "didn't match pod affinity"),
+		strings.Contains(message, "didn't match pod anti-affinity"):
+		return StuckCauseAffinity, false
+	case !attempted || len(pod.Spec.SchedulingGates) > 0:
+		// 调度门控未移除或调度器未运行，重建Pod不会改变
+		return StuckCauseNotAttempted, false
+	case fits && (failed["node_resources"] || failed["taints_tolerations"] ||
+		strings.Contains(message, "Insufficient") ||
+		strings.Contains(message, "untolerated taint") ||
+		strings.Contains(message, "were unschedulable")):
+		// 调度器记录的资源不足或污点原因按当前剩余容量已不存在
+		return StuckCauseStaleFailure, true
+	case failed["node_resources"], strings.Contains(message, "Insufficient"):
+		return StuckCauseInsufficientResources, false
+	case failed["taints_tolerations"], strings.Contains(message, "untolerated taint"),
+		strings.Contains(message, "were unschedulable"):
+		return StuckCauseTaint, false
+	default:
+		return StuckCauseUnknown, false
+	}
+}
+
+// freeCapacity 可调度节点的剩余容量快照，一次诊断内所有卡住的Pod共用，计划删除的Pod从中预留
+type freeCapacity struct {
+	nodes []v1.Node
+	free  map[string]v1.ResourceList // 节点名 -> 可分配量减去已调度Pod的请求
+}
+
+// snapshotFreeCapacity 计算就绪且可调度节点的剩余容量
+func (srm *SchedulerRecoveryManager) snapshotFreeCapacity(ctx context.Context) (*freeCapacity, error) {
+	nodes, err := srm.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	pods, err := srm.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName!=,status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled pods: %v", err)
+	}
+
+	capacity := &freeCapacity{free: make(map[string]v1.ResourceList)}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || !isNodeReady(&node) {
+			continue
+		}
+		capacity.nodes = append(capacity.nodes, node)
+		free := node.Status.Allocatable.DeepCopy()
+		if free == nil {
+			free = v1.ResourceList{}
+		}
+		capacity.free[node.Name] = free
+	}
+	for i := range pods.Items {
+		capacity.reserve(pods.Items[i].Spec.NodeName, &pods.Items[i])
+	}
+	return capacity, nil
+}
+
+// reserve 从节点剩余容量中减去Pod的请求和一个Pod数量，节点不在快照中时忽略
+func (c *freeCapacity) reserve(nodeName string, pod *v1.Pod) {
+	free, ok := c.free[nodeName]
+	if !ok {
+		return
+	}
+	for name, quantity := range podResourceRequests(pod) {
+		if remaining, ok := free[name]; ok {
+			remaining.Sub(quantity)
+			free[name] = remaining
+		}
+	}
+	if remaining, ok := free[v1.ResourcePods]; ok {
+		remaining.Sub(*resource.NewQuantity(1, resource.DecimalSI))
+		free[v1.ResourcePods] = remaining
+	}
+}
+
+// fits 是否有节点满足Pod的nodeSelector、容忍其NoSchedule和NoExecute污点，且剩余容量放得下Pod的请求，
+// 返回第一个满足的节点
+func (c *freeCapacity) fits(pod *v1.Pod) (string, bool) {
+	requests := podResourceRequests(pod)
+	for i := range c.nodes {
+		node := &c.nodes[i]
+		if !labels.SelectorFromSet(pod.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		if !toleratesNodeTaints(pod, node) {
+			continue
+		}
+		free := c.free[node.Name]
+		if pods, ok := free[v1.ResourcePods]; ok && pods.Sign() <= 0 {
+			continue
+		}
+		fits := true
+		for name, quantity := range requests {
+			if quantity.IsZero() {
+				continue
+			}
+			if remaining, ok := free[name]; !ok || remaining.Cmp(quantity) < 0 {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return node.Name, true
+		}
+	}
+	return "", false
+}
+
+// toleratesNodeTaints Pod是否容忍节点上所有NoSchedule和NoExecute污点
+func toleratesNodeTaints(pod *v1.Pod, node *v1.Node) bool {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// podResourceRequests Pod的有效资源请求：容器请求之和与各Init容器请求取较大值，再加上Pod开销
+func podResourceRequests(pod *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := requests[name]
+			total.Add(quantity)
+			requests[name] = total
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	for name, quantity := range pod.Spec.Overhead {
+		total := requests[name]
+		total.Add(quantity)
+		requests[name] = total
+	}
+	return requests
+}
+
+// remediateStuckPods 执行报告中的处置，DryRun时不修改集群
+func (srm *SchedulerRecoveryManager) remediateStuckPods(ctx context.Context, report *StuckPodRemediationReport) {
+	report.DryRun = srm.config.DryRun
+	for i := range report.Pods {
+		remediation := &report.Pods[i]
+		klog.Infof("Stuck pod %s: cause=%s action=%s (%s)", remediation.Pod, remediation.Cause, remediation.Action, remediation.Reason)
+		if report.DryRun {
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(remediation.Pod, "/")
+		pod, err := srm.client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			remediation.Error = err.Error()
+			continue
+		}
+		if pod.Spec.NodeName != "" {
+			// 诊断期间已被调度
+			continue
+		}
+
+		switch remediation.Action {
+		case StuckPodDelete:
+			err := srm.client.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{UID: &pod.UID},
+			})
+			if err != nil && !errors.IsNotFound(err) {
+				remediation.Error = err.Error()
+				klog.Errorf("Failed to delete stuck pod %s: %v", remediation.Pod, err)
+				continue
+			}
+			klog.Infof("Deleted stuck pod %s for %s to recreate", remediation.Pod, remediation.Controller)
+		case StuckPodReport:
+			if srm.recorder != nil {
+				srm.recorder.Eventf(pod, v1.EventTypeWarning, "SchedulingStuck",
+					"Pending for %v, cause: %s. %s", remediation.Age, remediation.Cause, strings.Join(remediation.Recommendations, "; "))
+			}
+		}
+		remediation.Executed = true
+	}
+}
@@ -0,0 +1,465 @@
+// usage-classifier.go
+// 基于使用历史的工作负载分类器 - 根据所属工作负载的实际CPU/内存/IO使用情况推断资源模式并选择配置文件
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// WorkloadRef 工作负载引用
+// 标识Pod的顶层所属工作负载，如Deployment、StatefulSet、CronJob
+type WorkloadRef struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+func (r WorkloadRef) String() string {
+	return fmt.Sprintf("%s/%s/%s", r.Namespace, r.Kind, r.Name)
+}
+
+// UsageSample 工作负载资源使用采样
+// 所有数值均为工作负载内各Pod的平均值
+type UsageSample struct {
+	Timestamp             time.Time
+	PodCount              int
+	CPUCores              float64 // CPU使用量（核）
+	CPURequestCores       float64 // CPU请求量（核），未设置时为0
+	MemoryBytes           float64 // 内存使用量（字节）
+	MemoryRequestBytes    float64 // 内存请求量（字节），未设置时为0
+	IOBytesPerSecond      float64 // 磁盘IO速率，metrics-server不提供时为0
+	NetworkBytesPerSecond float64 // 网络流量速率，metrics-server不提供时为0
+}
+
+// UsageHistoryProvider 使用历史数据源
+type UsageHistoryProvider interface {
+	// GetUsageHistory 返回工作负载在时间窗口内的使用采样，按时间升序
+	GetUsageHistory(ctx context.Context, ref WorkloadRef, window time.Duration) ([]UsageSample, error)
+}
+
+// UsageClassifierConfig 使用历史分类配置
+type UsageClassifierConfig struct {
+	Window                time.Duration // 参与分类的历史窗口
+	MinSamples            int           // 达到满置信度所需的最少采样数
+	MinConfidence         float64       // 低于该置信度时回退到静态分类
+	CPUIntensiveRatio     float64       // CPU使用量/请求量超过该比例视为CPU密集型
+	MemoryIntensiveRatio  float64       // 内存使用量/请求量超过该比例视为内存密集型
+	CPUIntensiveCores     float64       // 未设置请求量时，CPU使用量超过该值视为CPU密集型
+	MemoryIntensiveBytes  float64       // 未设置请求量时，内存使用量超过该值视为内存密集型
+	IOIntensiveBytes      float64       // IO速率超过该值视为IO密集型
+	NetworkIntensiveBytes float64       // 网络速率超过该值视为网络密集型
+}
+
+// DefaultUsageClassifierConfig 返回默认的使用历史分类配置
+func DefaultUsageClassifierConfig() UsageClassifierConfig {
+	return UsageClassifierConfig{
+		Window:                1 * time.Hour,
+		MinSamples:            12,
+		MinConfidence:         0.6,
+		CPUIntensiveRatio:     0.7,
+		MemoryIntensiveRatio:  0.7,
+		CPUIntensiveCores:     1.0,
+		MemoryIntensiveBytes:  4 * 1024 * 1024 * 1024,
+		IOIntensiveBytes:      50 * 1024 * 1024,
+		NetworkIntensiveBytes: 10 * 1024 * 1024,
+	}
+}
+
+// UsageClassification 使用历史分类结果
+type UsageClassification struct {
+	Profile    *WorkloadProfile
+	Pattern    ResourcePattern // 根据使用历史推断的资源模式
+	Confidence float64         // 置信度（0-1）
+	Reason     string          // 分类依据
+	Source     string          // ClassificationSourceUsage或回退时的ClassificationSourceStatic
+}
+
+// UsageClassifier 基于使用历史的工作负载分类器
+// 可选组件：数据不足或置信度不够时回退到WorkloadClassifier的静态规则
+type UsageClassifier struct {
+	client     kubernetes.Interface
+	classifier *WorkloadClassifier
+	history    UsageHistoryProvider
+	config     UsageClassifierConfig
+}
+
+// NewUsageClassifier 创建基于使用历史的分类器
+func NewUsageClassifier(client kubernetes.Interface, classifier *WorkloadClassifier, history UsageHistoryProvider, config UsageClassifierConfig) *UsageClassifier {
+	return &UsageClassifier{
+		client:     client,
+		classifier: classifier,
+		history:    history,
+		config:     config,
+	}
+}
+
+// ClassifyPod 根据Pod所属工作负载的使用历史分类
+func (uc *UsageClassifier) ClassifyPod(ctx context.Context, pod *v1.Pod) (*UsageClassification, error) {
+	staticProfile, ruleName, err := uc.classifier.classify(pod)
+	if err != nil {
+		return nil, err
+	}
+	fallback := &UsageClassification{
+		Profile:    staticProfile,
+		Pattern:    staticProfile.ResourcePattern,
+		Confidence: 1.0,
+		Source:     ClassificationSourceStatic,
+		Reason:     staticReason(ruleName),
+	}
+
+	ref, err := ResolveWorkloadRef(ctx, uc.client, pod)
+	if err != nil {
+		klog.Warningf("Failed to resolve workload of pod %s/%s, falling back to static classification: %v", pod.Namespace, pod.Name, err)
+		fallback.Reason = fmt.Sprintf("workload unresolved (%v); %s", err, fallback.Reason)
+		return fallback, nil
+	}
+
+	samples, err := uc.history.GetUsageHistory(ctx, ref, uc.config.Window)
+	if err != nil {
+		klog.Warningf("Failed to get usage history for %s, falling back to static classification: %v", ref, err)
+		fallback.Reason = fmt.Sprintf("usage history unavailable (%v); %s", err, fallback.Reason)
+		return fallback, nil
+	}
+	if len(samples) == 0 {
+		fallback.Reason = "no usage history; " + fallback.Reason
+		return fallback, nil
+	}
+
+	pattern, summary := uc.inferPattern(pod, samples)
+	profile, matched := uc.matchProfile(pattern, staticProfile)
+	if profile == nil {
+		fallback.Reason = fmt.Sprintf("no profile compatible with observed usage (%s); %s", summary, fallback.Reason)
+		return fallback, nil
+	}
+
+	coverage := float64(len(samples)) / float64(uc.config.MinSamples)
+	if coverage > 1 {
+		coverage = 1
+	}
+	confidence := float64(matched) / float64(resourcePatternDimensions) * coverage
+
+	result := &UsageClassification{
+		Profile:    profile,
+		Pattern:    pattern,
+		Confidence: confidence,
+		Source:     ClassificationSourceUsage,
+		Reason: fmt.Sprintf("%s over %d samples matched %s (%d/%d dimensions)",
+			summary, len(samples), profile.Type, matched, resourcePatternDimensions),
+	}
+
+	if confidence < uc.config.MinConfidence {
+		fallback.Reason = fmt.Sprintf("usage confidence %.2f below %.2f (%s); %s",
+			confidence, uc.config.MinConfidence, result.Reason, fallback.Reason)
+		return fallback, nil
+	}
+
+	klog.Infof("Classified pod %s/%s as %s from usage history of %s (confidence %.2f)",
+		pod.Namespace, pod.Name, profile.Type, ref, confidence)
+	return result, nil
+}
+
+// ApplyClassification 按使用历史分类并合并调度提示，置信度和分类依据写入分类记录
+func (uc *UsageClassifier) ApplyClassification(ctx context.Context, pod *v1.Pod) error {
+	result, err := uc.ClassifyPod(ctx, pod)
+	if err != nil {
+		return err
+	}
+
+	return uc.classifier.applyProfile(pod, result.Profile, result.Source, result.Confidence, result.Reason)
+}
+
+// resourcePatternDimensions 参与配置文件匹配的资源模式维度数
+const resourcePatternDimensions = 5
+
+// inferPattern 根据采样均值推断资源模式，GPU需求仍以Pod规格为准
+func (uc *UsageClassifier) inferPattern(pod *v1.Pod, samples []UsageSample) (ResourcePattern, string) {
+	var avg UsageSample
+	for _, sample := range samples {
+		avg.CPUCores += sample.CPUCores
+		avg.CPURequestCores += sample.CPURequestCores
+		avg.MemoryBytes += sample.MemoryBytes
+		avg.MemoryRequestBytes += sample.MemoryRequestBytes
+		avg.IOBytesPerSecond += sample.IOBytesPerSecond
+		avg.NetworkBytesPerSecond += sample.NetworkBytesPerSecond
+	}
+	n := float64(len(samples))
+	avg.CPUCores /= n
+	avg.CPURequestCores /= n
+	avg.MemoryBytes /= n
+	avg.MemoryRequestBytes /= n
+	avg.IOBytesPerSecond /= n
+	avg.NetworkBytesPerSecond /= n
+
+	pattern := ResourcePattern{
+		IOIntensive:      avg.IOBytesPerSecond >= uc.config.IOIntensiveBytes,
+		NetworkIntensive: avg.NetworkBytesPerSecond >= uc.config.NetworkIntensiveBytes,
+		GPURequired:      podRequestsGPU(pod),
+	}
+
+	var cpuSummary, memorySummary string
+	if avg.CPURequestCores > 0 {
+		ratio := avg.CPUCores / avg.CPURequestCores
+		pattern.CPUIntensive = ratio >= uc.config.CPUIntensiveRatio
+		cpuSummary = fmt.Sprintf("cpu %.0f%% of request", ratio*100)
+	} else {
+		pattern.CPUIntensive = avg.CPUCores >= uc.config.CPUIntensiveCores
+		cpuSummary = fmt.Sprintf("cpu %.2f cores", avg.CPUCores)
+	}
+	if avg.MemoryRequestBytes > 0 {
+		ratio := avg.MemoryBytes / avg.MemoryRequestBytes
+		pattern.MemoryIntensive = ratio >= uc.config.MemoryIntensiveRatio
+		memorySummary = fmt.Sprintf("memory %.0f%% of request", ratio*100)
+	} else {
+		pattern.MemoryIntensive = avg.MemoryBytes >= uc.config.MemoryIntensiveBytes
+		memorySummary = fmt.Sprintf("memory %.0fMi", avg.MemoryBytes/(1024*1024))
+	}
+
+	summary := fmt.Sprintf("%s, %s, io %.0fKi/s, network %.0fKi/s",
+		cpuSummary, memorySummary, avg.IOBytesPerSecond/1024, avg.NetworkBytesPerSecond/1024)
+	return pattern, summary
+}
+
+// matchProfile 选择资源模式最接近的配置文件
+// GPU需求必须一致；得分相同时优先静态分类结果，其次按类型名称排序保证结果稳定
+func (uc *UsageClassifier) matchProfile(pattern ResourcePattern, preferred *WorkloadProfile) (*WorkloadProfile, int) {
+	var best *WorkloadProfile
+	bestScore := -1
+	for _, workloadType := range uc.classifier.engine.ProfileTypes() {
+		profile, _ := uc.classifier.engine.GetProfile(workloadType)
+		p := profile.ResourcePattern
+		if p.GPURequired != pattern.GPURequired {
+			continue
+		}
+
+		score := 1 // GPU维度已匹配
+		for _, same := range []bool{
+			p.CPUIntensive == pattern.CPUIntensive,
+			p.MemoryIntensive == pattern.MemoryIntensive,
+			p.IOIntensive == pattern.IOIntensive,
+			p.NetworkIntensive == pattern.NetworkIntensive,
+		} {
+			if same {
+				score++
+			}
+		}
+
+		if score > bestScore || (score == bestScore && profile == preferred) {
+			best = profile
+			bestScore = score
+		}
+	}
+
+	return best, bestScore
+}
+
+func podRequestsGPU(pod *v1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if _, hasGPU := container.Resources.Requests["nvidia.com/gpu"]; hasGPU {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveWorkloadRef 解析Pod的顶层所属工作负载
+// ReplicaSet会继续追溯到Deployment，Job会追溯到CronJob；没有所有者的Pod以自身作为工作负载
+func ResolveWorkloadRef(ctx context.Context, client kubernetes.Interface, pod *v1.Pod) (WorkloadRef, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return WorkloadRef{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name}, nil
+	}
+
+	ref := WorkloadRef{Namespace: pod.Namespace, Kind: owner.Kind, Name: owner.Name}
+	switch owner.Kind {
+	case "ReplicaSet":
+		rs, err := client.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return ref, fmt.Errorf("failed to get replicaset %s/%s: %v", pod.Namespace, owner.Name, err)
+		}
+		if parent := metav1.GetControllerOf(rs); parent != nil {
+			ref.Kind, ref.Name = parent.Kind, parent.Name
+		}
+	case "Job":
+		job, err := client.BatchV1().Jobs(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return ref, fmt.Errorf("failed to get job %s/%s: %v", pod.Namespace, owner.Name, err)
+		}
+		if parent := metav1.GetControllerOf(job); parent != nil {
+			ref.Kind, ref.Name = parent.Kind, parent.Name
+		}
+	}
+
+	return ref, nil
+}
+
+// RecordedUsageHistory 内存中的使用历史记录
+// 可由外部采集器（如Prometheus导出的IO和网络数据）写入，也作为MetricsUsageHistory的存储
+type RecordedUsageHistory struct {
+	mu        sync.RWMutex
+	retention time.Duration
+	samples   map[WorkloadRef][]UsageSample
+}
+
+// NewRecordedUsageHistory 创建使用历史记录，超过保留时长的采样会被丢弃
+func NewRecordedUsageHistory(retention time.Duration) *RecordedUsageHistory {
+	return &RecordedUsageHistory{
+		retention: retention,
+		samples:   make(map[WorkloadRef][]UsageSample),
+	}
+}
+
+// Record 记录一次采样
+func (h *RecordedUsageHistory) Record(ref WorkloadRef, sample UsageSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := sample.Timestamp.Add(-h.retention)
+	samples := append(h.samples[ref], sample)
+	start := 0
+	for start < len(samples) && samples[start].Timestamp.Before(cutoff) {
+		start++
+	}
+	h.samples[ref] = samples[start:]
+}
+
+// GetUsageHistory 实现UsageHistoryProvider
+func (h *RecordedUsageHistory) GetUsageHistory(ctx context.Context, ref WorkloadRef, window time.Duration) ([]UsageSample, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	cutoff := time.Now().Add(-window)
+	var result []UsageSample
+	for _, sample := range h.samples[ref] {
+		if !sample.Timestamp.Before(cutoff) {
+			result = append(result, sample)
+		}
+	}
+	return result, nil
+}
+
+// MetricsUsageHistory 基于metrics-server的使用历史
+// 周期性调用Collect采集Pod指标，按所属工作负载聚合后写入RecordedUsageHistory；
+// metrics-server不提供IO和网络数据，这两项保持为0
+type MetricsUsageHistory struct {
+	*RecordedUsageHistory
+	client        kubernetes.Interface
+	metricsClient metricsclient.Interface
+}
+
+// NewMetricsUsageHistory 创建基于metrics-server的使用历史
+func NewMetricsUsageHistory(client kubernetes.Interface, metricsClient metricsclient.Interface, retention time.Duration) *MetricsUsageHistory {
+	return &MetricsUsageHistory{
+		RecordedUsageHistory: NewRecordedUsageHistory(retention),
+		client:               client,
+		metricsClient:        metricsClient,
+	}
+}
+
+// Collect 采集命名空间内所有Pod的使用指标（namespace为空表示所有命名空间）
+func (m *MetricsUsageHistory) Collect(ctx context.Context, namespace string) error {
+	podMetrics, err := m.metricsClient.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get pod metrics: %v", err)
+	}
+	pods, err := m.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	podsByKey := make(map[string]*v1.Pod, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podsByKey[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	totals := make(map[WorkloadRef]*UsageSample)
+	refCache := make(map[string]WorkloadRef)
+	now := time.Now()
+	for _, pm := range podMetrics.Items {
+		pod, exists := podsByKey[pm.Namespace+"/"+pm.Name]
+		if !exists {
+			continue
+		}
+
+		ref, err := m.resolveCached(ctx, pod, refCache)
+		if err != nil {
+			klog.V(2).Infof("Skipping usage of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+
+		total, exists := totals[ref]
+		if !exists {
+			total = &UsageSample{Timestamp: now}
+			totals[ref] = total
+		}
+		total.PodCount++
+		for _, container := range pm.Containers {
+			total.CPUCores += float64(container.Usage.Cpu().MilliValue()) / 1000
+			total.MemoryBytes += float64(container.Usage.Memory().Value())
+		}
+		for _, container := range pod.Spec.Containers {
+			total.CPURequestCores += float64(container.Resources.Requests.Cpu().MilliValue()) / 1000
+			total.MemoryRequestBytes += float64(container.Resources.Requests.Memory().Value())
+		}
+	}
+
+	for ref, total := range totals {
+		n := float64(total.PodCount)
+		m.Record(ref, UsageSample{
+			Timestamp:          total.Timestamp,
+			PodCount:           total.PodCount,
+			CPUCores:           total.CPUCores / n,
+			CPURequestCores:    total.CPURequestCores / n,
+			MemoryBytes:        total.MemoryBytes / n,
+			MemoryRequestBytes: total.MemoryRequestBytes / n,
+		})
+	}
+
+	return nil
+}
+
+// resolveCached 按直接所有者缓存工作负载解析结果，避免同一ReplicaSet重复查询
+func (m *MetricsUsageHistory) resolveCached(ctx context.Context, pod *v1.Pod, cache map[string]WorkloadRef) (WorkloadRef, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return ResolveWorkloadRef(ctx, m.client, pod)
+	}
+
+	key := pod.Namespace + "/" + owner.Kind + "/" + owner.Name
+	if ref, exists := cache[key]; exists {
+		return ref, nil
+	}
+	ref, err := ResolveWorkloadRef(ctx, m.client, pod)
+	if err != nil {
+		return ref, err
+	}
+	cache[key] = ref
+	return ref, nil
+}
+
+// Run 按间隔持续采集使用指标，直到ctx取消
+func (m *MetricsUsageHistory) Run(ctx context.Context, namespace string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Collect(ctx, namespace); err != nil {
+			klog.Errorf("Failed to collect usage history: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}